@@ -213,7 +213,7 @@ export default function AppHome() {
             <input
               ref={fileInputRef}
               type="file"
-              accept=".pdf,.txt,.md"
+              accept=".pdf,.txt,.md,.pptx,.odp,.epub,.eml,.mbox"
               style={{ display: 'none' }}
               onChange={handleFileChange}
             />
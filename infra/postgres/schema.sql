@@ -43,16 +43,25 @@ CREATE TABLE IF NOT EXISTS documents (
     status         text NOT NULL DEFAULT 'ready',
     metadata       jsonb NOT NULL DEFAULT '{}'::jsonb,
 
+    -- Set for documents extracted from another document (e.g. email
+    -- attachments, source_type = 'attachment').
+    parent_document_id uuid REFERENCES documents(id) ON DELETE CASCADE,
+
     created_at     timestamptz NOT NULL DEFAULT now(),
     updated_at     timestamptz NOT NULL DEFAULT now()
 );
 
+-- Columns added since the table was first created. On an existing database
+-- these statements add them; on a new one they do nothing.
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS parent_document_id uuid REFERENCES documents(id) ON DELETE CASCADE;
+
 CREATE INDEX IF NOT EXISTS documents_user_id_idx ON documents (user_id);
 CREATE INDEX IF NOT EXISTS documents_status_idx ON documents (status);
 CREATE INDEX IF NOT EXISTS documents_created_at_idx ON documents (created_at);
 -- Helpful for deduplicating uploads per-user when checksum is available.
 CREATE INDEX IF NOT EXISTS documents_user_checksum_idx ON documents (user_id, checksum_sha256);
 CREATE INDEX IF NOT EXISTS documents_user_storage_path_idx ON documents (user_id, storage_path);
+CREATE INDEX IF NOT EXISTS documents_parent_document_id_idx ON documents (parent_document_id);
 
 
 -- Documents: full extracted text content for quick debug / small-document queries.
@@ -72,23 +81,101 @@ CREATE TABLE IF NOT EXISTS document_chunks (
 
     chunk_index     integer NOT NULL,
     content_text    text NOT NULL,
+
+    -- Hierarchical chunking: parent chunks (is_parent) are large sections
+    -- kept for query-time context; the chunks cut from them are embedded and
+    -- point to their parent. Parents and embedded chunks are indexed
+    -- separately. Flat chunking stores no parents.
+    is_parent       boolean NOT NULL DEFAULT false,
+    parent_chunk_id uuid REFERENCES document_chunks(id) ON DELETE CASCADE,
+
     token_count     integer,
 
-    -- Link to vector DB record (Qdrant point ID). Store as UUID; can be text if you prefer.
+    -- Link to vector DB record (Qdrant point ID), set with embedded_at once
+    -- the chunk is indexed. NULL for chunks that are not (yet) embedded,
+    -- including parents. `api reconcile` repairs chunks and points that
+    -- are out of step.
     qdrant_point_id uuid,
+    embedded_at     timestamptz,
 
-    -- Optional offsets if chunks are derived from a single normalized text stream.
+    -- Character (code point) offsets of content_text in
+    -- document_contents.content, end exclusive. NULL for chunks stored
+    -- before offsets were recorded.
     start_offset    integer,
     end_offset      integer,
 
+    -- Hex SHA-256 of content_text. When a document is reindexed, new chunks
+    -- matching an embedded previous chunk take over its id and vector.
     content_sha256  text,
 
+    -- Structural span of the chunk (e.g. segment_kind = 'page', pages 3-4)
+    -- and the title of its first segment (slide or chapter title).
+    -- NULL for documents without segments.
+    segment_kind    text,
+    segment_start   integer,
+    segment_end     integer,
+    segment_title   text,
+
+    -- Heading breadcrumb of the chunk's section for Markdown-structured
+    -- documents, e.g. {'Install', 'Linux'}. NULL when unknown.
+    section_path    text[],
+
     created_at      timestamptz NOT NULL DEFAULT now(),
     updated_at      timestamptz NOT NULL DEFAULT now(),
 
-    CONSTRAINT document_chunks_document_chunk_index_uq UNIQUE (document_id, chunk_index)
+    CONSTRAINT document_chunks_document_chunk_index_uq UNIQUE (document_id, is_parent, chunk_index)
 );
 
+-- Columns added since the table was first created. On an existing database
+-- these statements add them; on a new one they do nothing.
+ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS segment_kind text;
+ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS segment_start integer;
+ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS segment_end integer;
+ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS segment_title text;
+ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS section_path text[];
+ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS is_parent boolean NOT NULL DEFAULT false;
+ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS parent_chunk_id uuid REFERENCES document_chunks(id) ON DELETE CASCADE;
+ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS embedded_at timestamptz;
+
+-- Chunk indexes became unique per kind (parent or embedded) with
+-- hierarchical chunking; widen the original (document_id, chunk_index)
+-- constraint.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'document_chunks'::regclass
+          AND conname = 'document_chunks_document_chunk_index_uq'
+          AND pg_get_constraintdef(oid) <> 'UNIQUE (document_id, is_parent, chunk_index)'
+    ) THEN
+        ALTER TABLE document_chunks DROP CONSTRAINT document_chunks_document_chunk_index_uq;
+        ALTER TABLE document_chunks ADD CONSTRAINT document_chunks_document_chunk_index_uq
+            UNIQUE (document_id, is_parent, chunk_index);
+    END IF;
+END
+$$;
+
 CREATE INDEX IF NOT EXISTS document_chunks_document_id_idx ON document_chunks (document_id);
+CREATE INDEX IF NOT EXISTS document_chunks_parent_chunk_id_idx ON document_chunks (parent_chunk_id);
 CREATE INDEX IF NOT EXISTS document_chunks_qdrant_point_id_idx ON document_chunks (qdrant_point_id);
 CREATE INDEX IF NOT EXISTS document_chunks_created_at_idx ON document_chunks (created_at);
+
+
+-- Chunk vectors of the embedded vector backend (VECTOR_BACKEND=embedded,
+-- VECTOR_STORE=postgres), loaded into an in-memory HNSW index at startup.
+-- id is the chunk ID (point ID); payload holds the chunk as embedded.
+-- No foreign keys: reindexing replaces chunks while keeping their vectors,
+-- and `api reconcile` deletes vectors whose chunk is gone.
+CREATE TABLE IF NOT EXISTS chunk_vectors (
+    id          uuid PRIMARY KEY,
+    document_id uuid NOT NULL,
+    user_id     uuid NOT NULL,
+
+    -- Embedding model; vectors of another model are not loaded.
+    model       text NOT NULL,
+    payload     jsonb NOT NULL,
+    embedding   real[] NOT NULL,
+
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    updated_at  timestamptz NOT NULL DEFAULT now()
+);
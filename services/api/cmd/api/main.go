@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,6 +16,13 @@ import (
 	"docsense/api/internal/adapters/config"
 	"docsense/api/internal/adapters/postgres"
 	"docsense/api/internal/adapters/rag"
+	"docsense/api/internal/adapters/vector"
+	"docsense/api/internal/ingest/chunk"
+	"docsense/api/internal/ingest/sandbox"
+	"docsense/api/internal/ingest/tokenize"
+	"docsense/api/internal/metrics"
+	"docsense/api/internal/ports"
+	"docsense/api/internal/tracing"
 	"docsense/api/internal/transport/http/auth"
 	"docsense/api/internal/transport/http/documents"
 	"docsense/api/internal/transport/http/middleware"
@@ -25,10 +33,36 @@ import (
 )
 
 func main() {
+	// The extraction worker is the same binary; see sandbox.RunWorker.
+	if len(os.Args) > 1 && os.Args[1] == sandbox.WorkerCommand {
+		os.Exit(sandbox.RunWorker(os.Stdin, os.Stdout))
+	}
+
 	cfg, err := config.LoadFromEnv()
 	if err != nil {
 		log.Fatalf("config error: %v", err)
 	}
+	isReconcile := len(os.Args) > 1 && os.Args[1] == reconcileCommand
+	if isReconcile {
+		if err := checkReconcileBackend(cfg); err != nil {
+			log.Fatalf("reconcile error: %v", err)
+		}
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("tracing error: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("tracing shutdown error: %v", err)
+		}
+	}()
+	if cfg.Tracing.Exporter != "none" {
+		log.Printf("tracing: exporting spans (%s)", cfg.Tracing.Exporter)
+	}
 
 	db, err := postgres.Open(cfg)
 	if err != nil {
@@ -41,31 +75,86 @@ func main() {
 	router := gin.New()
 	// Keep multipart parsing bounded; actual upload limit is enforced per-request.
 	router.MaxMultipartMemory = 8 << 20
-	router.Use(gin.Logger())
+	router.Use(middleware.Logger())
 	router.Use(gin.Recovery())
+	router.Use(middleware.Tracing())
 	router.Use(middleware.RequestID())
+	router.Use(middleware.Metrics())
 	if cfg.App.Env != "production" {
 		router.Use(middleware.DevAuth())
 	}
 
-	ragClient := rag.NewClient(cfg.RAG)
+	var ragClient ports.RAG = rag.NewClient(cfg.RAG, vector.DocumentOwners(db))
+	if cfg.RAG.Mode == "http" && cfg.RAG.SigningKey == "" {
+		log.Printf("warning: RAG_ALLOW_UNSIGNED is set; requests to the RAG service are unsigned")
+	}
+	if cfg.RAG.Mode == "fake" {
+		log.Printf("rag: using the in-process fake (RAG_MODE=fake); its index is not persisted")
+		ragClient = rag.NewFake()
+	}
+	if cfg.Vector.Backend == "embedded" {
+		idx, err := openVectorIndex(db, cfg.Vector)
+		if err != nil {
+			log.Fatalf("vector index error: %v", err)
+		}
+		ragClient = embeddedRAG{Index: idx, Generator: ragClient}
+	}
+	extractor, err := sandbox.NewRunner(cfg.Extract)
+	if err != nil {
+		log.Fatalf("extractor error: %v", err)
+	}
+	chunking := chunk.Options{Strategy: cfg.Chunk.Strategy, Size: cfg.Chunk.Size, Overlap: cfg.Chunk.Overlap, ParentSize: cfg.Chunk.ParentSize}
+	if err := chunking.Validate(); err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+	tokenizer, err := tokenize.Load(cfg.Chunk.Tokenizer, cfg.Chunk.TokenizerVocabFile)
+	if err != nil {
+		log.Fatalf("tokenizer error: %v", err)
+	}
+
+	docs := documents.NewHandler(db, cfg.Storage.Dir, cfg.Storage.MaxUploadBytes, ragClient, extractor, chunking, tokenizer, cfg.RAG.NeighborWindow, cfg.Embed)
+	if isReconcile {
+		err := reconcile(docs, os.Args[2:])
+		_ = db.Close()
+		if err != nil {
+			log.Fatalf("reconcile error: %v", err)
+		}
+		return
+	}
 
 	api := router.Group("/api")
 	auth.RegisterRoutes(api)
 	users.RegisterRoutes(api)
-	documents.NewHandler(db, cfg.Storage.Dir, cfg.Storage.MaxUploadBytes, ragClient).RegisterRoutes(api)
+	docs.RegisterRoutes(api)
+	if cfg.Admin.Token != "" {
+		docs.RegisterAdminRoutes(router.Group("/admin", middleware.AdminToken(cfg.Admin.Token)))
+	}
 
 	router.GET("/health", func(c *gin.Context) {
+		if err := extractor.Health(); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
+	if err := docs.RegisterMetrics(metrics.Default); err != nil {
+		log.Fatalf("metrics error: %v", err)
+	}
+	if err := metrics.RegisterDBStats(metrics.Default, db); err != nil {
+		log.Fatalf("metrics error: %v", err)
+	}
+	metricsSrv := serveMetrics(router, cfg.Metrics)
+
 	srv := &http.Server{
 		Addr:              fmt.Sprintf(":%d", cfg.HTTP.Port),
 		Handler:           router,
 		ReadTimeout:       10 * time.Second,
 		ReadHeaderTimeout: 10 * time.Second,
-		WriteTimeout:      30 * time.Second,
-		IdleTimeout:       2 * time.Minute,
+		// Handlers that ingest or generate synchronously lift this; their
+		// work is bounded by the extract, embedding and RAG timeouts.
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  2 * time.Minute,
 	}
 
 	shutdownCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -87,11 +176,113 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Printf("http shutdown error: %v", err)
 	}
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(ctx); err != nil {
+			log.Printf("metrics shutdown error: %v", err)
+		}
+	}
 
 	drainDB(ctx, db)
 	log.Printf("shutdown complete")
 }
 
+// serveMetrics exposes /metrics: on its own listener when cfg.Addr is set,
+// which it returns, or else on router, only behind cfg.Token. The token is
+// optional on the separate listener: without one, /metrics there is
+// unauthenticated and relies on the port being reachable only by the
+// scraper.
+func serveMetrics(router *gin.Engine, cfg config.MetricsConfig) *http.Server {
+	handlers := []gin.HandlerFunc{gin.WrapH(metrics.Handler())}
+	if cfg.Token != "" {
+		handlers = append([]gin.HandlerFunc{middleware.AdminToken(cfg.Token)}, handlers...)
+	}
+
+	if cfg.Addr == "" {
+		if cfg.Token == "" {
+			log.Printf("metrics: /metrics is disabled; set METRICS_TOKEN or METRICS_ADDR")
+			return nil
+		}
+		router.GET("/metrics", handlers...)
+		return nil
+	}
+
+	if cfg.Token == "" {
+		log.Printf("warning: metrics: /metrics on %s is unauthenticated; set METRICS_TOKEN or expose the port only to the scraper", cfg.Addr)
+	}
+	mr := gin.New()
+	mr.Use(gin.Recovery())
+	mr.GET("/metrics", handlers...)
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           mr,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+	go func() {
+		log.Printf("metrics listening on %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("metrics server error: %v", err)
+		}
+	}()
+	return srv
+}
+
+// reconcileCommand runs reconcile instead of the server:
+//
+//	api reconcile [-dry-run]
+const reconcileCommand = "reconcile"
+
+// reconcile repairs chunks and vector points that are out of step (see
+// documents.Handler.Reconcile) and logs what it found.
+func reconcile(docs *documents.Handler, args []string) error {
+	fs := flag.NewFlagSet(reconcileCommand, flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report differences without repairing them")
+	_ = fs.Parse(args)
+
+	report, err := docs.Reconcile(context.Background(), *dryRun)
+	log.Printf("reconcile: %s", report)
+	return err
+}
+
+// checkReconcileBackend refuses `api reconcile` for indexes held in the
+// server's memory: this process would build and repair a copy of its own
+// that the server never sees (and, with VECTOR_STORE=disk, append to the
+// server's vector log alongside it). Those use POST /admin/reconcile.
+func checkReconcileBackend(cfg config.Config) error {
+	switch {
+	case cfg.Vector.Backend == "embedded":
+		return errors.New("VECTOR_BACKEND=embedded keeps its index in the server; use POST /admin/reconcile (needs ADMIN_TOKEN)")
+	case cfg.RAG.Mode == "fake":
+		return errors.New("RAG_MODE=fake keeps its index in the server; use POST /admin/reconcile (needs ADMIN_TOKEN)")
+	}
+	return nil
+}
+
+// embeddedRAG indexes and retrieves with the embedded vector index and
+// answers with another backend's generator.
+type embeddedRAG struct {
+	*vector.Index
+	ports.Generator
+}
+
+// openVectorIndex opens the embedded vector index and loads its vectors.
+func openVectorIndex(db *sql.DB, cfg config.VectorConfig) (*vector.Index, error) {
+	var store vector.Store = vector.NewPostgresStore(db)
+	if cfg.Store == "disk" {
+		disk, err := vector.NewDiskStore(cfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		store = disk
+	}
+	var embedder vector.Embedder = vector.HashEmbedder{Dims: cfg.HashDims}
+	if cfg.Embedder == "openai" {
+		embedder = vector.NewOpenAIEmbedder(cfg.EmbeddingURL, cfg.EmbeddingAPIKey, cfg.EmbeddingModel, cfg.EmbeddingTimeout)
+	}
+	opts := vector.Options{M: cfg.M, EfConstruction: cfg.EfConstruction, EfSearch: cfg.EfSearch}
+	return vector.Open(context.Background(), store, embedder, vector.DocumentOwners(db), opts)
+}
+
 func drainDB(ctx context.Context, db *sql.DB) {
 	// Best-effort: close idle connections and stop accepting new ones.
 	// Any in-flight queries should complete before the server exits.
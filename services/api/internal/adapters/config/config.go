@@ -3,7 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -39,11 +41,121 @@ type StorageConfig struct {
 }
 
 type RAGConfig struct {
+	// Mode selects the RAG backend: "http" (the RAG service) or "fake" (an
+	// in-process index and extractive answers, for offline development).
+	Mode string
+
 	// BaseURL is the base URL of the RAG service (e.g., "http://rag:8000")
 	BaseURL string
 
-	// Timeout is the HTTP client timeout for RAG service calls
+	// Timeout bounds each attempt of RAG service calls without a timeout
+	// of their own (index maintenance), and is the default of the others.
 	Timeout time.Duration
+
+	// EmbedTimeout, QueryTimeout and GenerateTimeout bound each attempt of
+	// embedding, retrieval and answer generation calls.
+	EmbedTimeout    time.Duration
+	QueryTimeout    time.Duration
+	GenerateTimeout time.Duration
+
+	// MaxAttempts is how many times a call failing with a connection error,
+	// timeout or 5xx is attempted. Retries wait a random time up to
+	// RetryBackoff, doubling per attempt up to MaxRetryBackoff.
+	MaxAttempts     int
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+
+	// After BreakerThreshold consecutive failed calls, calls fail fast for
+	// BreakerCooldown before the service is tried again; 0 disables it.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// SigningKeyID and SigningKey sign requests to the RAG service, which
+	// must list the key in RAG_SIGNING_KEYS. Set from RAG_SIGNING_KEY as
+	// "id:secret"; required in http mode unless AllowUnsigned.
+	SigningKeyID string
+	SigningKey   string
+
+	// AllowUnsigned sends requests unsigned when no key is set, for a RAG
+	// service that also runs with RAG_ALLOW_UNSIGNED.
+	AllowUnsigned bool
+
+	// NeighborWindow is how many chunks on each side a retrieved chunk
+	// without a parent is expanded by before generation; 0 disables it.
+	NeighborWindow int
+}
+
+type EmbedConfig struct {
+	// BatchSize is the number of chunks sent to the RAG service per embed
+	// request.
+	BatchSize int
+
+	// Concurrency is the number of embed requests in flight per document.
+	Concurrency int
+}
+
+// VectorConfig configures the embedded vector backend, which replaces the
+// RAG service's indexing and retrieval (but not answer generation).
+type VectorConfig struct {
+	// Backend is "rag" (indexing and retrieval by the RAG_MODE backend) or
+	// "embedded" (an in-process HNSW index).
+	Backend string
+
+	// Store is where embedded vectors are kept: "postgres" (the
+	// chunk_vectors table) or "disk" (a log file in Dir).
+	Store string
+	Dir   string
+
+	// Embedder computes vectors: "hash" (hashed bag of words of HashDims
+	// dimensions; no model needed) or "openai" (an OpenAI-compatible
+	// embeddings API at EmbeddingURL).
+	Embedder         string
+	HashDims         int
+	EmbeddingURL     string
+	EmbeddingModel   string
+	EmbeddingAPIKey  string
+	EmbeddingTimeout time.Duration
+
+	// HNSW graph parameters: links per node and candidate list sizes.
+	M              int
+	EfConstruction int
+	EfSearch       int
+}
+
+type ExtractConfig struct {
+	// Timeout bounds the extraction of a single document.
+	Timeout time.Duration
+
+	// MaxOutputBytes caps the text and attachments extracted from a single
+	// document; 0 disables the cap.
+	MaxOutputBytes int64
+
+	// Isolate runs extractors in a child process (the extract-worker
+	// subcommand) that can be killed and memory-limited. It is on by
+	// default; in-process extraction cannot stop a parser that times out.
+	Isolate bool
+
+	// MemoryLimitBytes caps the worker's resident memory, above which it is
+	// killed; 0 disables it. Only used with Isolate.
+	MemoryLimitBytes int64
+}
+
+type ChunkConfig struct {
+	// Strategy is the default chunking strategy: fixed, sentence, paragraph
+	// or recursive. Uploads may override it.
+	Strategy string
+
+	// Size and Overlap are the default chunk size and overlap in tokens.
+	Size    int
+	Overlap int
+	// ParentSize, when non-zero, enables hierarchical chunking: chunks are
+	// cut from parent chunks of this many tokens, which queries expand to.
+	ParentSize int
+
+	// Tokenizer measures chunks: cl100k, wordpiece or words. Its vocabulary
+	// is embedded at build time unless TokenizerVocabFile names one.
+	Tokenizer          string
+	TokenizerVocabFile string
 }
 
 type Config struct {
@@ -52,6 +164,52 @@ type Config struct {
 	Postgres PostgresConfig
 	Storage  StorageConfig
 	RAG      RAGConfig
+	Embed    EmbedConfig
+	Vector   VectorConfig
+	Extract  ExtractConfig
+	Chunk    ChunkConfig
+	Tracing  TracingConfig
+	Metrics  MetricsConfig
+	Admin    AdminConfig
+}
+
+type TracingConfig struct {
+	// Exporter is where finished spans go: "none" (spans are not recorded;
+	// trace context is still propagated), "otlp" (OTLP/HTTP, protobuf
+	// encoding), "console" (stdout) or "file" (File).
+	Exporter string
+
+	// OTLPEndpoint is the full URL spans are posted to; OTLPHeaders are
+	// added to each export request ("key=value,...").
+	OTLPEndpoint string
+	OTLPHeaders  map[string]string
+
+	// File is where the file exporter appends spans as JSON, one per
+	// line (the OpenTelemetry stdout exporter's format).
+	File string
+
+	ServiceName string
+
+	// SampleRatio is the fraction of new traces recorded. Requests that
+	// carry a traceparent follow the caller's sampling decision.
+	SampleRatio float64
+}
+
+type MetricsConfig struct {
+	// Addr, when set, serves /metrics on its own listener (e.g. ":9090"),
+	// reachable only from where that port is exposed.
+	Addr string
+
+	// Token, when set, is required as "Authorization: Bearer <token>" to
+	// read /metrics. Without Addr, /metrics is served on the API's port
+	// only if Token is set.
+	Token string
+}
+
+type AdminConfig struct {
+	// Token, when set, enables the /admin routes (POST /admin/reconcile)
+	// and is required there as "Authorization: Bearer <token>".
+	Token string
 }
 
 // LoadFromEnv loads configuration purely from environment variables.
@@ -78,8 +236,76 @@ func LoadFromEnv() (Config, error) {
 	cfg.Storage.Dir = getenvDefault("STORAGE_DIR", "/data")
 	cfg.Storage.MaxUploadBytes = getenvInt64Default("MAX_UPLOAD_BYTES", 25<<20) // 25 MiB
 
+	cfg.RAG.Mode = getenvDefault("RAG_MODE", "http")
 	cfg.RAG.BaseURL = getenvDefault("RAG_SERVICE_URL", "http://rag:8000")
 	cfg.RAG.Timeout = getenvDurationDefault("RAG_SERVICE_TIMEOUT", 60*time.Second)
+	cfg.RAG.EmbedTimeout = getenvDurationDefault("RAG_EMBED_TIMEOUT", cfg.RAG.Timeout)
+	cfg.RAG.QueryTimeout = getenvDurationDefault("RAG_QUERY_TIMEOUT", 10*time.Second)
+	cfg.RAG.GenerateTimeout = getenvDurationDefault("RAG_GENERATE_TIMEOUT", cfg.RAG.Timeout)
+	cfg.RAG.MaxAttempts = getenvIntDefault("RAG_MAX_ATTEMPTS", 3)
+	cfg.RAG.RetryBackoff = getenvDurationDefault("RAG_RETRY_BACKOFF", 500*time.Millisecond)
+	cfg.RAG.MaxRetryBackoff = getenvDurationDefault("RAG_MAX_RETRY_BACKOFF", 5*time.Second)
+	cfg.RAG.BreakerThreshold = getenvIntDefault("RAG_BREAKER_THRESHOLD", 5)
+	cfg.RAG.BreakerCooldown = getenvDurationDefault("RAG_BREAKER_COOLDOWN", 30*time.Second)
+	cfg.RAG.NeighborWindow = getenvIntDefault("QUERY_NEIGHBOR_WINDOW", 1)
+	if key := getenvDefault("RAG_SIGNING_KEY", ""); key != "" {
+		id, secret, ok := strings.Cut(key, ":")
+		if !ok || id == "" || secret == "" {
+			return Config{}, fmt.Errorf("invalid RAG_SIGNING_KEY: want id:secret")
+		}
+		cfg.RAG.SigningKeyID, cfg.RAG.SigningKey = id, secret
+	}
+	cfg.RAG.AllowUnsigned = getenvBoolDefault("RAG_ALLOW_UNSIGNED", false)
+
+	cfg.Embed.BatchSize = getenvIntDefault("EMBED_BATCH_SIZE", 64)
+	cfg.Embed.Concurrency = getenvIntDefault("EMBED_CONCURRENCY", 4)
+
+	cfg.Vector.Backend = getenvDefault("VECTOR_BACKEND", "rag")
+	cfg.Vector.Store = getenvDefault("VECTOR_STORE", "postgres")
+	cfg.Vector.Dir = getenvDefault("VECTOR_DIR", filepath.Join(cfg.Storage.Dir, "vectors"))
+	cfg.Vector.Embedder = getenvDefault("VECTOR_EMBEDDER", "hash")
+	cfg.Vector.HashDims = getenvIntDefault("VECTOR_HASH_DIMS", 1024)
+	cfg.Vector.EmbeddingURL = getenvDefault("VECTOR_EMBEDDING_URL", "https://api.openai.com/v1")
+	cfg.Vector.EmbeddingModel = getenvDefault("VECTOR_EMBEDDING_MODEL", "text-embedding-3-small")
+	cfg.Vector.EmbeddingAPIKey = getenvDefault("VECTOR_EMBEDDING_API_KEY", "")
+	cfg.Vector.EmbeddingTimeout = getenvDurationDefault("VECTOR_EMBEDDING_TIMEOUT", 30*time.Second)
+	cfg.Vector.M = getenvIntDefault("VECTOR_HNSW_M", 16)
+	cfg.Vector.EfConstruction = getenvIntDefault("VECTOR_HNSW_EF_CONSTRUCTION", 200)
+	cfg.Vector.EfSearch = getenvIntDefault("VECTOR_HNSW_EF_SEARCH", 64)
+
+	cfg.Extract.Timeout = getenvDurationDefault("EXTRACT_TIMEOUT", 2*time.Minute)
+	cfg.Extract.MaxOutputBytes = getenvInt64Default("EXTRACT_MAX_OUTPUT_BYTES", 64<<20) // 64 MiB
+	cfg.Extract.Isolate = getenvBoolDefault("EXTRACT_ISOLATE", true)
+	cfg.Extract.MemoryLimitBytes = getenvInt64Default("EXTRACT_MEMORY_LIMIT_BYTES", 1<<30) // 1 GiB
+
+	cfg.Chunk.Strategy = getenvDefault("CHUNK_STRATEGY", "fixed")
+	cfg.Chunk.Size = getenvIntDefault("CHUNK_SIZE", 700)
+	cfg.Chunk.Overlap = getenvIntDefault("CHUNK_OVERLAP", 100)
+	cfg.Chunk.ParentSize = getenvIntDefault("CHUNK_PARENT_SIZE", 0)
+	cfg.Chunk.Tokenizer = getenvDefault("TOKENIZER", "cl100k")
+	cfg.Chunk.TokenizerVocabFile = getenvDefault("TOKENIZER_VOCAB_FILE", "")
+
+	cfg.Tracing.Exporter = getenvDefault("OTEL_TRACES_EXPORTER", "none")
+	cfg.Tracing.OTLPEndpoint = getenvDefault("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+		strings.TrimRight(getenvDefault("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318"), "/")+"/v1/traces")
+	cfg.Tracing.File = getenvDefault("OTEL_TRACES_FILE", "traces.jsonl")
+	cfg.Tracing.ServiceName = getenvDefault("OTEL_SERVICE_NAME", "docsense-api")
+	cfg.Tracing.SampleRatio = getenvFloatDefault("OTEL_TRACES_SAMPLER_ARG", 1)
+	if h := getenvDefault("OTEL_EXPORTER_OTLP_HEADERS", ""); h != "" {
+		cfg.Tracing.OTLPHeaders = make(map[string]string)
+		for _, kv := range strings.Split(h, ",") {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok || strings.TrimSpace(k) == "" {
+				return Config{}, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_HEADERS: want key=value,...")
+			}
+			cfg.Tracing.OTLPHeaders[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+
+	cfg.Metrics.Addr = getenvDefault("METRICS_ADDR", "")
+	cfg.Metrics.Token = getenvDefault("METRICS_TOKEN", "")
+
+	cfg.Admin.Token = getenvDefault("ADMIN_TOKEN", "")
 
 	if cfg.HTTP.Port <= 0 {
 		return Config{}, fmt.Errorf("invalid HTTP_PORT: %d", cfg.HTTP.Port)
@@ -102,9 +328,100 @@ func LoadFromEnv() (Config, error) {
 	if cfg.Storage.MaxUploadBytes <= 0 {
 		return Config{}, fmt.Errorf("invalid MAX_UPLOAD_BYTES: %d", cfg.Storage.MaxUploadBytes)
 	}
+	if cfg.RAG.Mode != "http" && cfg.RAG.Mode != "fake" {
+		return Config{}, fmt.Errorf("invalid RAG_MODE: %q (want http or fake)", cfg.RAG.Mode)
+	}
 	if cfg.RAG.BaseURL == "" {
 		return Config{}, fmt.Errorf("RAG_SERVICE_URL is required")
 	}
+	if cfg.RAG.Mode == "http" && cfg.RAG.SigningKey == "" && !cfg.RAG.AllowUnsigned {
+		return Config{}, fmt.Errorf("RAG_SIGNING_KEY is required (or RAG_ALLOW_UNSIGNED=true)")
+	}
+	for name, d := range map[string]time.Duration{
+		"RAG_SERVICE_TIMEOUT":   cfg.RAG.Timeout,
+		"RAG_EMBED_TIMEOUT":     cfg.RAG.EmbedTimeout,
+		"RAG_QUERY_TIMEOUT":     cfg.RAG.QueryTimeout,
+		"RAG_GENERATE_TIMEOUT":  cfg.RAG.GenerateTimeout,
+		"RAG_RETRY_BACKOFF":     cfg.RAG.RetryBackoff,
+		"RAG_MAX_RETRY_BACKOFF": cfg.RAG.MaxRetryBackoff,
+		"RAG_BREAKER_COOLDOWN":  cfg.RAG.BreakerCooldown,
+	} {
+		if d < 0 {
+			return Config{}, fmt.Errorf("invalid %s: %s", name, d)
+		}
+	}
+	if cfg.RAG.MaxAttempts <= 0 {
+		return Config{}, fmt.Errorf("invalid RAG_MAX_ATTEMPTS: %d", cfg.RAG.MaxAttempts)
+	}
+	if cfg.RAG.BreakerThreshold < 0 {
+		return Config{}, fmt.Errorf("invalid RAG_BREAKER_THRESHOLD: %d", cfg.RAG.BreakerThreshold)
+	}
+	if cfg.RAG.NeighborWindow < 0 {
+		return Config{}, fmt.Errorf("invalid QUERY_NEIGHBOR_WINDOW: %d", cfg.RAG.NeighborWindow)
+	}
+	if cfg.Embed.BatchSize <= 0 {
+		return Config{}, fmt.Errorf("invalid EMBED_BATCH_SIZE: %d", cfg.Embed.BatchSize)
+	}
+	if cfg.Embed.Concurrency <= 0 {
+		return Config{}, fmt.Errorf("invalid EMBED_CONCURRENCY: %d", cfg.Embed.Concurrency)
+	}
+	if cfg.Vector.Backend != "rag" && cfg.Vector.Backend != "embedded" {
+		return Config{}, fmt.Errorf("invalid VECTOR_BACKEND: %q (want rag or embedded)", cfg.Vector.Backend)
+	}
+	if cfg.Vector.Store != "postgres" && cfg.Vector.Store != "disk" {
+		return Config{}, fmt.Errorf("invalid VECTOR_STORE: %q (want postgres or disk)", cfg.Vector.Store)
+	}
+	if cfg.Vector.Store == "disk" && cfg.Vector.Dir == "" {
+		return Config{}, fmt.Errorf("VECTOR_DIR is required")
+	}
+	switch cfg.Vector.Embedder {
+	case "hash":
+		if cfg.Vector.HashDims <= 0 {
+			return Config{}, fmt.Errorf("invalid VECTOR_HASH_DIMS: %d", cfg.Vector.HashDims)
+		}
+	case "openai":
+		if cfg.Vector.EmbeddingURL == "" || cfg.Vector.EmbeddingModel == "" {
+			return Config{}, fmt.Errorf("VECTOR_EMBEDDING_URL and VECTOR_EMBEDDING_MODEL are required")
+		}
+		if cfg.Vector.EmbeddingTimeout < 0 {
+			return Config{}, fmt.Errorf("invalid VECTOR_EMBEDDING_TIMEOUT: %s", cfg.Vector.EmbeddingTimeout)
+		}
+	default:
+		return Config{}, fmt.Errorf("invalid VECTOR_EMBEDDER: %q (want hash or openai)", cfg.Vector.Embedder)
+	}
+	if cfg.Vector.M < 2 {
+		return Config{}, fmt.Errorf("invalid VECTOR_HNSW_M: %d", cfg.Vector.M)
+	}
+	if cfg.Vector.EfConstruction <= 0 || cfg.Vector.EfSearch <= 0 {
+		return Config{}, fmt.Errorf("invalid VECTOR_HNSW_EF_CONSTRUCTION or VECTOR_HNSW_EF_SEARCH: %d, %d", cfg.Vector.EfConstruction, cfg.Vector.EfSearch)
+	}
+	if cfg.Extract.Timeout <= 0 {
+		return Config{}, fmt.Errorf("invalid EXTRACT_TIMEOUT: %s", cfg.Extract.Timeout)
+	}
+	if cfg.Extract.MaxOutputBytes < 0 {
+		return Config{}, fmt.Errorf("invalid EXTRACT_MAX_OUTPUT_BYTES: %d", cfg.Extract.MaxOutputBytes)
+	}
+	if cfg.Extract.MemoryLimitBytes < 0 {
+		return Config{}, fmt.Errorf("invalid EXTRACT_MEMORY_LIMIT_BYTES: %d", cfg.Extract.MemoryLimitBytes)
+	}
+	if cfg.Chunk.Size <= 0 {
+		return Config{}, fmt.Errorf("invalid CHUNK_SIZE: %d", cfg.Chunk.Size)
+	}
+	if cfg.Chunk.Overlap < 0 || cfg.Chunk.Overlap >= cfg.Chunk.Size {
+		return Config{}, fmt.Errorf("invalid CHUNK_OVERLAP: %d (must be below CHUNK_SIZE)", cfg.Chunk.Overlap)
+	}
+	switch cfg.Tracing.Exporter {
+	case "none", "otlp", "console":
+	case "file":
+		if cfg.Tracing.File == "" {
+			return Config{}, fmt.Errorf("OTEL_TRACES_FILE is required")
+		}
+	default:
+		return Config{}, fmt.Errorf("invalid OTEL_TRACES_EXPORTER: %q (want none, otlp, console or file)", cfg.Tracing.Exporter)
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		return Config{}, fmt.Errorf("invalid OTEL_TRACES_SAMPLER_ARG: %g (want 0 to 1)", cfg.Tracing.SampleRatio)
+	}
 
 	return cfg, nil
 }
@@ -151,3 +468,27 @@ func getenvInt64Default(key string, def int64) int64 {
 	}
 	return n
 }
+
+func getenvBoolDefault(key string, def bool) bool {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+func getenvFloatDefault(key string, def float64) float64 {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"docsense/api/internal/adapters/config"
+
+	"github.com/XSAM/otelsql"
+	_ "github.com/lib/pq"
 )
 
 // OpenPostgres opens a Postgres connection pool using database/sql.
@@ -24,7 +27,7 @@ func Open(cfg config.Config) (*sql.DB, error) {
 		cfg.Postgres.SSLMode,
 	)
 
-	db, err := sql.Open("postgres", dsn)
+	db, err := otelsql.Open("postgres", dsn, tracingOptions()...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	"github.com/XSAM/otelsql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingOptions configure otelsql to record a client span for each
+// statement run on behalf of a traced request, named after its operation
+// ("db SELECT"). Statements outside one (startup, background jobs) and
+// connection housekeeping are not traced.
+func tracingOptions() []otelsql.Option {
+	return []otelsql.Option{
+		otelsql.WithAttributes(semconv.DBSystemNamePostgreSQL),
+		otelsql.WithSpanNameFormatter(func(_ context.Context, method otelsql.Method, query string) string {
+			if op := operation(query); op != "" {
+				return "db " + op
+			}
+			return string(method)
+		}),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitConnPrepare:      true,
+			OmitRows:             true,
+			OmitConnectorConnect: true,
+			SpanFilter:           traceStatement,
+		}),
+	}
+}
+
+// traceStatement decides whether a statement gets a span: only within a
+// recorded trace, and for lib/pq's COPY statements (pq.CopyIn) only the
+// final, argument-free Exec that sends the buffered rows.
+func traceStatement(ctx context.Context, method otelsql.Method, query string, args []driver.NamedValue) bool {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return false
+	}
+	return method != otelsql.MethodStmtExec || operation(query) != "COPY" || len(args) == 0
+}
+
+// operation is the statement's leading keyword, upper-cased.
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
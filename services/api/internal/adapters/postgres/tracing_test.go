@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceStatement(t *testing.T) {
+	tp := trace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+	traced, span := tp.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+	untraced := oteltrace.ContextWithSpanContext(context.Background(), span.SpanContext())
+
+	arg := []driver.NamedValue{{Ordinal: 1, Value: "x"}}
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		method otelsql.Method
+		query  string
+		args   []driver.NamedValue
+		want   bool
+	}{
+		{"query in a recorded trace", traced, otelsql.MethodConnQuery, "SELECT 1", arg, true},
+		{"query outside a trace", context.Background(), otelsql.MethodConnQuery, "SELECT 1", nil, false},
+		{"query in an unrecorded trace", untraced, otelsql.MethodConnQuery, "SELECT 1", nil, false},
+		{"copy row", traced, otelsql.MethodStmtExec, `COPY "t" ("a") FROM STDIN`, arg, false},
+		{"copy flush", traced, otelsql.MethodStmtExec, `copy "t" ("a") FROM STDIN`, nil, true},
+		{"prepared insert", traced, otelsql.MethodStmtExec, "INSERT INTO t VALUES ($1)", arg, true},
+	}
+	for _, tt := range tests {
+		if got := traceStatement(tt.ctx, tt.method, tt.query, tt.args); got != tt.want {
+			t.Errorf("%s: traceStatement = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOperation(t *testing.T) {
+	for query, want := range map[string]string{
+		"\n\t\tselect id\n\t\tFROM documents": "SELECT",
+		"UPDATE documents SET status = $1":    "UPDATE",
+		"   ":                                 "",
+	} {
+		if got := operation(query); got != want {
+			t.Errorf("operation(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package rag
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	b := newBreaker(2, cooldown)
+	steps := []struct {
+		name string
+		do   func()
+		want bool // allow() afterwards
+	}{
+		{"closed", func() {}, true},
+		{"one failure", func() { b.record(false) }, true},
+		{"success resets", func() { b.record(true) }, true},
+		{"one failure again", func() { b.record(false) }, true},
+		{"threshold opens", func() { b.record(false) }, false},
+		{"open during cooldown", func() {}, false},
+		{"probe after cooldown", func() { time.Sleep(cooldown) }, true},
+		{"one probe at a time", func() {}, false},
+		{"failed probe reopens", func() { b.record(false) }, false},
+		{"probe again", func() { time.Sleep(cooldown) }, true},
+		{"abandoned probe frees the slot", func() { b.abandon() }, true},
+		{"successful probe closes", func() { b.record(true) }, true},
+		{"closed allows concurrent calls", func() {}, true},
+	}
+	for _, s := range steps {
+		s.do()
+		if got := b.allow(); got != s.want {
+			t.Fatalf("%s: allow() = %v, want %v", s.name, got, s.want)
+		}
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := newBreaker(0, time.Minute)
+	for range 10 {
+		b.record(false)
+	}
+	if !b.allow() {
+		t.Error("a disabled breaker opened")
+	}
+}
@@ -4,27 +4,92 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"docsense/api/internal/adapters/config"
+	"docsense/api/internal/metrics"
+	"docsense/api/internal/requestctx"
+	"docsense/api/internal/tracing"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Client provides HTTP client for RAG service communication.
+//
+// Calls are retried and guarded by a circuit breaker; errors wrap
+// ErrUnavailable or ErrBadRequest so callers can tell the two apart.
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+
+	// Per-call settings: embedding, retrieval, answer generation, and
+	// index maintenance (payloads and points).
+	embed, query, generate, admin callOptions
+
+	maxAttempts  int
+	retryBackoff time.Duration
+	maxBackoff   time.Duration
+	breaker      *breaker
+
+	// Requests are signed with signingKey unless it is empty; see sign.
+	signingKeyID string
+	signingKey   []byte
+
+	// owner resolves the users owning embedded documents, which the
+	// service stores with their chunks to filter retrieval by.
+	owner OwnerFunc
+}
+
+// OwnerFunc returns the ID of the user owning a document.
+type OwnerFunc func(ctx context.Context, documentID string) (string, error)
+
+// callOptions configures one kind of call.
+type callOptions struct {
+	// timeout bounds each attempt; 0 means no limit.
+	timeout time.Duration
+	// idempotent calls may be retried after the request reached the
+	// service.
+	idempotent bool
 }
 
-// NewClient creates a new RAG service client.
-func NewClient(cfg config.RAGConfig) *Client {
+// NewClient creates a new RAG service client. owner resolves the users
+// owning embedded documents.
+func NewClient(cfg config.RAGConfig, owner OwnerFunc) *Client {
 	return &Client{
 		baseURL: cfg.BaseURL,
-		httpClient: &http.Client{
-			Timeout: cfg.Timeout,
-		},
+		// Timeouts are per call; see callOptions. Each attempt is a client
+		// span, whose trace context the request carries.
+		httpClient: &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport,
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method + " " + r.URL.Path
+			}),
+		)},
+		// Embedding upserts by chunk ID and retrieval only reads, so both
+		// can be repeated. Generation calls an LLM, which is slow and
+		// billed, so it is only retried if the request never reached the
+		// service.
+		embed:        callOptions{timeout: cfg.EmbedTimeout, idempotent: true},
+		query:        callOptions{timeout: cfg.QueryTimeout, idempotent: true},
+		generate:     callOptions{timeout: cfg.GenerateTimeout},
+		admin:        callOptions{timeout: cfg.Timeout, idempotent: true},
+		maxAttempts:  max(cfg.MaxAttempts, 1),
+		retryBackoff: cfg.RetryBackoff,
+		maxBackoff:   cfg.MaxRetryBackoff,
+		breaker:      newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		signingKeyID: cfg.SigningKeyID,
+		signingKey:   []byte(cfg.SigningKey),
+		owner:        owner,
 	}
 }
 
@@ -33,23 +98,76 @@ type ChunkIn struct {
 	ChunkID    string `json:"chunk_id"`
 	ChunkIndex int    `json:"chunk_index"`
 	Text       string `json:"text"`
+
+	// Segment span (e.g. slides 3-4) and the title of its first segment;
+	// omitted for unstructured documents.
+	SegmentKind  string `json:"segment_kind,omitempty"`
+	SegmentStart int    `json:"segment_start,omitempty"`
+	SegmentEnd   int    `json:"segment_end,omitempty"`
+	SegmentTitle string `json:"segment_title,omitempty"`
+
+	// SectionPath is the heading breadcrumb of the chunk's section.
+	SectionPath []string `json:"section_path,omitempty"`
 }
 
 // EmbedRequest is the request payload for embedding chunks.
 type EmbedRequest struct {
 	DocumentID string    `json:"document_id"`
+	UserID     string    `json:"user_id,omitempty"`
 	Chunks     []ChunkIn `json:"chunks"`
 }
 
 // EmbedResponse is the response from embedding endpoint.
 type EmbedResponse struct {
 	Upserted int `json:"upserted"`
+	// PointIDs are the IDs of the upserted vector points, which are the
+	// chunk IDs.
+	PointIDs []string `json:"point_ids"`
+}
+
+// UpdatePayloadsResponse is the response from the update payloads endpoint.
+type UpdatePayloadsResponse struct {
+	Updated int `json:"updated"`
+}
+
+// Point is an indexed vector point.
+type Point struct {
+	ID         string  `json:"id"`
+	DocumentID *string `json:"document_id"`
+}
+
+// PointsResponse is a page of indexed points.
+type PointsResponse struct {
+	Points []Point `json:"points"`
+	// NextOffset fetches the next page; nil after the last page.
+	NextOffset *string `json:"next_offset"`
+}
+
+// DeletePointsRequest is the request payload for deleting points.
+type DeletePointsRequest struct {
+	PointIDs []string `json:"point_ids"`
+}
+
+// DeletePointsResponse is the response from the delete points endpoint.
+type DeletePointsResponse struct {
+	Deleted int `json:"deleted"`
 }
 
 // QueryRequest is the request payload for query endpoint.
 type QueryRequest struct {
 	Query string `json:"query"`
 	TopK  int    `json:"top_k"`
+
+	// Optional filter; see Filter.
+	UserID      string   `json:"user_id,omitempty"`
+	DocumentIDs []string `json:"document_ids,omitempty"`
+}
+
+// Filter restricts retrieval to a user's documents and, if DocumentIDs is
+// set, to those documents.
+type Filter struct {
+	UserID      string
+	DocumentIDs []string
 }
 
 // Citation represents a source citation.
@@ -58,95 +176,386 @@ type Citation struct {
 	DocumentID  *string `json:"document_id"`
 	ChunkIndex  *int    `json:"chunk_index"`
 	TextSnippet *string `json:"text_snippet"`
+
+	SegmentKind  *string `json:"segment_kind"`
+	SegmentStart *int    `json:"segment_start"`
+	SegmentEnd   *int    `json:"segment_end"`
+	SegmentTitle *string `json:"segment_title"`
+
+	SectionPath []string `json:"section_path"`
+}
+
+// PageSpan returns the cited page range when the citation comes from a
+// paginated document (PDF).
+func (c Citation) PageSpan() (start, end int, ok bool) {
+	if c.SegmentKind == nil || *c.SegmentKind != "page" || c.SegmentStart == nil {
+		return 0, 0, false
+	}
+	start, end = *c.SegmentStart, *c.SegmentStart
+	if c.SegmentEnd != nil && *c.SegmentEnd > start {
+		end = *c.SegmentEnd
+	}
+	return start, end, true
 }
 
-// RetrievedChunkOut represents a retrieved chunk from query.
+// RetrievedChunkOut represents a retrieved chunk from query. The chunk's
+// location is only returned by Retrieve.
 type RetrievedChunkOut struct {
 	ID         string  `json:"id"`
 	Score      float64 `json:"score"`
 	DocumentID *string `json:"document_id"`
 	Text       *string `json:"text"`
+
+	ChunkIndex   *int     `json:"chunk_index,omitempty"`
+	SegmentKind  *string  `json:"segment_kind,omitempty"`
+	SegmentStart *int     `json:"segment_start,omitempty"`
+	SegmentEnd   *int     `json:"segment_end,omitempty"`
+	SegmentTitle *string  `json:"segment_title,omitempty"`
+	SectionPath  []string `json:"section_path,omitempty"`
+}
+
+// Match returns the chunk as a retrieval result of a document.
+func (ch ChunkIn) Match(documentID string, score float64) RetrievedChunkOut {
+	text, index := ch.Text, ch.ChunkIndex
+	m := RetrievedChunkOut{ID: ch.ChunkID, Score: score, DocumentID: &documentID, Text: &text, ChunkIndex: &index, SectionPath: ch.SectionPath}
+	if ch.SegmentKind != "" {
+		kind, start, end := ch.SegmentKind, ch.SegmentStart, ch.SegmentEnd
+		m.SegmentKind, m.SegmentStart, m.SegmentEnd = &kind, &start, &end
+	}
+	if ch.SegmentTitle != "" {
+		title := ch.SegmentTitle
+		m.SegmentTitle = &title
+	}
+	return m
+}
+
+// RetrieveResponse is the response from the retrieve endpoint.
+type RetrieveResponse struct {
+	Matches []RetrievedChunkOut `json:"matches"`
+}
+
+// ContextChunk is a passage to answer from: a retrieved chunk, possibly
+// expanded to its parent or neighbours. Citations refer to ChunkID.
+type ContextChunk struct {
+	ChunkID    string  `json:"chunk_id"`
+	Text       string  `json:"text"`
+	Score      float64 `json:"score"`
+	DocumentID *string `json:"document_id,omitempty"`
+	ChunkIndex *int    `json:"chunk_index,omitempty"`
+
+	SegmentKind  *string  `json:"segment_kind,omitempty"`
+	SegmentStart *int     `json:"segment_start,omitempty"`
+	SegmentEnd   *int     `json:"segment_end,omitempty"`
+	SegmentTitle *string  `json:"segment_title,omitempty"`
+	SectionPath  []string `json:"section_path,omitempty"`
+}
+
+// GenerateRequest is the request payload for the generate endpoint.
+type GenerateRequest struct {
+	Query    string         `json:"query"`
+	Contexts []ContextChunk `json:"contexts"`
+}
+
+// GenerateResponse is the response from the generate endpoint.
+type GenerateResponse struct {
+	Answer    string     `json:"answer"`
+	Citations []Citation `json:"citations"`
 }
 
 // QueryResponse is the response from query endpoint.
 type QueryResponse struct {
-	Answer    string             `json:"answer"`
-	Citations []Citation         `json:"citations"`
+	Answer    string              `json:"answer"`
+	Citations []Citation          `json:"citations"`
 	Matches   []RetrievedChunkOut `json:"matches"`
 }
 
-// EmbedChunks sends chunks to the RAG service for embedding and indexing.
-func (c *Client) EmbedChunks(ctx context.Context, documentID string, chunks []ChunkIn) (int, error) {
-	reqBody := EmbedRequest{
-		DocumentID: documentID,
-		Chunks:     chunks,
+// EmbedChunks sends chunks to the RAG service for embedding and indexing. It
+// returns the IDs of the upserted points.
+func (c *Client) EmbedChunks(ctx context.Context, documentID string, chunks []ChunkIn) ([]string, error) {
+	req, err := c.embedRequest(ctx, documentID, chunks)
+	if err != nil {
+		return nil, fmt.Errorf("embed: %w", err)
 	}
+	var out EmbedResponse
+	if err := c.do(ctx, c.embed, "POST", "/embed", req, &out); err != nil {
+		return nil, fmt.Errorf("embed: %w", err)
+	}
+	return out.PointIDs, nil
+}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return 0, fmt.Errorf("marshal embed request: %w", err)
+// Query sends a query to the RAG service and returns the answer with citations.
+func (c *Client) Query(ctx context.Context, query string, topK int) (*QueryResponse, error) {
+	var out QueryResponse
+	if err := c.do(ctx, c.generate, "POST", "/query", QueryRequest{Query: query, TopK: topK}, &out); err != nil {
+		return nil, fmt.Errorf("query: %w", err)
 	}
+	return &out, nil
+}
 
-	url := c.baseURL + "/embed"
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return 0, fmt.Errorf("create embed request: %w", err)
+// Retrieve returns the chunks most similar to query, without generating an
+// answer.
+func (c *Client) Retrieve(ctx context.Context, query string, topK int) (*RetrieveResponse, error) {
+	return c.RetrieveFiltered(ctx, query, topK, Filter{})
+}
+
+// RetrieveFiltered is Retrieve restricted to the chunks filter allows. The
+// service filters while searching, so topK matches are returned whenever
+// that many pass the filter.
+func (c *Client) RetrieveFiltered(ctx context.Context, query string, topK int, filter Filter) (*RetrieveResponse, error) {
+	req := QueryRequest{Query: query, TopK: topK, UserID: filter.UserID, DocumentIDs: filter.DocumentIDs}
+	var out RetrieveResponse
+	if err := c.do(ctx, c.query, "POST", "/retrieve", req, &out); err != nil {
+		return nil, fmt.Errorf("retrieve: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return &out, nil
+}
 
-	resp, err := c.httpClient.Do(req)
+// Generate answers query from the given passages.
+func (c *Client) Generate(ctx context.Context, query string, contexts []ContextChunk) (*GenerateResponse, error) {
+	var out GenerateResponse
+	if err := c.do(ctx, c.generate, "POST", "/generate", GenerateRequest{Query: query, Contexts: contexts}, &out); err != nil {
+		return nil, fmt.Errorf("generate: %w", err)
+	}
+	return &out, nil
+}
+
+// GenerateStream is Generate with the answer streamed: onDelta is called
+// with each piece of the answer as the service produces it, and the
+// response has the whole answer and the citations. Canceling ctx stops
+// generation. An error from onDelta ends the stream and is returned.
+func (c *Client) GenerateStream(ctx context.Context, query string, contexts []ContextChunk, onDelta func(string) error) (*GenerateResponse, error) {
+	var out GenerateResponse
+	read := func(body io.Reader) error {
+		var answer strings.Builder
+		dec := json.NewDecoder(body)
+		for {
+			var line generateStreamLine
+			if err := dec.Decode(&line); err != nil {
+				if errors.Is(err, io.EOF) {
+					err = io.ErrUnexpectedEOF
+				}
+				return &unavailableError{fmt.Errorf("read stream: %w", err)}
+			}
+			switch {
+			case line.Error != "":
+				return &unavailableError{fmt.Errorf("stream failed: %s", line.Error)}
+			case line.Citations != nil:
+				out.Answer, out.Citations = answer.String(), *line.Citations
+				return nil
+			case line.Delta != "":
+				answer.WriteString(line.Delta)
+				if err := onDelta(line.Delta); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := c.do(ctx, c.generate, "POST", "/generate/stream", GenerateRequest{Query: query, Contexts: contexts}, read); err != nil {
+		return nil, fmt.Errorf("generate: %w", err)
+	}
+	return &out, nil
+}
+
+// generateStreamLine is a line of the /generate/stream response: a piece
+// of the answer, the final citations, or an error that ended generation.
+type generateStreamLine struct {
+	Delta     string      `json:"delta"`
+	Citations *[]Citation `json:"citations"`
+	Error     string      `json:"error"`
+}
+
+// UpdatePayloads replaces the payloads of already embedded chunks (index,
+// segment span, section path) without embedding them again.
+func (c *Client) UpdatePayloads(ctx context.Context, documentID string, chunks []ChunkIn) (int, error) {
+	req, err := c.embedRequest(ctx, documentID, chunks)
 	if err != nil {
-		return 0, fmt.Errorf("execute embed request: %w", err)
+		return 0, fmt.Errorf("update payloads: %w", err)
 	}
-	defer resp.Body.Close()
+	var out UpdatePayloadsResponse
+	if err := c.do(ctx, c.admin, "POST", "/points/payload", req, &out); err != nil {
+		return 0, fmt.Errorf("update payloads: %w", err)
+	}
+	return out.Updated, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return 0, fmt.Errorf("embed request failed with status %d: %s", resp.StatusCode, string(body))
+// embedRequest returns the request embedding or updating chunks of a
+// document, with its owner.
+func (c *Client) embedRequest(ctx context.Context, documentID string, chunks []ChunkIn) (EmbedRequest, error) {
+	req := EmbedRequest{DocumentID: documentID, Chunks: chunks}
+	if c.owner != nil {
+		userID, err := c.owner(ctx, documentID)
+		if err != nil {
+			return req, err
+		}
+		req.UserID = userID
 	}
+	return req, nil
+}
 
-	var embedResp EmbedResponse
-	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
-		return 0, fmt.Errorf("decode embed response: %w", err)
+// ListPoints returns a page of at most limit indexed points, starting at
+// offset ("" for the first page).
+func (c *Client) ListPoints(ctx context.Context, offset string, limit int) (*PointsResponse, error) {
+	q := url.Values{"limit": {strconv.Itoa(limit)}}
+	if offset != "" {
+		q.Set("offset", offset)
+	}
+	var out PointsResponse
+	if err := c.do(ctx, c.admin, "GET", "/points?"+q.Encode(), nil, &out); err != nil {
+		return nil, fmt.Errorf("list points: %w", err)
 	}
+	return &out, nil
+}
 
-	return embedResp.Upserted, nil
+// DeletePoints deletes indexed points by ID.
+func (c *Client) DeletePoints(ctx context.Context, pointIDs []string) (int, error) {
+	var out DeletePointsResponse
+	if err := c.do(ctx, c.admin, "POST", "/points/delete", DeletePointsRequest{PointIDs: pointIDs}, &out); err != nil {
+		return 0, fmt.Errorf("delete points: %w", err)
+	}
+	return out.Deleted, nil
 }
 
-// Query sends a query to the RAG service and returns the answer with citations.
-func (c *Client) Query(ctx context.Context, query string, topK int) (*QueryResponse, error) {
-	reqBody := QueryRequest{
-		Query: query,
-		TopK:  topK,
+// do sends a request to path, with in as the JSON body unless it is nil, and
+// decodes the JSON response into out, or passes the response body to out
+// if it is a func(io.Reader) error. Each attempt is bounded by the call's
+// timeout. Failures that leave the service unavailable (connection errors,
+// timeouts, 5xx and 429 responses) are retried with jittered exponential
+// backoff, up to c.maxAttempts attempts; once the request may have reached
+// the service, only idempotent calls are retried. Calls fail fast while the
+// circuit breaker is open. Each call is a span, the parent of its
+// attempts' spans; retries are recorded as span events. Calls are
+// timed and failures counted per operation (the path, e.g. "points_delete").
+func (c *Client) do(ctx context.Context, call callOptions, method, path string, in, out any) (err error) {
+	route, _, _ := strings.Cut(path, "?")
+	operation := strings.ReplaceAll(strings.TrimPrefix(route, "/"), "/", "_")
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "rag "+method+" "+route,
+		trace.WithAttributes(
+			attribute.String("http.request.method", method),
+			attribute.String("url.full", c.baseURL+path),
+		),
+	)
+	defer func() {
+		metrics.RAGRequestDuration.WithLabelValues(operation).Observe(metrics.Since(start))
+		// A caller giving up is not a failure of the service.
+		if err != nil && ctx.Err() == nil {
+			metrics.RAGErrors.WithLabelValues(operation, errorKind(err)).Inc()
+		}
+		tracing.End(span, err)
+	}()
+
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+		span.SetAttributes(attribute.Int("http.request.body.size", len(body)))
 	}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("marshal query request: %w", err)
+	for attempt := 1; ; attempt++ {
+		if !c.breaker.allow() {
+			return ErrCircuitOpen
+		}
+		span.SetAttributes(attribute.Int("rag.attempts", attempt))
+		sent, err := c.attempt(ctx, call.timeout, method, path, body, out)
+		var se *StatusError
+		if errors.As(err, &se) {
+			span.SetAttributes(attribute.Int("http.response.status_code", se.StatusCode))
+		} else if err == nil {
+			span.SetAttributes(attribute.Int("http.response.status_code", http.StatusOK))
+		}
+		if ctx.Err() != nil {
+			// The caller gave up, which says nothing about the service.
+			c.breaker.abandon()
+			return err
+		}
+		c.breaker.record(!errors.Is(err, ErrUnavailable))
+		if err == nil || !errors.Is(err, ErrUnavailable) {
+			return err
+		}
+		if attempt >= c.maxAttempts || (sent && !call.idempotent) {
+			return err
+		}
+		span.AddEvent("retry", trace.WithAttributes(attribute.Int("rag.attempt", attempt), attribute.String("error", err.Error())))
+		select {
+		case <-time.After(c.backoff(attempt)):
+		case <-ctx.Done():
+			return err
+		}
 	}
+}
 
-	url := c.baseURL + "/query"
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+// errorKind classifies a failed call for metrics.
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, ErrUnavailable):
+		return "unavailable"
+	case errors.Is(err, ErrBadRequest):
+		return "bad_request"
+	default:
+		return "other"
+	}
+}
+
+// attempt sends one request. sent reports whether the request may have
+// reached the service (it was not refused while connecting).
+func (c *Client) attempt(ctx context.Context, timeout time.Duration, method, path string, body []byte, out any) (sent bool, err error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
 	if err != nil {
-		return nil, fmt.Errorf("create query request: %w", err)
+		return false, fmt.Errorf("create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	requestctx.Inject(ctx, req.Header)
+	if err := c.sign(req, body); err != nil {
+		return false, fmt.Errorf("sign request: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("execute query request: %w", err)
+		var opErr *net.OpError
+		sent = !(errors.As(err, &opErr) && opErr.Op == "dial")
+		return sent, &unavailableError{fmt.Errorf("execute request: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("query request failed with status %d: %s", resp.StatusCode, string(body))
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return true, &StatusError{StatusCode: resp.StatusCode, Body: string(msg)}
 	}
-
-	var queryResp QueryResponse
-	if err := json.NewDecoder(resp.Body).Decode(&queryResp); err != nil {
-		return nil, fmt.Errorf("decode query response: %w", err)
+	if read, ok := out.(func(io.Reader) error); ok {
+		return true, read(resp.Body)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return true, fmt.Errorf("decode response: %w", err)
 	}
+	return true, nil
+}
 
-	return &queryResp, nil
+// backoff returns the wait before retrying after the given attempt: a
+// random duration up to c.retryBackoff doubled per attempt, capped at
+// c.maxBackoff ("full jitter").
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.retryBackoff << (attempt - 1)
+	if d <= 0 || d > c.maxBackoff {
+		d = c.maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d)
 }
@@ -0,0 +1,131 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"docsense/api/internal/adapters/config"
+)
+
+func TestStatusErrorClass(t *testing.T) {
+	tests := []struct {
+		status                  int
+		unavailable, badRequest bool
+	}{
+		{http.StatusInternalServerError, true, false},
+		{http.StatusServiceUnavailable, true, false},
+		{http.StatusTooManyRequests, true, false},
+		{http.StatusBadRequest, false, true},
+		{http.StatusUnprocessableEntity, false, true},
+		{http.StatusUnauthorized, false, false},
+		{http.StatusForbidden, false, false},
+	}
+	for _, tt := range tests {
+		err := error(&StatusError{StatusCode: tt.status})
+		if errors.Is(err, ErrUnavailable) != tt.unavailable || errors.Is(err, ErrBadRequest) != tt.badRequest {
+			t.Errorf("status %d: unavailable=%v bad request=%v, want %v %v", tt.status,
+				errors.Is(err, ErrUnavailable), errors.Is(err, ErrBadRequest), tt.unavailable, tt.badRequest)
+		}
+	}
+}
+
+// newTestClient returns a client of a server answering with statuses in
+// turn (200 with body once they run out) and the number of requests served.
+func newTestClient(t *testing.T, cfg config.RAGConfig, body string, statuses ...int) (*Client, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	cfg.BaseURL = srv.URL
+	cfg.RetryBackoff, cfg.MaxRetryBackoff = time.Millisecond, time.Millisecond
+	return NewClient(cfg, nil), &calls
+}
+
+func TestClientRetries(t *testing.T) {
+	const matches = `{"matches":[{"id":"c1","score":0.5}]}`
+	const answer = `{"answer":"a","citations":[]}`
+	tests := []struct {
+		name      string
+		statuses  []int
+		generate  bool
+		wantCalls int32
+		wantErr   error
+	}{
+		{"success", nil, false, 1, nil},
+		{"retried until success", []int{503, 502}, false, 3, nil},
+		{"gives up after max attempts", []int{503, 503, 503, 503}, false, 3, ErrUnavailable},
+		{"bad request is not retried", []int{400}, false, 1, ErrBadRequest},
+		{"generation is not retried once sent", []int{503}, true, 1, ErrUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := matches
+			if tt.generate {
+				body = answer
+			}
+			c, calls := newTestClient(t, config.RAGConfig{MaxAttempts: 3}, body, tt.statuses...)
+			var err error
+			if tt.generate {
+				_, err = c.Generate(context.Background(), "q", nil)
+			} else {
+				var resp *RetrieveResponse
+				resp, err = c.Retrieve(context.Background(), "q", 5)
+				if err == nil && (len(resp.Matches) != 1 || resp.Matches[0].ID != "c1") {
+					t.Errorf("matches = %+v", resp.Matches)
+				}
+			}
+			if (tt.wantErr == nil) != (err == nil) || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("%d requests, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestClientBreaker(t *testing.T) {
+	c, calls := newTestClient(t, config.RAGConfig{MaxAttempts: 1, BreakerThreshold: 2, BreakerCooldown: time.Hour}, `{"matches":[]}`, 500, 500)
+	for range 2 {
+		if _, err := c.Retrieve(context.Background(), "q", 5); !errors.Is(err, ErrUnavailable) || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("err = %v, want a service failure", err)
+		}
+	}
+	if _, err := c.Retrieve(context.Background(), "q", 5); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("%d requests, want 2 (none while open)", got)
+	}
+}
+
+func TestClientCanceledCallDoesNotTripBreaker(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server notices the client hanging up once the body is read.
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	c := NewClient(config.RAGConfig{BaseURL: srv.URL, MaxAttempts: 3, BreakerThreshold: 1, BreakerCooldown: time.Hour}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Retrieve(ctx, "q", 5); err == nil {
+		t.Fatal("Retrieve succeeded")
+	}
+	if !c.breaker.allow() {
+		t.Error("a call the caller gave up on opened the breaker")
+	}
+}
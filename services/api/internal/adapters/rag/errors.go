@@ -0,0 +1,58 @@
+package rag
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrUnavailable is wrapped by errors of calls that failed because the
+	// RAG service could not serve them: it could not be reached, timed out,
+	// or answered with a 5xx or 429 status, even after retries.
+	ErrUnavailable = errors.New("rag service unavailable")
+
+	// ErrCircuitOpen is returned without calling the service while the
+	// circuit breaker is open after repeated failures. It wraps
+	// ErrUnavailable.
+	ErrCircuitOpen = fmt.Errorf("%w: circuit breaker open", ErrUnavailable)
+
+	// ErrBadRequest is wrapped by errors of calls the RAG service rejected
+	// (4xx other than 429); retrying them will not help.
+	ErrBadRequest = errors.New("rag service rejected request")
+)
+
+// StatusError is a response with a status other than 200 OK.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// Unwrap classifies the status as ErrUnavailable or ErrBadRequest. 401
+// and 403 (a request signature the service rejected) are neither: they are
+// configuration errors, not the caller's.
+func (e *StatusError) Unwrap() error {
+	if e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests {
+		return ErrUnavailable
+	}
+	if e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden {
+		return nil
+	}
+	if e.StatusCode >= 400 {
+		return ErrBadRequest
+	}
+	return nil
+}
+
+// unavailableError is a transport failure (connection error or timeout).
+type unavailableError struct {
+	err error
+}
+
+func (e *unavailableError) Error() string { return e.err.Error() }
+
+func (e *unavailableError) Unwrap() []error { return []error{ErrUnavailable, e.err} }
@@ -0,0 +1,305 @@
+package rag
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// fakeDims is the dimension of the fake's hashed bag-of-words vectors.
+	fakeDims = 1024
+	// fakeAnswerSentences is the most sentences an extractive answer has.
+	fakeAnswerSentences = 3
+	// fakeSnippetRunes bounds citation snippets.
+	fakeSnippetRunes = 240
+)
+
+// Fake is an in-process stand-in for the RAG service, for development and
+// tests without the Python service, Qdrant or an LLM (RAG_MODE=fake).
+//
+// Chunks are embedded as hashed bag-of-words vectors and kept in memory;
+// answers are the query-relevant sentences of the passages. It is
+// deterministic, and its index is lost on restart (`api reconcile`
+// re-embeds the chunks).
+type Fake struct {
+	mu     sync.RWMutex
+	points map[string]fakePoint
+}
+
+type fakePoint struct {
+	documentID string
+	chunk      ChunkIn
+	vector     []float64
+}
+
+// NewFake returns an empty Fake.
+func NewFake() *Fake {
+	return &Fake{points: make(map[string]fakePoint)}
+}
+
+// EmbedChunks indexes chunks in memory.
+func (f *Fake) EmbedChunks(_ context.Context, documentID string, chunks []ChunkIn) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := make([]string, len(chunks))
+	for i, ch := range chunks {
+		f.points[ch.ChunkID] = fakePoint{documentID: documentID, chunk: ch, vector: fakeVector(ch.Text)}
+		ids[i] = ch.ChunkID
+	}
+	return ids, nil
+}
+
+// UpdatePayloads replaces the metadata of indexed chunks, keeping their
+// vectors.
+func (f *Fake) UpdatePayloads(_ context.Context, documentID string, chunks []ChunkIn) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, ch := range chunks {
+		p, ok := f.points[ch.ChunkID]
+		if !ok {
+			continue
+		}
+		p.documentID, p.chunk = documentID, ch
+		f.points[ch.ChunkID] = p
+		n++
+	}
+	return n, nil
+}
+
+// ListPoints pages through indexed chunks in ID order; offsets are point
+// IDs.
+func (f *Fake) ListPoints(_ context.Context, offset string, limit int) (*PointsResponse, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	ids := make([]string, 0, len(f.points))
+	for id := range f.points {
+		if id >= offset {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	out := &PointsResponse{Points: []Point{}}
+	for i, id := range ids {
+		if i == limit {
+			next := id
+			out.NextOffset = &next
+			break
+		}
+		documentID := f.points[id].documentID
+		out.Points = append(out.Points, Point{ID: id, DocumentID: &documentID})
+	}
+	return out, nil
+}
+
+// DeletePoints removes chunks from the index.
+func (f *Fake) DeletePoints(_ context.Context, pointIDs []string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range pointIDs {
+		delete(f.points, id)
+	}
+	return len(pointIDs), nil
+}
+
+// Retrieve returns the topK chunks whose vectors are most similar to the
+// query's, best first.
+func (f *Fake) Retrieve(_ context.Context, query string, topK int) (*RetrieveResponse, error) {
+	q := fakeVector(query)
+
+	f.mu.RLock()
+	matches := make([]RetrievedChunkOut, 0, len(f.points))
+	for _, p := range f.points {
+		score := 0.0
+		for i, v := range q {
+			score += v * p.vector[i]
+		}
+		if score <= 0 {
+			continue
+		}
+		matches = append(matches, p.chunk.Match(p.documentID, score))
+	}
+	f.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if len(matches) > topK {
+		matches = matches[:topK]
+	}
+	return &RetrieveResponse{Matches: matches}, nil
+}
+
+// Generate answers extractively: the passages' sentences sharing the most
+// words with the query, in passage order, citing the passages they come
+// from.
+func (f *Fake) Generate(_ context.Context, query string, contexts []ContextChunk) (*GenerateResponse, error) {
+	terms := make(map[string]bool)
+	for _, w := range fakeWords(query) {
+		terms[w] = true
+	}
+
+	type sentence struct {
+		text    string
+		context int
+		pos     int
+		score   int
+	}
+	var sentences []sentence
+	for ci, c := range contexts {
+		for si, s := range fakeSentences(c.Text) {
+			seen := make(map[string]bool)
+			for _, w := range fakeWords(s) {
+				if terms[w] {
+					seen[w] = true
+				}
+			}
+			if len(seen) > 0 {
+				sentences = append(sentences, sentence{text: s, context: ci, pos: si, score: len(seen)})
+			}
+		}
+	}
+	if len(sentences) == 0 {
+		return &GenerateResponse{Answer: "No relevant passages found.", Citations: []Citation{}}, nil
+	}
+
+	sort.SliceStable(sentences, func(i, j int) bool { return sentences[i].score > sentences[j].score })
+	if len(sentences) > fakeAnswerSentences {
+		sentences = sentences[:fakeAnswerSentences]
+	}
+	sort.Slice(sentences, func(i, j int) bool {
+		if sentences[i].context != sentences[j].context {
+			return sentences[i].context < sentences[j].context
+		}
+		return sentences[i].pos < sentences[j].pos
+	})
+
+	var (
+		answer    []string
+		citations = []Citation{}
+		cited     = make(map[int]bool)
+	)
+	for _, s := range sentences {
+		answer = append(answer, s.text)
+		if cited[s.context] {
+			continue
+		}
+		cited[s.context] = true
+		citations = append(citations, fakeCitation(contexts[s.context], s.text))
+	}
+	return &GenerateResponse{Answer: strings.Join(answer, " "), Citations: citations}, nil
+}
+
+// GenerateStream is Generate with the answer passed to onDelta word by
+// word.
+func (f *Fake) GenerateStream(ctx context.Context, query string, contexts []ContextChunk, onDelta func(string) error) (*GenerateResponse, error) {
+	resp, err := f.Generate(ctx, query, contexts)
+	if err != nil {
+		return nil, err
+	}
+	for _, word := range strings.SplitAfter(resp.Answer, " ") {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := onDelta(word); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func fakeCitation(c ContextChunk, snippet string) Citation {
+	if r := []rune(snippet); len(r) > fakeSnippetRunes {
+		snippet = string(r[:fakeSnippetRunes]) + "…"
+	}
+	return Citation{
+		ChunkID:      c.ChunkID,
+		DocumentID:   c.DocumentID,
+		ChunkIndex:   c.ChunkIndex,
+		TextSnippet:  &snippet,
+		SegmentKind:  c.SegmentKind,
+		SegmentStart: c.SegmentStart,
+		SegmentEnd:   c.SegmentEnd,
+		SegmentTitle: c.SegmentTitle,
+		SectionPath:  c.SectionPath,
+	}
+}
+
+// fakeVector embeds text as an L2-normalized bag of its words, each word
+// hashed to a dimension.
+func fakeVector(text string) []float64 {
+	v := make([]float64, fakeDims)
+	for _, w := range fakeWords(text) {
+		h := fnv.New32a()
+		h.Write([]byte(w))
+		v[h.Sum32()%fakeDims]++
+	}
+	norm := 0.0
+	for _, x := range v {
+		norm += x * x
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range v {
+			v[i] /= norm
+		}
+	}
+	return v
+}
+
+// fakeStopwords are common English words the fake ignores, so that
+// matching favours content words.
+var fakeStopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"can": true, "do": true, "does": true, "for": true, "from": true, "how": true, "i": true, "in": true,
+	"is": true, "it": true, "of": true, "on": true, "or": true, "that": true, "the": true, "this": true,
+	"to": true, "was": true, "what": true, "when": true, "where": true, "which": true, "who": true,
+	"why": true, "with": true, "you": true,
+}
+
+// fakeWords returns the lowercased words of text, without stopwords.
+func fakeWords(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	out := words[:0]
+	for _, w := range words {
+		if !fakeStopwords[w] {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// fakeSentences splits text after sentence-ending punctuation and at line
+// breaks.
+func fakeSentences(text string) []string {
+	var (
+		out   []string
+		start int
+	)
+	runes := []rune(text)
+	for i, r := range runes {
+		end := r == '\n' || (strings.ContainsRune(".!?", r) && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])))
+		if !end {
+			continue
+		}
+		if s := strings.TrimSpace(string(runes[start : i+1])); s != "" {
+			out = append(out, s)
+		}
+		start = i + 1
+	}
+	if s := strings.TrimSpace(string(runes[start:])); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,116 @@
+package rag
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFakeIndex(t *testing.T) {
+	ctx := context.Background()
+	f := NewFake()
+	chunks := []ChunkIn{
+		{ChunkID: "a", ChunkIndex: 0, Text: "Postgres stores the documents and their chunks."},
+		{ChunkID: "b", ChunkIndex: 1, Text: "Qdrant holds the vectors used for retrieval."},
+		{ChunkID: "c", ChunkIndex: 2, Text: "The web app is written in React."},
+	}
+	if ids, err := f.EmbedChunks(ctx, "doc1", chunks); err != nil || !reflect.DeepEqual(ids, []string{"a", "b", "c"}) {
+		t.Fatalf("EmbedChunks = %v, %v", ids, err)
+	}
+
+	resp, err := f.Retrieve(ctx, "Where are vectors stored for retrieval?", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Matches) == 0 || resp.Matches[0].ID != "b" {
+		t.Fatalf("matches = %+v, want b first", resp.Matches)
+	}
+	if m := resp.Matches[0]; *m.DocumentID != "doc1" || *m.ChunkIndex != 1 || *m.Text != chunks[1].Text {
+		t.Errorf("match = %+v", m)
+	}
+	if resp, _ := f.Retrieve(ctx, "kubernetes helm", 5); len(resp.Matches) != 0 {
+		t.Errorf("unrelated query matched %+v", resp.Matches)
+	}
+
+	// Payload updates keep the vector; unknown chunks are skipped.
+	n, err := f.UpdatePayloads(ctx, "doc1", []ChunkIn{{ChunkID: "b", ChunkIndex: 7, Text: "ignored for search"}, {ChunkID: "zzz"}})
+	if err != nil || n != 1 {
+		t.Fatalf("UpdatePayloads = %d, %v", n, err)
+	}
+	resp, _ = f.Retrieve(ctx, "qdrant vectors", 1)
+	if len(resp.Matches) != 1 || resp.Matches[0].ID != "b" || *resp.Matches[0].ChunkIndex != 7 {
+		t.Errorf("after update: %+v", resp.Matches)
+	}
+
+	// Pages of 2 in ID order.
+	var listed []string
+	offset := ""
+	for {
+		page, err := f.ListPoints(ctx, offset, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range page.Points {
+			listed = append(listed, p.ID)
+		}
+		if page.NextOffset == nil {
+			break
+		}
+		offset = *page.NextOffset
+	}
+	if !reflect.DeepEqual(listed, []string{"a", "b", "c"}) {
+		t.Errorf("ListPoints = %v", listed)
+	}
+
+	if _, err := f.DeletePoints(ctx, []string{"b"}); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := f.Retrieve(ctx, "qdrant vectors", 5); len(resp.Matches) != 0 {
+		t.Errorf("deleted chunk still retrieved: %+v", resp.Matches)
+	}
+}
+
+func TestFakeGenerate(t *testing.T) {
+	doc := "doc1"
+	contexts := []ContextChunk{
+		{ChunkID: "a", DocumentID: &doc, Text: "The API is written in Go. It stores files on disk."},
+		{ChunkID: "b", DocumentID: &doc, Text: "Embeddings are computed by the RAG service.\nThe RAG service is written in Python."},
+	}
+	tests := []struct {
+		query, answer string
+		cited         []string
+	}{
+		{"Which language is the API written in?", "The API is written in Go. The RAG service is written in Python.", []string{"a", "b"}},
+		{"Who computes embeddings?", "Embeddings are computed by the RAG service.", []string{"b"}},
+		{"What about kubernetes?", "No relevant passages found.", nil},
+	}
+	for _, tt := range tests {
+		resp, err := NewFake().Generate(context.Background(), tt.query, contexts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cited []string
+		for _, c := range resp.Citations {
+			cited = append(cited, c.ChunkID)
+		}
+		if resp.Answer != tt.answer || !reflect.DeepEqual(cited, tt.cited) {
+			t.Errorf("%q: answer %q citing %v, want %q citing %v", tt.query, resp.Answer, cited, tt.answer, tt.cited)
+		}
+	}
+}
+
+func TestFakeGenerateStream(t *testing.T) {
+	contexts := []ContextChunk{{ChunkID: "a", Text: "Streaming sends the answer word by word."}}
+	var deltas []string
+	resp, err := NewFake().GenerateStream(context.Background(), "how is the answer streamed word by word", contexts, func(d string) error {
+		deltas = append(deltas, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deltas) < 2 || strings.Join(deltas, "") != resp.Answer {
+		t.Errorf("deltas %q do not add up to %q", deltas, resp.Answer)
+	}
+}
@@ -0,0 +1,67 @@
+package rag
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"docsense/api/internal/adapters/config"
+)
+
+// The same vector is checked by services/rag/tests/test_signing.py, so the
+// two sides cannot drift apart.
+const (
+	vectorKey       = "secret-one"
+	vectorMethod    = "POST"
+	vectorURI       = "/retrieve?debug=1"
+	vectorTimestamp = "1700000000"
+	vectorNonce     = "00112233445566778899aabbccddeeff"
+	vectorBody      = `{"query":"q","top_k":5}`
+	vectorSignature = "688e9cc9c2e3e35a233e323e8bc08958456fc48535d3f45ed4fc6b7fb7dca51d"
+)
+
+func TestSignatureVector(t *testing.T) {
+	got := signature([]byte(vectorKey), vectorMethod, vectorURI, vectorTimestamp, vectorNonce, []byte(vectorBody))
+	if got != vectorSignature {
+		t.Errorf("signature = %s, want %s", got, vectorSignature)
+	}
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(vectorBody)
+	newRequest := func() *http.Request {
+		req, err := http.NewRequest(vectorMethod, "http://rag:8000"+vectorURI, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	unsigned := NewClient(config.RAGConfig{}, nil)
+	req := newRequest()
+	if err := unsigned.sign(req, body); err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get(headerSignature) != "" {
+		t.Error("signed without a key")
+	}
+
+	c := NewClient(config.RAGConfig{SigningKeyID: "k1", SigningKey: vectorKey}, nil)
+	first, second := newRequest(), newRequest()
+	for _, req := range []*http.Request{first, second} {
+		if err := c.sign(req, body); err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Header.Get(headerKeyID); got != "k1" {
+			t.Errorf("key ID = %q, want k1", got)
+		}
+		h := req.Header
+		want := signature([]byte(vectorKey), vectorMethod, vectorURI, h.Get(headerTimestamp), h.Get(headerNonce), body)
+		if got := h.Get(headerSignature); got != want {
+			t.Errorf("signature = %s, want %s", got, want)
+		}
+	}
+	if first.Header.Get(headerNonce) == second.Header.Get(headerNonce) {
+		t.Error("two requests got the same nonce")
+	}
+}
@@ -0,0 +1,196 @@
+package vector
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// diskFile is the name of a DiskStore's log in its directory.
+const diskFile = "vectors.jsonl"
+
+// DiskStore keeps records in a JSON lines log on local disk: each line puts
+// records or deletes IDs. Writes are appended and synced; Load replays the
+// log and compacts it when it has superseded entries.
+type DiskStore struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// diskEntry is a line of a DiskStore's log.
+type diskEntry struct {
+	Put    []Record `json:"put,omitempty"`
+	Delete []string `json:"delete,omitempty"`
+}
+
+// NewDiskStore returns a Store keeping its log in dir, which is created if
+// needed.
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &DiskStore{path: filepath.Join(dir, diskFile)}, nil
+}
+
+func (s *DiskStore) Load(ctx context.Context, fn func(Record) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recs, ops, err := s.replay()
+	if err != nil {
+		return err
+	}
+	if ops > len(recs) {
+		if err := s.compact(recs); err != nil {
+			return fmt.Errorf("compact %s: %w", s.path, err)
+		}
+	}
+	for _, rec := range recs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replay reads the log and returns the live records, in the order they
+// were first written, and the number of puts, deletes and unreadable lines
+// in it.
+func (s *DiskStore) replay() ([]Record, int, error) {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var (
+		order []string
+		byID  = make(map[string]Record)
+		lines int
+		ops   int
+	)
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 64<<20)
+	for sc.Scan() {
+		lines++
+		var e diskEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// A write cut short by a crash; compaction drops it.
+			log.Printf("warning: %s line %d: %v; skipping", s.path, lines, err)
+			ops++
+			continue
+		}
+		ops += len(e.Put) + len(e.Delete)
+		for _, rec := range e.Put {
+			if _, ok := byID[rec.ID]; !ok {
+				order = append(order, rec.ID)
+			}
+			byID[rec.ID] = rec
+		}
+		for _, id := range e.Delete {
+			delete(byID, id)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	recs := make([]Record, 0, len(byID))
+	for _, id := range order {
+		if rec, ok := byID[id]; ok {
+			recs = append(recs, rec)
+			delete(byID, id)
+		}
+	}
+	return recs, ops, nil
+}
+
+// compact replaces the log with one putting recs, one record per line.
+func (s *DiskStore) compact(recs []Record) error {
+	if s.f != nil {
+		_ = s.f.Close()
+		s.f = nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), diskFile+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, rec := range recs {
+		if err := enc.Encode(diskEntry{Put: []Record{rec}}); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *DiskStore) Put(_ context.Context, recs []Record) error {
+	return s.append(diskEntry{Put: recs})
+}
+
+func (s *DiskStore) Delete(_ context.Context, ids []string) error {
+	return s.append(diskEntry{Delete: ids})
+}
+
+func (s *DiskStore) append(e diskEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+		if err != nil {
+			return err
+		}
+		s.f = f
+	}
+	if _, err := s.f.Write(line); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+// Close closes the log.
+func (s *DiskStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
@@ -0,0 +1,130 @@
+package vector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"docsense/api/internal/adapters/rag"
+)
+
+// Embedder turns texts into vectors.
+type Embedder interface {
+	// Embed returns a vector per text, in order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// Model names the embedding model. Records embedded with another model
+	// are not loaded.
+	Model() string
+}
+
+// HashEmbedder embeds texts as hashed bags of words. It needs no model and
+// matches only shared words, so it suits trying the embedded backend out
+// rather than production.
+type HashEmbedder struct {
+	Dims int
+}
+
+func (e HashEmbedder) Model() string {
+	return fmt.Sprintf("hash-%d", e.Dims)
+}
+
+func (e HashEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, e.Dims)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, w := range words {
+			h := fnv.New32a()
+			h.Write([]byte(w))
+			v[h.Sum32()%uint32(e.Dims)]++
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// OpenAIEmbedder calls an OpenAI-compatible embeddings endpoint (OpenAI,
+// Ollama, llama.cpp, vLLM, ...).
+type OpenAIEmbedder struct {
+	// URL is the API base URL, e.g. "https://api.openai.com/v1"; requests
+	// go to URL + "/embeddings".
+	URL    string
+	APIKey string
+	Name   string
+
+	HTTPClient *http.Client
+}
+
+// NewOpenAIEmbedder returns an OpenAIEmbedder with a per-request timeout.
+func NewOpenAIEmbedder(url, apiKey, model string, timeout time.Duration) *OpenAIEmbedder {
+	return &OpenAIEmbedder{
+		URL:        strings.TrimSuffix(url, "/"),
+		APIKey:     apiKey,
+		Name:       model,
+		HTTPClient: &http.Client{Timeout: timeout},
+	}
+}
+
+func (e *OpenAIEmbedder) Model() string {
+	return e.Name
+}
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	body, err := json.Marshal(map[string]any{"model": e.Name, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+
+	resp, err := e.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embeddings: %w: %v", rag.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, fmt.Errorf("embeddings: %w", &rag.StatusError{StatusCode: resp.StatusCode, Body: string(msg)})
+	}
+
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("embeddings: decode response: %w", err)
+	}
+	vecs := make([][]float32, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(vecs) {
+			return nil, fmt.Errorf("embeddings: index %d out of range", d.Index)
+		}
+		vecs[d.Index] = d.Embedding
+	}
+	for i, v := range vecs {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("embeddings: no embedding for input %d", i)
+		}
+	}
+	return vecs, nil
+}
@@ -0,0 +1,280 @@
+package vector
+
+import (
+	"container/heap"
+	"math"
+	"math/rand/v2"
+	"sort"
+)
+
+// hnsw is a Hierarchical Navigable Small World graph (Malkov & Yashunin)
+// over unit vectors, with cosine distance. It is not safe for concurrent
+// use; Index guards it.
+//
+// Nodes are never unlinked: deleting a node only marks it, so the graph
+// stays navigable, and Index rebuilds the graph once enough nodes are
+// deleted.
+type hnsw struct {
+	m              int // neighbors per node above layer 0 (2m on layer 0)
+	efConstruction int
+	levelMult      float64
+
+	nodes   []hnswNode
+	entry   int32 // -1 when empty
+	deleted int
+}
+
+type hnswNode struct {
+	vec       []float32
+	neighbors [][]int32 // by layer
+	deleted   bool
+}
+
+func newHNSW(m, efConstruction int) *hnsw {
+	return &hnsw{
+		m:              m,
+		efConstruction: max(efConstruction, m),
+		levelMult:      1 / math.Log(float64(m)),
+		entry:          -1,
+	}
+}
+
+// live is the number of nodes that are not deleted.
+func (g *hnsw) live() int {
+	return len(g.nodes) - g.deleted
+}
+
+// add inserts vec and returns its node.
+func (g *hnsw) add(vec []float32) int32 {
+	level := int(-math.Log(1-rand.Float64()) * g.levelMult)
+	id := int32(len(g.nodes))
+	g.nodes = append(g.nodes, hnswNode{vec: vec, neighbors: make([][]int32, level+1)})
+	if g.entry < 0 {
+		g.entry = id
+		return id
+	}
+
+	ep := g.entry
+	top := len(g.nodes[ep].neighbors) - 1
+	for l := top; l > level; l-- {
+		ep = g.greedy(vec, ep, l)
+	}
+	for l := min(level, top); l >= 0; l-- {
+		found := g.searchLayer(vec, ep, g.efConstruction, l, nil)
+		g.nodes[id].neighbors[l] = g.selectNeighbors(found, g.maxNeighbors(l))
+		for _, n := range g.nodes[id].neighbors[l] {
+			g.link(n, id, l)
+		}
+		ep = found[0].node
+	}
+	if level > top {
+		g.entry = id
+	}
+	return id
+}
+
+// remove marks a node deleted.
+func (g *hnsw) remove(id int32) {
+	if !g.nodes[id].deleted {
+		g.nodes[id].deleted = true
+		g.deleted++
+	}
+}
+
+// search returns up to k live nodes nearest to vec that allow accepts (nil
+// accepts all), nearest first. ef is the size of the candidate list.
+func (g *hnsw) search(vec []float32, k, ef int, allow func(int32) bool) []scored {
+	if g.entry < 0 {
+		return nil
+	}
+	ep := g.entry
+	for l := len(g.nodes[ep].neighbors) - 1; l > 0; l-- {
+		ep = g.greedy(vec, ep, l)
+	}
+	found := g.searchLayer(vec, ep, max(ef, k), 0, func(n int32) bool {
+		return !g.nodes[n].deleted && (allow == nil || allow(n))
+	})
+	if len(found) > k {
+		found = found[:k]
+	}
+	return found
+}
+
+// greedy walks layer l from ep towards vec and returns the closest node
+// it reaches.
+func (g *hnsw) greedy(vec []float32, ep int32, l int) int32 {
+	best := distance(vec, g.nodes[ep].vec)
+	for changed := true; changed; {
+		changed = false
+		for _, n := range g.nodes[ep].neighbors[l] {
+			if d := distance(vec, g.nodes[n].vec); d < best {
+				best, ep, changed = d, n, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer is a beam search of layer l from ep, nearest first. Only
+// nodes that result accepts (nil accepts all) are returned, but all nodes
+// are traversed, so filtered searches still reach their matches.
+func (g *hnsw) searchLayer(vec []float32, ep int32, ef, l int, result func(int32) bool) []scored {
+	visited := map[int32]bool{ep: true}
+	start := scored{node: ep, dist: distance(vec, g.nodes[ep].vec)}
+	candidates := &minHeap{start}
+	results := &maxHeap{}
+	if result == nil || result(ep) {
+		*results = append(*results, start)
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(scored)
+		if results.Len() >= ef && c.dist > (*results)[0].dist {
+			break
+		}
+		for _, n := range g.nodes[c.node].neighbors[l] {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+			d := distance(vec, g.nodes[n].vec)
+			if results.Len() >= ef && d >= (*results)[0].dist {
+				continue
+			}
+			heap.Push(candidates, scored{node: n, dist: d})
+			if result != nil && !result(n) {
+				continue
+			}
+			heap.Push(results, scored{node: n, dist: d})
+			if results.Len() > ef {
+				heap.Pop(results)
+			}
+		}
+	}
+
+	out := make([]scored, results.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(results).(scored)
+	}
+	return out
+}
+
+func (g *hnsw) maxNeighbors(l int) int {
+	if l == 0 {
+		return 2 * g.m
+	}
+	return g.m
+}
+
+// link adds to as a neighbor of from on layer l, pruning from's neighbors
+// if it has too many.
+func (g *hnsw) link(from, to int32, l int) {
+	nb := append(g.nodes[from].neighbors[l], to)
+	if len(nb) > g.maxNeighbors(l) {
+		vec := g.nodes[from].vec
+		cands := make([]scored, len(nb))
+		for i, n := range nb {
+			cands[i] = scored{node: n, dist: distance(vec, g.nodes[n].vec)}
+		}
+		sortScored(cands)
+		nb = g.selectNeighbors(cands, g.maxNeighbors(l))
+	}
+	g.nodes[from].neighbors[l] = nb
+}
+
+// selectNeighbors picks up to m of the candidates (nearest first) with the
+// paper's heuristic: a candidate is kept if it is closer to the base than to
+// any kept neighbor, which spreads links across clusters. Skipped
+// candidates fill the remaining slots.
+func (g *hnsw) selectNeighbors(cands []scored, m int) []int32 {
+	out := make([]int32, 0, m)
+	var skipped []int32
+	for _, c := range cands {
+		if len(out) == m {
+			break
+		}
+		good := true
+		for _, n := range out {
+			if distance(g.nodes[c.node].vec, g.nodes[n].vec) < c.dist {
+				good = false
+				break
+			}
+		}
+		if good {
+			out = append(out, c.node)
+		} else {
+			skipped = append(skipped, c.node)
+		}
+	}
+	for _, n := range skipped {
+		if len(out) == m {
+			break
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// distance is the cosine distance of unit vectors.
+func distance(a, b []float32) float32 {
+	return 1 - dot(a, b)
+}
+
+func dot(a, b []float32) float32 {
+	var s float32
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
+
+// normalize scales v to unit length in place; zero vectors are left alone.
+func normalize(v []float32) {
+	var s float64
+	for _, x := range v {
+		s += float64(x) * float64(x)
+	}
+	if s == 0 {
+		return
+	}
+	n := float32(math.Sqrt(s))
+	for i := range v {
+		v[i] /= n
+	}
+}
+
+// scored is a node and its distance to a query.
+type scored struct {
+	node int32
+	dist float32
+}
+
+func sortScored(s []scored) {
+	sort.Slice(s, func(i, j int) bool { return s[i].dist < s[j].dist })
+}
+
+type minHeap []scored
+
+func (h minHeap) Len() int           { return len(h) }
+func (h minHeap) Less(i, j int) bool { return h[i].dist < h[j].dist }
+func (h minHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(x any)        { *h = append(*h, x.(scored)) }
+func (h *minHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type maxHeap []scored
+
+func (h maxHeap) Len() int           { return len(h) }
+func (h maxHeap) Less(i, j int) bool { return h[i].dist > h[j].dist }
+func (h maxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x any)        { *h = append(*h, x.(scored)) }
+func (h *maxHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,93 @@
+package vector
+
+import (
+	"math/rand/v2"
+	"sort"
+	"testing"
+)
+
+func randomVectors(r *rand.Rand, n, dims int) [][]float32 {
+	out := make([][]float32, n)
+	for i := range out {
+		v := make([]float32, dims)
+		for j := range v {
+			v[j] = float32(r.NormFloat64())
+		}
+		normalize(v)
+		out[i] = v
+	}
+	return out
+}
+
+// exactNearest returns the k nodes nearest to q among those allow accepts.
+func exactNearest(vecs [][]float32, q []float32, k int, allow func(int32) bool) []int32 {
+	var all []scored
+	for i, v := range vecs {
+		if allow(int32(i)) {
+			all = append(all, scored{node: int32(i), dist: distance(q, v)})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].dist < all[j].dist })
+	out := make([]int32, 0, k)
+	for _, s := range all[:min(k, len(all))] {
+		out = append(out, s.node)
+	}
+	return out
+}
+
+// TestHNSWRecall compares graph search with exact search on random unit
+// vectors, with and without deleted nodes and a filter.
+func TestHNSWRecall(t *testing.T) {
+	const (
+		n, dims, queries = 3000, 32, 50
+		k, ef            = 10, 64
+		minRecall        = 0.95
+	)
+	r := rand.New(rand.NewPCG(1, 2))
+	vecs := randomVectors(r, n, dims)
+	g := newHNSW(16, 100)
+	for _, v := range vecs {
+		g.add(v)
+	}
+	for i := 0; i < n; i += 7 {
+		g.remove(int32(i))
+	}
+	live := func(n int32) bool { return !g.nodes[n].deleted }
+	even := func(n int32) bool { return n%2 == 0 }
+
+	tests := []struct {
+		name  string
+		allow func(int32) bool
+	}{
+		{"all", nil},
+		{"even nodes", even},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, total := 0, 0
+			for _, q := range randomVectors(r, queries, dims) {
+				want := exactNearest(vecs, q, k, func(n int32) bool { return live(n) && (tt.allow == nil || tt.allow(n)) })
+				found := g.search(q, k, ef, tt.allow)
+				got := make(map[int32]bool)
+				for i, s := range found {
+					if !live(s.node) || (tt.allow != nil && !tt.allow(s.node)) {
+						t.Fatalf("search returned node %d, which is deleted or filtered out", s.node)
+					}
+					if i > 0 && s.dist < found[i-1].dist {
+						t.Fatalf("results not nearest first: %v", found)
+					}
+					got[s.node] = true
+				}
+				for _, n := range want {
+					if got[n] {
+						hits++
+					}
+				}
+				total += len(want)
+			}
+			if recall := float64(hits) / float64(total); recall < minRecall {
+				t.Errorf("recall@%d = %.3f, want at least %.2f", k, recall, minRecall)
+			}
+		})
+	}
+}
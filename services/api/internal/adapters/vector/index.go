@@ -0,0 +1,396 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+
+	"docsense/api/internal/adapters/rag"
+)
+
+// exactSearchMax is the most candidates a filtered search scores one by
+// one instead of searching the graph, which degrades when few nodes pass
+// the filter.
+const exactSearchMax = 2048
+
+// Options tunes the HNSW graph.
+type Options struct {
+	// M is the number of links per node (2M on the bottom layer).
+	M int
+	// EfConstruction and EfSearch are the candidate list sizes when
+	// inserting and searching; larger is slower and more accurate.
+	EfConstruction int
+	EfSearch       int
+}
+
+// Index is an embedded vector backend: chunks are embedded by an Embedder,
+// persisted in a Store and searched in an in-memory HNSW graph that is
+// rebuilt from the store on Open. It implements the RAG service's indexing
+// and retrieval calls, and retrieval filtered by user and document.
+type Index struct {
+	store    Store
+	embedder Embedder
+	owner    OwnerFunc
+	opts     Options
+
+	// wmu serializes writes, so the store and memory apply them in the
+	// same order; mu guards the fields below.
+	wmu    sync.Mutex
+	mu     sync.RWMutex
+	graph  *hnsw
+	dims   int
+	points map[string]*point // by chunk ID
+	nodes  []*point          // by graph node; nil once deleted
+	byDoc  map[string]map[*point]bool
+	byUser map[string]int // point counts
+}
+
+type point struct {
+	rec  Record // without Vector, which the graph holds
+	node int32
+}
+
+// Open loads the records in store embedded with embedder's model into a
+// new Index. owner resolves the users owning embedded documents.
+func Open(ctx context.Context, store Store, embedder Embedder, owner OwnerFunc, opts Options) (*Index, error) {
+	idx := &Index{
+		store:    store,
+		embedder: embedder,
+		owner:    owner,
+		opts:     opts,
+		graph:    newHNSW(opts.M, opts.EfConstruction),
+		points:   make(map[string]*point),
+		byDoc:    make(map[string]map[*point]bool),
+		byUser:   make(map[string]int),
+	}
+	skipped := 0
+	err := store.Load(ctx, func(rec Record) error {
+		if rec.Model != embedder.Model() || (idx.dims != 0 && len(rec.Vector) != idx.dims) {
+			skipped++
+			return nil
+		}
+		idx.dims = len(rec.Vector)
+		idx.insert(rec)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load vectors: %w", err)
+	}
+	log.Printf("vector index: loaded %d points (model %s)", len(idx.points), embedder.Model())
+	if skipped > 0 {
+		log.Printf("warning: vector index: skipped %d points of another model or dimension; `api reconcile` re-embeds their chunks", skipped)
+	}
+	return idx, nil
+}
+
+// EmbedChunks embeds chunks of a document, stores them and adds them to
+// the index, replacing points with the same IDs.
+func (idx *Index) EmbedChunks(ctx context.Context, documentID string, chunks []rag.ChunkIn) ([]string, error) {
+	if len(chunks) == 0 {
+		return []string{}, nil
+	}
+	userID, err := idx.owner(ctx, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("embed: %w", err)
+	}
+	texts := make([]string, len(chunks))
+	for i, ch := range chunks {
+		texts[i] = ch.Text
+	}
+	vecs, err := idx.embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("embed: %w", err)
+	}
+
+	recs := make([]Record, len(chunks))
+	ids := make([]string, len(chunks))
+	for i, ch := range chunks {
+		normalize(vecs[i])
+		recs[i] = Record{ID: ch.ChunkID, DocumentID: documentID, UserID: userID, Model: idx.embedder.Model(), Chunk: ch, Vector: vecs[i]}
+		ids[i] = ch.ChunkID
+	}
+
+	idx.wmu.Lock()
+	defer idx.wmu.Unlock()
+	idx.mu.RLock()
+	dims := idx.dims
+	idx.mu.RUnlock()
+	for _, rec := range recs {
+		if dims == 0 {
+			dims = len(rec.Vector)
+		}
+		if len(rec.Vector) != dims {
+			return nil, fmt.Errorf("embed: got %d-dimensional vector, index has %d", len(rec.Vector), dims)
+		}
+	}
+	if err := idx.store.Put(ctx, recs); err != nil {
+		return nil, fmt.Errorf("embed: store vectors: %w", err)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.dims = dims
+	for _, rec := range recs {
+		idx.remove(rec.ID)
+		idx.insert(rec)
+	}
+	idx.maybeRebuild()
+	return ids, nil
+}
+
+// UpdatePayloads replaces the metadata of indexed chunks, keeping their
+// vectors.
+func (idx *Index) UpdatePayloads(ctx context.Context, documentID string, chunks []rag.ChunkIn) (int, error) {
+	idx.wmu.Lock()
+	defer idx.wmu.Unlock()
+
+	idx.mu.RLock()
+	var recs []Record
+	for _, ch := range chunks {
+		p, ok := idx.points[ch.ChunkID]
+		if !ok {
+			continue
+		}
+		rec := p.rec
+		rec.DocumentID, rec.Chunk = documentID, ch
+		rec.Vector = idx.graph.nodes[p.node].vec
+		recs = append(recs, rec)
+	}
+	idx.mu.RUnlock()
+	if len(recs) == 0 {
+		return 0, nil
+	}
+	if err := idx.store.Put(ctx, recs); err != nil {
+		return 0, fmt.Errorf("update payloads: %w", err)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, rec := range recs {
+		p := idx.points[rec.ID]
+		idx.unindex(p)
+		rec.Vector = nil
+		p.rec = rec
+		idx.index(p)
+	}
+	return len(recs), nil
+}
+
+// ListPoints pages through indexed chunks in ID order; offsets are point
+// IDs.
+func (idx *Index) ListPoints(_ context.Context, offset string, limit int) (*rag.PointsResponse, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	ids := make([]string, 0, len(idx.points))
+	for id := range idx.points {
+		if id >= offset {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	out := &rag.PointsResponse{Points: []rag.Point{}}
+	for i, id := range ids {
+		if i == limit {
+			next := id
+			out.NextOffset = &next
+			break
+		}
+		documentID := idx.points[id].rec.DocumentID
+		out.Points = append(out.Points, rag.Point{ID: id, DocumentID: &documentID})
+	}
+	return out, nil
+}
+
+// DeletePoints removes chunks from the store and the index.
+func (idx *Index) DeletePoints(ctx context.Context, pointIDs []string) (int, error) {
+	idx.wmu.Lock()
+	defer idx.wmu.Unlock()
+	if err := idx.store.Delete(ctx, pointIDs); err != nil {
+		return 0, fmt.Errorf("delete points: %w", err)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, id := range pointIDs {
+		idx.remove(id)
+	}
+	idx.maybeRebuild()
+	return len(pointIDs), nil
+}
+
+// Retrieve returns the topK chunks most similar to query among all
+// documents.
+func (idx *Index) Retrieve(ctx context.Context, query string, topK int) (*rag.RetrieveResponse, error) {
+	return idx.RetrieveFiltered(ctx, query, topK, rag.Filter{})
+}
+
+// RetrieveFiltered returns the topK chunks most similar to query among
+// those the filter allows, best first.
+func (idx *Index) RetrieveFiltered(ctx context.Context, query string, topK int, filter rag.Filter) (*rag.RetrieveResponse, error) {
+	vecs, err := idx.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("retrieve: %w", err)
+	}
+	q := vecs[0]
+	normalize(q)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if idx.dims != 0 && len(q) != idx.dims {
+		return nil, fmt.Errorf("retrieve: got %d-dimensional query vector, index has %d", len(q), idx.dims)
+	}
+
+	allowed := len(idx.points)
+	if filter.UserID != "" {
+		allowed = idx.byUser[filter.UserID]
+	}
+	cands, listed := idx.candidates(filter)
+	if listed {
+		allowed = len(cands)
+	}
+
+	var found []scored
+	switch {
+	case allowed == 0:
+	case listed && allowed <= exactSearchMax:
+		found = idx.exact(q, cands, topK)
+	case allowed == len(idx.points):
+		found = idx.graph.search(q, topK, max(idx.opts.EfSearch, topK), nil)
+	default:
+		// Widen the search by how selective the filter is, so enough
+		// matches survive it.
+		ef := max(idx.opts.EfSearch, topK)
+		ef = min(ef*((len(idx.points)+allowed-1)/allowed), len(idx.points))
+		found = idx.graph.search(q, topK, ef, func(n int32) bool { return allows(filter, idx.nodes[n].rec) })
+	}
+
+	out := &rag.RetrieveResponse{Matches: make([]rag.RetrievedChunkOut, len(found))}
+	for i, s := range found {
+		rec := idx.nodes[s.node].rec
+		out.Matches[i] = rec.Chunk.Match(rec.DocumentID, float64(1-s.dist))
+	}
+	return out, nil
+}
+
+// candidates returns the points the filter allows when they can be listed
+// without a scan: the filter names documents, or its user has few points.
+func (idx *Index) candidates(filter rag.Filter) ([]*point, bool) {
+	var out []*point
+	switch {
+	case len(filter.DocumentIDs) > 0:
+		seen := make(map[string]bool)
+		for _, id := range filter.DocumentIDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			for p := range idx.byDoc[id] {
+				if allows(filter, p.rec) {
+					out = append(out, p)
+				}
+			}
+		}
+	case filter.UserID != "" && idx.byUser[filter.UserID] <= exactSearchMax:
+		for _, p := range idx.points {
+			if allows(filter, p.rec) {
+				out = append(out, p)
+			}
+		}
+	default:
+		return nil, false
+	}
+	return out, true
+}
+
+// exact scores every candidate and returns the topK nearest.
+func (idx *Index) exact(q []float32, cands []*point, topK int) []scored {
+	found := make([]scored, len(cands))
+	for i, p := range cands {
+		found[i] = scored{node: p.node, dist: distance(q, idx.graph.nodes[p.node].vec)}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].dist != found[j].dist {
+			return found[i].dist < found[j].dist
+		}
+		return idx.nodes[found[i].node].rec.ID < idx.nodes[found[j].node].rec.ID
+	})
+	if len(found) > topK {
+		found = found[:topK]
+	}
+	return found
+}
+
+// allows reports whether the filter allows a record.
+func allows(f rag.Filter, rec Record) bool {
+	if f.UserID != "" && rec.UserID != f.UserID {
+		return false
+	}
+	return len(f.DocumentIDs) == 0 || slices.Contains(f.DocumentIDs, rec.DocumentID)
+}
+
+// insert adds rec to the graph and the lookup maps; its ID must not be
+// indexed.
+func (idx *Index) insert(rec Record) {
+	node := idx.graph.add(rec.Vector)
+	rec.Vector = nil
+	p := &point{rec: rec, node: node}
+	idx.points[rec.ID] = p
+	idx.nodes = append(idx.nodes, p)
+	idx.index(p)
+}
+
+// remove deletes a point from the graph and the lookup maps, if present.
+func (idx *Index) remove(id string) {
+	p, ok := idx.points[id]
+	if !ok {
+		return
+	}
+	idx.unindex(p)
+	delete(idx.points, id)
+	idx.nodes[p.node] = nil
+	idx.graph.remove(p.node)
+}
+
+func (idx *Index) index(p *point) {
+	doc := idx.byDoc[p.rec.DocumentID]
+	if doc == nil {
+		doc = make(map[*point]bool)
+		idx.byDoc[p.rec.DocumentID] = doc
+	}
+	doc[p] = true
+	idx.byUser[p.rec.UserID]++
+}
+
+func (idx *Index) unindex(p *point) {
+	delete(idx.byDoc[p.rec.DocumentID], p)
+	if len(idx.byDoc[p.rec.DocumentID]) == 0 {
+		delete(idx.byDoc, p.rec.DocumentID)
+	}
+	if idx.byUser[p.rec.UserID]--; idx.byUser[p.rec.UserID] == 0 {
+		delete(idx.byUser, p.rec.UserID)
+	}
+}
+
+// maybeRebuild rebuilds the graph from the live points once deleted nodes
+// outnumber them, which keeps searches from wading through dead links.
+func (idx *Index) maybeRebuild() {
+	if idx.graph.deleted < 1024 || idx.graph.deleted < idx.graph.live() {
+		return
+	}
+	old, live := idx.graph, make([]*point, 0, len(idx.points))
+	for _, p := range idx.nodes {
+		if p != nil {
+			live = append(live, p)
+		}
+	}
+	idx.graph = newHNSW(idx.opts.M, idx.opts.EfConstruction)
+	idx.nodes = idx.nodes[:0]
+	for _, p := range live {
+		p.node = idx.graph.add(old.nodes[p.node].vec)
+		idx.nodes = append(idx.nodes, p)
+	}
+}
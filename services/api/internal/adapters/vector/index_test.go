@@ -0,0 +1,90 @@
+package vector
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"docsense/api/internal/adapters/rag"
+)
+
+func openTestIndex(t *testing.T, dir string) *Index {
+	t.Helper()
+	store, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	owners := map[string]string{"doc1": "alice", "doc2": "alice", "doc3": "bob"}
+	owner := func(_ context.Context, documentID string) (string, error) { return owners[documentID], nil }
+	idx, err := Open(context.Background(), store, HashEmbedder{Dims: 256}, owner, Options{M: 8, EfConstruction: 64, EfSearch: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+func matchIDs(resp *rag.RetrieveResponse) []string {
+	var ids []string
+	for _, m := range resp.Matches {
+		ids = append(ids, m.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestIndexFilters(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	idx := openTestIndex(t, dir)
+	for doc, texts := range map[string][]string{
+		"doc1": {"invoice total due"},
+		"doc2": {"invoice number"},
+		"doc3": {"invoice from bob"},
+	} {
+		var chunks []rag.ChunkIn
+		for i, text := range texts {
+			chunks = append(chunks, rag.ChunkIn{ChunkID: doc + "-" + string(rune('a'+i)), ChunkIndex: i, Text: text})
+		}
+		if _, err := idx.EmbedChunks(ctx, doc, chunks); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter rag.Filter
+		want   []string
+	}{
+		{"everyone", rag.Filter{}, []string{"doc1-a", "doc2-a", "doc3-a"}},
+		{"by user", rag.Filter{UserID: "alice"}, []string{"doc1-a", "doc2-a"}},
+		{"by user and document", rag.Filter{UserID: "alice", DocumentIDs: []string{"doc2", "doc3"}}, []string{"doc2-a"}},
+		{"unknown user", rag.Filter{UserID: "carol"}, nil},
+	}
+	for _, tt := range tests {
+		resp, err := idx.RetrieveFiltered(ctx, "invoice", 10, tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := matchIDs(resp); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: matches %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := idx.DeletePoints(ctx, []string{"doc2-a"}); err != nil {
+		t.Fatal(err)
+	}
+	// Reopening replays the store.
+	idx = openTestIndex(t, dir)
+	resp, err := idx.RetrieveFiltered(ctx, "invoice", 10, rag.Filter{UserID: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := matchIDs(resp), []string{"doc1-a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after delete and reopen: matches %v, want %v", got, want)
+	}
+	if m := resp.Matches[0]; *m.DocumentID != "doc1" || *m.Text != "invoice total due" {
+		t.Errorf("match = %+v", m)
+	}
+}
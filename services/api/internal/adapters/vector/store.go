@@ -0,0 +1,129 @@
+package vector
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"docsense/api/internal/adapters/rag"
+
+	"github.com/lib/pq"
+)
+
+// Record is a stored chunk embedding. ID is the chunk ID.
+type Record struct {
+	ID         string      `json:"id"`
+	DocumentID string      `json:"document_id"`
+	UserID     string      `json:"user_id"`
+	Model      string      `json:"model"`
+	Chunk      rag.ChunkIn `json:"chunk"`
+	Vector     []float32   `json:"vector"`
+}
+
+// Store persists records for an Index, which keeps them in memory and
+// loads them all at startup.
+type Store interface {
+	// Load calls fn with every stored record.
+	Load(ctx context.Context, fn func(Record) error) error
+	// Put inserts or replaces records by ID.
+	Put(ctx context.Context, recs []Record) error
+	// Delete deletes records by ID, ignoring unknown IDs.
+	Delete(ctx context.Context, ids []string) error
+}
+
+// PostgresStore keeps records in the chunk_vectors table.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore returns a Store backed by the chunk_vectors table.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Load(ctx context.Context, fn func(Record) error) error {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT id::text, document_id::text, user_id::text, model, payload, embedding
+		 FROM chunk_vectors`,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			rec     Record
+			payload []byte
+			vec     pq.Float32Array
+		)
+		if err := rows.Scan(&rec.ID, &rec.DocumentID, &rec.UserID, &rec.Model, &payload, &vec); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(payload, &rec.Chunk); err != nil {
+			return fmt.Errorf("chunk vector %s: %w", rec.ID, err)
+		}
+		rec.Vector = vec
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *PostgresStore) Put(ctx context.Context, recs []Record) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(
+		ctx,
+		`INSERT INTO chunk_vectors (id, document_id, user_id, model, payload, embedding)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (id) DO UPDATE SET
+		   document_id = EXCLUDED.document_id,
+		   user_id = EXCLUDED.user_id,
+		   model = EXCLUDED.model,
+		   payload = EXCLUDED.payload,
+		   embedding = EXCLUDED.embedding,
+		   updated_at = now()`,
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, rec := range recs {
+		payload, err := json.Marshal(rec.Chunk)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, rec.ID, rec.DocumentID, rec.UserID, rec.Model, payload, pq.Float32Array(rec.Vector)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, ids []string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM chunk_vectors WHERE id = ANY($1::uuid[])`, pq.Array(ids))
+	return err
+}
+
+// OwnerFunc returns the ID of the user owning a document.
+type OwnerFunc = rag.OwnerFunc
+
+// DocumentOwners looks up document owners in the documents table.
+func DocumentOwners(db *sql.DB) OwnerFunc {
+	return func(ctx context.Context, documentID string) (string, error) {
+		var userID string
+		err := db.QueryRowContext(ctx, `SELECT user_id::text FROM documents WHERE id = $1`, documentID).Scan(&userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("document %s: %w", documentID, rag.ErrBadRequest)
+		}
+		return userID, err
+	}
+}
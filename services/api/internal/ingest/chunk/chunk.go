@@ -2,6 +2,9 @@ package chunk
 
 import (
 	"strings"
+	"unicode"
+
+	"docsense/api/internal/ingest/tokenize"
 
 	uuid "github.com/google/uuid"
 )
@@ -9,40 +12,90 @@ import (
 type Chunk struct {
 	DocumentID uuid.UUID
 	Index      int
-	Content    string
+	// Content is the document text between StartOffset and EndOffset,
+	// verbatim.
+	Content string
+	// TokenCount is the length of Content in the chunker's tokens.
 	TokenCount int
+
+	// StartOffset and EndOffset are the byte offsets of Content in the
+	// document text.
+	StartOffset int
+	EndOffset   int
+
+	// SegmentStart and SegmentEnd are the numbers of the first and last
+	// segment (e.g. slide) the chunk's words came from; zero when the input
+	// had no segments. SegmentTitle is the title of the first one.
+	SegmentStart int
+	SegmentEnd   int
+	SegmentTitle string
+
+	// SectionPath is the heading breadcrumb of the section the chunk lies
+	// in, outermost first; nil when the document has no headings.
+	SectionPath []string
+}
+
+// Segment is a numbered region of the document text, such as a slide,
+// spanning bytes Start to End.
+type Segment struct {
+	Number int
+	Title  string
+	Start  int
+	End    int
+
+	// SectionPath is set on segments produced by SplitSections.
+	SectionPath []string
+}
+
+// Chunker splits a document's segments into chunks. Segments are given in
+// document order and must not overlap; unsegmented text is passed as a
+// single segment numbered 0 spanning the whole text.
+type Chunker interface {
+	Chunk(documentID uuid.UUID, text string, segments []Segment) ([]Chunk, error)
 }
 
-// ChunkText deterministically splits text into overlapping chunks.
-// Approx 700 tokens per chunk, 100-token overlap, using words as token approximation.
+// ChunkText deterministically splits text into overlapping chunks using the
+// default options, counting words as tokens.
 func ChunkText(documentID uuid.UUID, text string) ([]Chunk, error) {
-	words := strings.Fields(text)
-	if len(words) == 0 {
-		return nil, nil
-	}
+	return ChunkSegments(documentID, text, []Segment{{Start: 0, End: len(text)}})
+}
 
-	const chunkSize = 700
-	const overlap = 100
+// ChunkSegments chunks the segments of text exactly like ChunkText,
+// additionally recording which segments each chunk spans.
+func ChunkSegments(documentID uuid.UUID, text string, segments []Segment) ([]Chunk, error) {
+	return fixedChunker{size: DefaultSize, overlap: DefaultOverlap, tok: tokenize.Words{}}.Chunk(documentID, text, segments)
+}
 
-	var chunks []Chunk
-	idx := 0
-	for start := 0; start < len(words); start += (chunkSize - overlap) {
-		end := start + chunkSize
-		if end > len(words) {
-			end = len(words)
-		}
-		slice := words[start:end]
-		content := strings.Join(slice, " ")
-		chunks = append(chunks, Chunk{
-			DocumentID: documentID,
-			Index:      idx,
-			Content:    content,
-			TokenCount: len(slice),
-		})
-		idx++
-		if end == len(words) {
-			break
+// span is a half-open byte range of the document text.
+type span struct {
+	start, end int
+}
+
+// trimSpan shrinks s to exclude leading and trailing whitespace of text;
+// ok is false when nothing is left.
+func trimSpan(text string, s span) (span, bool) {
+	sub := text[s.start:s.end]
+	trimmed := strings.TrimLeftFunc(sub, unicode.IsSpace)
+	s.start += len(sub) - len(trimmed)
+	s.end = s.start + len(strings.TrimRightFunc(trimmed, unicode.IsSpace))
+	return s, s.end > s.start
+}
+
+// wordSpans returns the whitespace-separated words of text within s.
+func wordSpans(text string, s span) []span {
+	var out []span
+	start := -1
+	for i, r := range text[s.start:s.end] {
+		switch {
+		case unicode.IsSpace(r) && start >= 0:
+			out = append(out, span{s.start + start, s.start + i})
+			start = -1
+		case !unicode.IsSpace(r) && start < 0:
+			start = i
 		}
 	}
-	return chunks, nil
+	if start >= 0 {
+		out = append(out, span{s.start + start, s.end})
+	}
+	return out
 }
@@ -0,0 +1,198 @@
+package chunk
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"docsense/api/internal/ingest/tokenize"
+
+	uuid "github.com/google/uuid"
+)
+
+const sampleText = `Retrieval works best on clean text. Each chunk should hold a complete thought, e.g. a paragraph or two.
+
+The chunker never splits a sentence unless it must! Does it keep questions? It should.
+
+Lists stay readable:
+first item
+second item
+
+Ünïcödé text — with “quotes” and em dashes — must keep its byte offsets right. 日本語の文も含まれます。
+
+A final paragraph closes the sample, long enough to need more than one small chunk when the size is tiny.`
+
+// checkChunks verifies what every chunker guarantees: chunks are verbatim,
+// trimmed slices of text at their offsets, indexed in order, moving forward,
+// within size unless a single word is larger, sharing at most overlap tokens
+// with the previous chunk, and together covering every word of the text.
+func checkChunks(t *testing.T, text string, chunks []Chunk, size, overlap int, tok tokenize.Tokenizer) {
+	t.Helper()
+	if len(chunks) == 0 {
+		t.Fatal("no chunks")
+	}
+	covered := make([]bool, len(text))
+	for i, c := range chunks {
+		if c.Index != i {
+			t.Errorf("chunk %d has index %d", i, c.Index)
+		}
+		if c.StartOffset < 0 || c.EndOffset > len(text) || c.StartOffset >= c.EndOffset {
+			t.Fatalf("chunk %d: bad offsets [%d, %d)", i, c.StartOffset, c.EndOffset)
+		}
+		if got := text[c.StartOffset:c.EndOffset]; got != c.Content {
+			t.Errorf("chunk %d: text at offsets = %q, content = %q", i, got, c.Content)
+		}
+		if strings.TrimSpace(c.Content) != c.Content {
+			t.Errorf("chunk %d is not trimmed: %q", i, c.Content)
+		}
+		if c.TokenCount != tok.Count(c.Content) {
+			t.Errorf("chunk %d: TokenCount %d, counted %d", i, c.TokenCount, tok.Count(c.Content))
+		}
+		if c.TokenCount > size && len(strings.Fields(c.Content)) > 1 {
+			t.Errorf("chunk %d: %d tokens, size %d: %q", i, c.TokenCount, size, c.Content)
+		}
+		if i > 0 && c.StartOffset <= chunks[i-1].StartOffset {
+			t.Errorf("chunk %d starts at %d, not after chunk %d at %d", i, c.StartOffset, i-1, chunks[i-1].StartOffset)
+		}
+		if i > 0 && c.StartOffset < chunks[i-1].EndOffset {
+			if n := tok.Count(text[c.StartOffset:chunks[i-1].EndOffset]); n > overlap {
+				t.Errorf("chunk %d shares %d tokens with chunk %d, overlap %d", i, n, i-1, overlap)
+			}
+		}
+		for j := c.StartOffset; j < c.EndOffset; j++ {
+			covered[j] = true
+		}
+	}
+	for _, w := range wordSpans(text, span{0, len(text)}) {
+		if !covered[w.start] {
+			t.Errorf("word %q at %d is in no chunk", text[w.start:w.end], w.start)
+		}
+	}
+}
+
+func TestChunkers(t *testing.T) {
+	tok := tokenize.Words{}
+	for _, strategy := range []string{StrategyFixed, StrategySentence, StrategyParagraph, StrategyRecursive} {
+		for _, sz := range []struct{ size, overlap int }{{8, 0}, {8, 3}, {20, 5}, {700, 100}} {
+			opts := Options{Strategy: strategy, Size: sz.size, Overlap: sz.overlap}
+			t.Run(fmt.Sprintf("%s/%d-%d", strategy, sz.size, sz.overlap), func(t *testing.T) {
+				c, err := New(opts, tok)
+				if err != nil {
+					t.Fatal(err)
+				}
+				chunks, err := c.Chunk(uuid.Nil, sampleText, []Segment{{Start: 0, End: len(sampleText)}})
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkChunks(t, sampleText, chunks, sz.size, sz.overlap, tok)
+				if sz.size >= 700 && len(chunks) != 1 {
+					t.Errorf("got %d chunks for a text that fits in one", len(chunks))
+				}
+			})
+		}
+	}
+}
+
+func TestFixedOverlap(t *testing.T) {
+	text := "w1 w2 w3 w4 w5 w6 w7 w8 w9 w10"
+	tests := []struct {
+		size, overlap int
+		want          []string
+	}{
+		{4, 0, []string{"w1 w2 w3 w4", "w5 w6 w7 w8", "w9 w10"}},
+		{4, 2, []string{"w1 w2 w3 w4", "w3 w4 w5 w6", "w5 w6 w7 w8", "w7 w8 w9 w10"}},
+		{5, 1, []string{"w1 w2 w3 w4 w5", "w5 w6 w7 w8 w9", "w9 w10"}},
+		{20, 5, []string{text}},
+	}
+	for _, tt := range tests {
+		c, err := New(Options{Strategy: StrategyFixed, Size: tt.size, Overlap: tt.overlap}, tokenize.Words{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks, err := c.Chunk(uuid.Nil, text, []Segment{{Start: 0, End: len(text)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, ch := range chunks {
+			got = append(got, ch.Content)
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("size %d overlap %d: got %q, want %q", tt.size, tt.overlap, got, tt.want)
+		}
+	}
+}
+
+func TestSentenceBoundaries(t *testing.T) {
+	text := "One two three. Four five six! Seven, e.g. eight nine? Ten."
+	c, err := New(Options{Strategy: StrategySentence, Size: 6}, tokenize.Words{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := c.Chunk(uuid.Nil, text, []Segment{{Start: 0, End: len(text)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"One two three. Four five six!", "Seven, e.g. eight nine? Ten."}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d: %+v", len(chunks), len(want), chunks)
+	}
+	for i, ch := range chunks {
+		if ch.Content != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, ch.Content, want[i])
+		}
+	}
+}
+
+func TestChunkSegmentSpans(t *testing.T) {
+	pages := []string{"alpha beta gamma", "delta epsilon", "zeta eta theta iota"}
+	text := strings.Join(pages, "\n\n")
+	var segments []Segment
+	off := 0
+	for i, p := range pages {
+		segments = append(segments, Segment{Number: i + 1, Title: fmt.Sprintf("p%d", i+1), Start: off, End: off + len(p)})
+		off += len(p) + 2
+	}
+	c, err := New(Options{Strategy: StrategyFixed, Size: 4, Overlap: 0}, tokenize.Words{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := c.Chunk(uuid.Nil, text, segments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		start, end int
+		title      string
+	}{{1, 2, "p1"}, {2, 3, "p2"}, {3, 3, "p3"}}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d", len(chunks), len(want))
+	}
+	for i, ch := range chunks {
+		if ch.SegmentStart != want[i].start || ch.SegmentEnd != want[i].end || ch.SegmentTitle != want[i].title {
+			t.Errorf("chunk %d: segments %d-%d %q, want %d-%d %q", i,
+				ch.SegmentStart, ch.SegmentEnd, ch.SegmentTitle, want[i].start, want[i].end, want[i].title)
+		}
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		ok   bool
+	}{
+		{"defaults", DefaultOptions(), true},
+		{"unknown strategy", Options{Strategy: "semantic", Size: 100}, false},
+		{"zero size", Options{Strategy: StrategyFixed}, false},
+		{"overlap equals size", Options{Strategy: StrategyFixed, Size: 10, Overlap: 10}, false},
+		{"negative overlap", Options{Strategy: StrategyFixed, Size: 10, Overlap: -1}, false},
+		{"parent not larger", Options{Strategy: StrategyFixed, Size: 10, ParentSize: 10}, false},
+		{"hierarchical", Options{Strategy: StrategyRecursive, Size: 10, ParentSize: 50}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.opts.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
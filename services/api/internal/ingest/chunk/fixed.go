@@ -0,0 +1,66 @@
+package chunk
+
+import (
+	"docsense/api/internal/ingest/tokenize"
+
+	uuid "github.com/google/uuid"
+)
+
+// fixedChunker windows the words of the document: as many words as fit in
+// size tokens per chunk, each chunk repeating the last words of the previous
+// one that fit in overlap tokens.
+type fixedChunker struct {
+	size, overlap int
+	tok           tokenize.Tokenizer
+}
+
+func (c fixedChunker) Chunk(documentID uuid.UUID, text string, segments []Segment) ([]Chunk, error) {
+	var words []span
+	var wordSegment []*Segment
+	for i := range segments {
+		for _, w := range wordSpans(text, span{segments[i].Start, segments[i].End}) {
+			words = append(words, w)
+			wordSegment = append(wordSegment, &segments[i])
+		}
+	}
+	if len(words) == 0 {
+		return nil, nil
+	}
+	// Words are counted as they appear inside a chunk, after a space.
+	tokens := make([]int, len(words))
+	for i, w := range words {
+		tokens[i] = c.tok.Count(" " + text[w.start:w.end])
+	}
+
+	var chunks []Chunk
+	for start := 0; start < len(words); {
+		end, n := start, 0
+		for end < len(words) && (end == start || n+tokens[end] <= c.size) {
+			n += tokens[end]
+			end++
+		}
+		content := text[words[start].start:words[end-1].end]
+		chunks = append(chunks, Chunk{
+			DocumentID:   documentID,
+			Index:        len(chunks),
+			Content:      content,
+			TokenCount:   c.tok.Count(content),
+			StartOffset:  words[start].start,
+			EndOffset:    words[end-1].end,
+			SegmentStart: wordSegment[start].Number,
+			SegmentEnd:   wordSegment[end-1].Number,
+			SegmentTitle: wordSegment[start].Title,
+		})
+		if end == len(words) {
+			break
+		}
+
+		next, shared := end, 0
+		for next-1 > start && shared+tokens[next-1] <= c.overlap {
+			next--
+			shared += tokens[next]
+		}
+		start = next
+	}
+	return chunks, nil
+}
@@ -0,0 +1,48 @@
+package chunk
+
+import (
+	uuid "github.com/google/uuid"
+)
+
+// Parent is a large chunk that gives context to the smaller chunks cut from
+// it, which are the ones embedded and retrieved.
+type Parent struct {
+	Chunk
+	Children []Chunk
+}
+
+// ChunkHierarchy cuts each of parents, chunks of text cut from segments,
+// into children with child. Children are indexed across the whole document,
+// so neighbouring children have consecutive indexes, and inherit their
+// parent's section path.
+func ChunkHierarchy(parents []Chunk, child Chunker, documentID uuid.UUID, text string, segments []Segment) ([]Parent, error) {
+	out := make([]Parent, len(parents))
+	index := 0
+	for i, p := range parents {
+		children, err := child.Chunk(documentID, text, clipSegments(segments, p.StartOffset, p.EndOffset))
+		if err != nil {
+			return nil, err
+		}
+		for j := range children {
+			children[j].Index = index
+			children[j].SectionPath = p.SectionPath
+			index++
+		}
+		out[i] = Parent{Chunk: p, Children: children}
+	}
+	return out, nil
+}
+
+// clipSegments returns the parts of segments that lie between byte offsets
+// start and end.
+func clipSegments(segments []Segment, start, end int) []Segment {
+	var out []Segment
+	for _, s := range segments {
+		if s.End <= start || s.Start >= end {
+			continue
+		}
+		s.Start, s.End = max(s.Start, start), min(s.End, end)
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,70 @@
+package chunk
+
+import (
+	"reflect"
+	"testing"
+
+	"docsense/api/internal/ingest/tokenize"
+
+	uuid "github.com/google/uuid"
+)
+
+func TestChunkHierarchy(t *testing.T) {
+	text := sampleText
+	segments := []Segment{{Number: 1, Start: 0, End: 200}, {Number: 2, Start: 200, End: len(text)}}
+	tok := tokenize.Words{}
+	parent, err := New(Options{Strategy: StrategyParagraph, Size: 30}, tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := New(Options{Strategy: StrategyFixed, Size: 6, Overlap: 2}, tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parents, err := parent.Chunk(uuid.Nil, text, segments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parents[0].SectionPath = []string{"Intro"}
+	tree, err := ChunkHierarchy(parents, child, uuid.Nil, text, segments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree) != len(parents) || len(tree) < 2 {
+		t.Fatalf("got %d parents, want %d (at least 2)", len(tree), len(parents))
+	}
+
+	// Children together are a chunking of the whole text, indexed across
+	// parents.
+	var children []Chunk
+	for i, p := range tree {
+		children = append(children, p.Children...)
+		for _, ch := range p.Children {
+			if ch.StartOffset < p.StartOffset || ch.EndOffset > p.EndOffset {
+				t.Errorf("parent %d [%d, %d): child %d [%d, %d) outside it", i, p.StartOffset, p.EndOffset, ch.Index, ch.StartOffset, ch.EndOffset)
+			}
+			if !reflect.DeepEqual(ch.SectionPath, p.SectionPath) {
+				t.Errorf("parent %d: child section path %q, want %q", i, ch.SectionPath, p.SectionPath)
+			}
+		}
+	}
+	checkChunks(t, text, children, 6, 2, tok)
+}
+
+func TestClipSegments(t *testing.T) {
+	segments := []Segment{{Number: 1, Start: 0, End: 10}, {Number: 2, Start: 12, End: 20}, {Number: 3, Start: 22, End: 30}}
+	tests := []struct {
+		start, end int
+		want       []Segment
+	}{
+		{0, 30, segments},
+		{5, 15, []Segment{{Number: 1, Start: 5, End: 10}, {Number: 2, Start: 12, End: 15}}},
+		{10, 12, nil},
+		{14, 16, []Segment{{Number: 2, Start: 14, End: 16}}},
+	}
+	for _, tt := range tests {
+		if got := clipSegments(segments, tt.start, tt.end); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("clipSegments(%d, %d) = %+v, want %+v", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package chunk
+
+import (
+	"fmt"
+
+	"docsense/api/internal/ingest/tokenize"
+)
+
+// Chunking strategies.
+const (
+	// StrategyFixed windows words without regard to text structure.
+	StrategyFixed = "fixed"
+	// StrategySentence packs whole sentences.
+	StrategySentence = "sentence"
+	// StrategyParagraph packs whole paragraphs, splitting long ones by
+	// sentence.
+	StrategyParagraph = "paragraph"
+	// StrategyRecursive splits on paragraphs, then lines, then sentences,
+	// then words, going finer only where a piece is still too large.
+	StrategyRecursive = "recursive"
+)
+
+// Default options. With the Words tokenizer they match the original fixed
+// windowing.
+const (
+	DefaultStrategy = StrategyFixed
+	DefaultSize     = 700
+	DefaultOverlap  = 100
+)
+
+// Options selects a chunking strategy and its parameters. Size and Overlap
+// are measured in tokens of the tokenizer passed to New, whose name is
+// recorded in Tokenizer.
+//
+// A non-zero ParentSize makes chunking hierarchical (see ChunkHierarchy):
+// the text is first cut into parent chunks of ParentSize tokens, without
+// overlap, and each parent into chunks of Size.
+type Options struct {
+	Strategy   string `json:"strategy"`
+	Size       int    `json:"size"`
+	Overlap    int    `json:"overlap"`
+	ParentSize int    `json:"parent_size,omitempty"`
+	Tokenizer  string `json:"tokenizer,omitempty"`
+}
+
+// DefaultOptions returns the options ChunkText uses.
+func DefaultOptions() Options {
+	return Options{Strategy: DefaultStrategy, Size: DefaultSize, Overlap: DefaultOverlap}
+}
+
+// Validate reports whether o describes a usable chunker.
+func (o Options) Validate() error {
+	switch o.Strategy {
+	case StrategyFixed, StrategySentence, StrategyParagraph, StrategyRecursive:
+	default:
+		return fmt.Errorf("unknown chunking strategy %q", o.Strategy)
+	}
+	if o.Size <= 0 {
+		return fmt.Errorf("chunk size must be positive, got %d", o.Size)
+	}
+	if o.Overlap < 0 || o.Overlap >= o.Size {
+		return fmt.Errorf("chunk overlap must be in [0, %d), got %d", o.Size, o.Overlap)
+	}
+	if o.ParentSize != 0 && o.ParentSize <= o.Size {
+		return fmt.Errorf("parent chunk size must be 0 or above %d, got %d", o.Size, o.ParentSize)
+	}
+	return nil
+}
+
+// NewParent returns the Chunker for o's parent chunks, or nil when o is
+// not hierarchical.
+func NewParent(o Options, tok tokenize.Tokenizer) (Chunker, error) {
+	if o.ParentSize == 0 {
+		return nil, nil
+	}
+	return New(Options{Strategy: o.Strategy, Size: o.ParentSize}, tok)
+}
+
+// New returns the Chunker for o, sizing chunks and counting their tokens
+// with tok.
+func New(o Options, tok tokenize.Tokenizer) (Chunker, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	switch o.Strategy {
+	case StrategySentence:
+		return unitChunker{size: o.Size, overlap: o.Overlap, tok: tok, split: splitSentences}, nil
+	case StrategyParagraph:
+		return unitChunker{size: o.Size, overlap: o.Overlap, tok: tok, split: paragraphSplitter(o.Size, tok)}, nil
+	case StrategyRecursive:
+		return unitChunker{size: o.Size, overlap: o.Overlap, tok: tok, split: recursiveSplitter(o.Size, tok)}, nil
+	default:
+		return fixedChunker{size: o.Size, overlap: o.Overlap, tok: tok}, nil
+	}
+}
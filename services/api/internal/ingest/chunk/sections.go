@@ -0,0 +1,139 @@
+package chunk
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	uuid "github.com/google/uuid"
+)
+
+var (
+	atxHeading    = regexp.MustCompile(`^ {0,3}(#{1,6})\s+(.*?)(?:\s+#+)?\s*$`)
+	setextHeading = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	fence         = regexp.MustCompile("^ {0,3}(```|~~~)")
+)
+
+// SplitSections splits segments of text that marks sections with Markdown
+// headings into one segment per section, each carrying its heading
+// breadcrumb in SectionPath. The heading stack carries over from one
+// segment to the next, so a section may continue across pages.
+//
+// A section spans its heading line and body, so the heading is searchable.
+// Sections with no text besides their heading are dropped; their heading
+// still appears in the paths of their subsections.
+func SplitSections(text string, segments []Segment) []Segment {
+	var (
+		out   []Segment
+		stack []string // stack[i] is the current heading at level i+1
+	)
+	for _, seg := range segments {
+		var (
+			lines   = lineSpans(text, span{seg.Start, seg.End})
+			cur     = span{seg.Start, seg.Start}
+			hasBody bool
+			inFence bool
+		)
+		flush := func() {
+			if s, ok := trimSpan(text, cur); ok && hasBody {
+				out = append(out, Segment{
+					Number:      seg.Number,
+					Title:       seg.Title,
+					Start:       s.start,
+					End:         s.end,
+					SectionPath: sectionPath(stack),
+				})
+			}
+			hasBody = false
+		}
+
+		for i := 0; i < len(lines); i++ {
+			line := text[lines[i].start:lines[i].end]
+			if fence.MatchString(line) {
+				inFence = !inFence
+			}
+			level, title := 0, ""
+			heading := lines[i]
+			if !inFence {
+				if m := atxHeading.FindStringSubmatch(line); m != nil {
+					level, title = len(m[1]), m[2]
+				} else if i+1 < len(lines) && strings.TrimSpace(line) != "" &&
+					setextHeading.MatchString(text[lines[i+1].start:lines[i+1].end]) &&
+					(i == 0 || strings.TrimSpace(text[lines[i-1].start:lines[i-1].end]) == "") {
+					level, title = 1, strings.TrimSpace(line)
+					if strings.HasPrefix(strings.TrimSpace(text[lines[i+1].start:lines[i+1].end]), "-") {
+						level = 2
+					}
+					i++ // the underline
+					heading.end = lines[i].end
+				}
+			}
+			if level == 0 || title == "" {
+				cur.end = lines[i].end
+				hasBody = hasBody || strings.TrimSpace(line) != ""
+				continue
+			}
+
+			flush()
+			if len(stack) >= level {
+				stack = stack[:level-1]
+			}
+			for len(stack) < level-1 {
+				stack = append(stack, "")
+			}
+			stack = append(stack, strings.Join(strings.Fields(title), " "))
+			cur = heading
+		}
+		flush()
+	}
+	return out
+}
+
+// sectionPath returns the non-empty headings of stack (levels may be
+// skipped, e.g. "#" followed by "###").
+func sectionPath(stack []string) []string {
+	var path []string
+	for _, h := range stack {
+		if h != "" {
+			path = append(path, h)
+		}
+	}
+	return path
+}
+
+// ChunkSections chunks each run of consecutive segments that share a
+// section path separately, so no chunk crosses a section boundary, and
+// labels the chunks with their section path.
+func ChunkSections(c Chunker, documentID uuid.UUID, text string, segments []Segment) ([]Chunk, error) {
+	var out []Chunk
+	for start := 0; start < len(segments); {
+		end := start + 1
+		for end < len(segments) && slices.Equal(segments[end].SectionPath, segments[start].SectionPath) {
+			end++
+		}
+		chunks, err := c.Chunk(documentID, text, segments[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range chunks {
+			ch.Index = len(out)
+			ch.SectionPath = segments[start].SectionPath
+			out = append(out, ch)
+		}
+		start = end
+	}
+	return out, nil
+}
+
+// lineSpans returns the lines of s without their line breaks.
+func lineSpans(text string, s span) []span {
+	var out []span
+	for start := s.start; ; {
+		i := strings.IndexByte(text[start:s.end], '\n')
+		if i < 0 {
+			return append(out, span{start, s.end})
+		}
+		out = append(out, span{start, start + i})
+		start += i + 1
+	}
+}
@@ -0,0 +1,117 @@
+package chunk
+
+import (
+	"reflect"
+	"testing"
+
+	"docsense/api/internal/ingest/tokenize"
+
+	uuid "github.com/google/uuid"
+)
+
+func TestSplitSections(t *testing.T) {
+	type section struct {
+		path []string
+		text string
+	}
+	tests := []struct {
+		name string
+		text string
+		want []section
+	}{
+		{
+			name: "nested atx headings",
+			text: "Preface text.\n\n# Install\n\nIntro.\n\n## Linux\n\nUse apt.\n\n## macOS\n\nUse brew.\n\n# Usage\n\nRun it.",
+			want: []section{
+				{nil, "Preface text."},
+				{[]string{"Install"}, "# Install\n\nIntro."},
+				{[]string{"Install", "Linux"}, "## Linux\n\nUse apt."},
+				{[]string{"Install", "macOS"}, "## macOS\n\nUse brew."},
+				{[]string{"Usage"}, "# Usage\n\nRun it."},
+			},
+		},
+		{
+			name: "setext headings and skipped levels",
+			text: "Guide\n=====\n\n### Deep\n\nBody.\n\nNext\n----\n\nMore.",
+			want: []section{
+				{[]string{"Guide", "Deep"}, "### Deep\n\nBody."},
+				{[]string{"Guide", "Next"}, "Next\n----\n\nMore."},
+			},
+		},
+		{
+			name: "headings in code fences are ignored",
+			text: "# Code\n\n```\n# not a heading\n```",
+			want: []section{
+				{[]string{"Code"}, "# Code\n\n```\n# not a heading\n```"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segs := SplitSections(tt.text, []Segment{{Start: 0, End: len(tt.text)}})
+			var got []section
+			for _, s := range segs {
+				got = append(got, section{s.SectionPath, tt.text[s.Start:s.End]})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitSections:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitSectionsAcrossSegments(t *testing.T) {
+	// A section that starts on one page continues on the next.
+	page1 := "# Chapter\n\nFirst page."
+	page2 := "Second page.\n\n## Part\n\nMore."
+	text := page1 + "\n\n" + page2
+	segs := SplitSections(text, []Segment{
+		{Number: 1, Start: 0, End: len(page1)},
+		{Number: 2, Start: len(page1) + 2, End: len(text)},
+	})
+	want := []struct {
+		number int
+		path   []string
+	}{
+		{1, []string{"Chapter"}},
+		{2, []string{"Chapter"}},
+		{2, []string{"Chapter", "Part"}},
+	}
+	if len(segs) != len(want) {
+		t.Fatalf("got %d sections, want %d: %+v", len(segs), len(want), segs)
+	}
+	for i, s := range segs {
+		if s.Number != want[i].number || !reflect.DeepEqual(s.SectionPath, want[i].path) {
+			t.Errorf("section %d: page %d path %q, want page %d path %q", i, s.Number, s.SectionPath, want[i].number, want[i].path)
+		}
+	}
+}
+
+func TestChunkSectionsStayInSection(t *testing.T) {
+	text := "# A\n\none two three four five six seven\n\n# B\n\neight nine ten"
+	segs := SplitSections(text, []Segment{{Start: 0, End: len(text)}})
+	c, err := New(Options{Strategy: StrategyFixed, Size: 50}, tokenize.Words{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := ChunkSections(c, uuid.Nil, text, segs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, text, chunks, 50, 0, tokenize.Words{})
+	want := []struct {
+		content string
+		path    []string
+	}{
+		{"# A\n\none two three four five six seven", []string{"A"}},
+		{"# B\n\neight nine ten", []string{"B"}},
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d", len(chunks), len(want))
+	}
+	for i, ch := range chunks {
+		if ch.Content != want[i].content || !reflect.DeepEqual(ch.SectionPath, want[i].path) {
+			t.Errorf("chunk %d = %q %q, want %q %q", i, ch.Content, ch.SectionPath, want[i].content, want[i].path)
+		}
+	}
+}
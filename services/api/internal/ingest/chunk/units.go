@@ -0,0 +1,224 @@
+package chunk
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+
+	"docsense/api/internal/ingest/tokenize"
+
+	uuid "github.com/google/uuid"
+)
+
+// unit is a structural span of the text with what packing needs to know
+// about it. tokens counts the unit with the text separating it from the
+// previous one, as it appears inside a chunk.
+type unit struct {
+	span
+	tokens  int
+	segment *Segment
+}
+
+// unitChunker packs structural units (sentences, paragraphs, ...) into
+// chunks of at most size tokens, never splitting a unit unless it alone is
+// larger than a chunk. Consecutive chunks share trailing units totalling at
+// most overlap tokens.
+type unitChunker struct {
+	size, overlap int
+	tok           tokenize.Tokenizer
+	// split returns the units of text within a segment's span, trimmed and
+	// in order.
+	split func(text string, s span) []span
+}
+
+func (c unitChunker) Chunk(documentID uuid.UUID, text string, segments []Segment) ([]Chunk, error) {
+	var units []unit
+	add := func(s span, seg *Segment) {
+		gapStart := s.start
+		if len(units) > 0 {
+			gapStart = units[len(units)-1].end
+		}
+		units = append(units, unit{s, c.tok.Count(text[gapStart:s.end]), seg})
+	}
+	for i := range segments {
+		for _, s := range c.split(text, span{segments[i].Start, segments[i].End}) {
+			if c.tok.Count(text[s.start:s.end]) <= c.size {
+				add(s, &segments[i])
+				continue
+			}
+			// A unit larger than a chunk is cut into chunk-sized word runs.
+			for _, run := range wordRuns(text, s, c.size, c.tok) {
+				add(run, &segments[i])
+			}
+		}
+	}
+
+	var chunks []Chunk
+	for start := 0; start < len(units); {
+		end, tokens := start, 0
+		for end < len(units) && (end == start || tokens+units[end].tokens <= c.size) {
+			tokens += units[end].tokens
+			end++
+		}
+
+		content := text[units[start].start:units[end-1].end]
+		chunks = append(chunks, Chunk{
+			DocumentID:   documentID,
+			Index:        len(chunks),
+			Content:      content,
+			TokenCount:   c.tok.Count(content),
+			StartOffset:  units[start].start,
+			EndOffset:    units[end-1].end,
+			SegmentStart: units[start].segment.Number,
+			SegmentEnd:   units[end-1].segment.Number,
+			SegmentTitle: units[start].segment.Title,
+		})
+		if end == len(units) {
+			break
+		}
+
+		// Start the next chunk with as many trailing units as fit in the
+		// overlap while leaving room for the next new unit, always moving
+		// forward by at least one unit.
+		next, shared := end, 0
+		for next-1 > start {
+			n := shared + units[next-1].tokens
+			if n > c.overlap || n+units[end].tokens > c.size {
+				break
+			}
+			next--
+			shared += units[next].tokens
+		}
+		start = next
+	}
+	return chunks, nil
+}
+
+// wordRuns cuts s into runs of whole words of at most size tokens each; a
+// single word longer than that is a run of its own.
+func wordRuns(text string, s span, size int, tok tokenize.Tokenizer) []span {
+	var runs []span
+	run, n := span{-1, -1}, 0
+	for _, w := range wordSpans(text, s) {
+		wn := tok.Count(" " + text[w.start:w.end])
+		if run.start >= 0 && n+wn > size {
+			runs = append(runs, run)
+			run, n = span{-1, -1}, 0
+		}
+		if run.start < 0 {
+			run.start = w.start
+		}
+		run.end = w.end
+		n += wn
+	}
+	if run.start >= 0 {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+var (
+	paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+	lineBreak      = regexp.MustCompile(`\n`)
+	// sentenceEnd matches terminal punctuation, optional closing quotes or
+	// brackets, and the whitespace after them.
+	sentenceEnd = regexp.MustCompile(`[.!?…]+["'”’)\]]*\s+`)
+)
+
+// splitAt splits s at the matches of sep, returning the trimmed, non-empty
+// parts.
+func splitAt(text string, s span, sep *regexp.Regexp) []span {
+	var out []span
+	last := s.start
+	for _, m := range sep.FindAllStringIndex(text[s.start:s.end], -1) {
+		if part, ok := trimSpan(text, span{last, s.start + m[0]}); ok {
+			out = append(out, part)
+		}
+		last = s.start + m[1]
+	}
+	if part, ok := trimSpan(text, span{last, s.end}); ok {
+		out = append(out, part)
+	}
+	return out
+}
+
+// splitParagraphs splits s at blank lines.
+func splitParagraphs(text string, s span) []span {
+	return splitAt(text, s, paragraphBreak)
+}
+
+// paragraphSplitter returns a splitter that splits text at blank lines and
+// paragraphs longer than size tokens into sentences.
+func paragraphSplitter(size int, tok tokenize.Tokenizer) func(string, span) []span {
+	return func(text string, s span) []span {
+		var out []span
+		for _, para := range splitParagraphs(text, s) {
+			if tok.Count(text[para.start:para.end]) <= size {
+				out = append(out, para)
+				continue
+			}
+			out = append(out, sentences(text, para)...)
+		}
+		return out
+	}
+}
+
+// splitSentences splits s into the sentences of its paragraphs.
+func splitSentences(text string, s span) []span {
+	var out []span
+	for _, para := range splitParagraphs(text, s) {
+		out = append(out, sentences(text, para)...)
+	}
+	return out
+}
+
+// sentences splits a paragraph after terminal punctuation that is followed by
+// something other than a lowercase letter, so "e.g. this" stays together.
+func sentences(text string, s span) []span {
+	var out []span
+	last := s.start
+	for _, m := range sentenceEnd.FindAllStringIndex(text[s.start:s.end], -1) {
+		end := s.start + m[1]
+		next, _ := utf8.DecodeRuneInString(text[end:s.end])
+		if unicode.IsLower(next) {
+			continue
+		}
+		if sent, ok := trimSpan(text, span{last, end}); ok {
+			out = append(out, sent)
+		}
+		last = end
+	}
+	if sent, ok := trimSpan(text, span{last, s.end}); ok {
+		out = append(out, sent)
+	}
+	return out
+}
+
+// recursiveSplitter returns a splitter that splits text at the coarsest
+// separator (paragraphs, then lines, then sentences) that brings every piece
+// within size tokens. Pieces still too large are left for unitChunker to cut
+// into word runs.
+func recursiveSplitter(size int, tok tokenize.Tokenizer) func(string, span) []span {
+	levels := []func(string, span) []span{
+		splitParagraphs,
+		func(text string, s span) []span { return splitAt(text, s, lineBreak) },
+		sentences,
+	}
+
+	var split func(text string, s span, level int) []span
+	split = func(text string, s span, level int) []span {
+		s, ok := trimSpan(text, s)
+		if !ok {
+			return nil
+		}
+		if level == len(levels) || tok.Count(text[s.start:s.end]) <= size {
+			return []span{s}
+		}
+		var out []span
+		for _, part := range levels[level](text, s) {
+			out = append(out, split(text, part, level+1)...)
+		}
+		return out
+	}
+	return func(text string, s span) []span { return split(text, s, 0) }
+}
@@ -0,0 +1,35 @@
+package extract
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// zipEntries indexes the files of a zip archive by name.
+func zipEntries(zr *zip.Reader) map[string]*zip.File {
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	return files
+}
+
+// openZipEntry opens a named archive member.
+func openZipEntry(files map[string]*zip.File, name string) (io.ReadCloser, error) {
+	f, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("missing archive entry %q", name)
+	}
+	return f.Open()
+}
+
+func decodeZipXML(files map[string]*zip.File, name string, v any) error {
+	rc, err := openZipEntry(files, name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
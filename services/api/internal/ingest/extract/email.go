@@ -0,0 +1,447 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Email MIME types. Neither has a single registered type for mbox; this is
+// the one most mail tools use.
+const (
+	MIMETypeEML  = "message/rfc822"
+	MIMETypeMBOX = "application/mbox"
+)
+
+// SegmentKindMessage marks segments extracted from email messages.
+const SegmentKindMessage = "message"
+
+// Attachment is a file carried inside another document (e.g. an email
+// attachment) that is ingested as a document of its own.
+type Attachment struct {
+	Filename string
+	MIMEType string
+	Data     []byte
+
+	// Message and MessageID identify the message of an mbox mailbox the
+	// attachment came from: its 1-based number (the segment number) and
+	// Message-ID header. Message is 0 for single messages.
+	Message   int
+	MessageID string
+}
+
+// emailMessage is a parsed message: its headers, the readable body and any
+// attachments in a supported format.
+type emailMessage struct {
+	From      string
+	To        string
+	Cc        string
+	Subject   string
+	Date      time.Time
+	MessageID string
+
+	Body        string
+	Attachments []Attachment
+}
+
+func (m *emailMessage) headerMetadata() map[string]any {
+	meta := map[string]any{}
+	for k, v := range map[string]string{
+		"from":       m.From,
+		"to":         m.To,
+		"cc":         m.Cc,
+		"subject":    m.Subject,
+		"message_id": m.MessageID,
+	} {
+		if v != "" {
+			meta[k] = v
+		}
+	}
+	if !m.Date.IsZero() {
+		meta["date"] = m.Date.UTC().Format(time.RFC3339)
+	}
+	return meta
+}
+
+// segment renders the message as text. Headers lead so sender, recipients
+// and subject are searchable alongside the body.
+func (m *emailMessage) segment(number int) Segment {
+	var b strings.Builder
+	for _, h := range []struct{ name, value string }{
+		{"Subject", m.Subject},
+		{"From", m.From},
+		{"To", m.To},
+		{"Cc", m.Cc},
+	} {
+		if h.value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", h.name, h.value)
+		}
+	}
+	if !m.Date.IsZero() {
+		fmt.Fprintf(&b, "Date: %s\n", m.Date.Format(time.RFC1123Z))
+	}
+	if body := strings.TrimSpace(m.Body); body != "" {
+		b.WriteString("\n")
+		b.WriteString(body)
+		b.WriteString("\n")
+	}
+	if len(m.Attachments) > 0 {
+		names := make([]string, len(m.Attachments))
+		for i, a := range m.Attachments {
+			names[i] = a.Filename
+		}
+		fmt.Fprintf(&b, "\nAttachments: %s\n", strings.Join(names, ", "))
+	}
+	return Segment{
+		Kind:   SegmentKindMessage,
+		Number: number,
+		Title:  m.Subject,
+		Text:   strings.TrimRight(b.String(), "\n"),
+	}
+}
+
+// extractEML reads a single RFC 5322 message. The subject becomes the
+// document title and the headers its metadata.
+func extractEML(filePath string) (*Result, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("open eml: %w", err)
+	}
+	defer f.Close()
+
+	msg, err := parseEmail(f)
+	if err != nil {
+		return nil, fmt.Errorf("eml parse: %w", err)
+	}
+	res := newSegmentedResult([]Segment{msg.segment(1)})
+	res.Title = msg.Subject
+	res.Metadata = msg.headerMetadata()
+	res.Attachments = msg.Attachments
+	return res, nil
+}
+
+// extractMBOX reads every message of an mbox mailbox, one segment per
+// message. Per-message headers are kept in metadata under "messages".
+// Messages that cannot be parsed are skipped and counted in metadata
+// ("skipped_messages"); the mailbox fails only if no message parses.
+func extractMBOX(filePath string) (*Result, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("open mbox: %w", err)
+	}
+	defer f.Close()
+
+	var (
+		segments    []Segment
+		headers     []map[string]any
+		attachments []Attachment
+		skipped     int
+		firstErr    error
+	)
+	err = splitMBOX(f, func(raw []byte) error {
+		msg, err := parseEmail(bytes.NewReader(raw))
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("message %d: %w", len(segments)+skipped+1, err)
+			}
+			skipped++
+			return nil
+		}
+		number := len(segments) + 1
+		segments = append(segments, msg.segment(number))
+		headers = append(headers, msg.headerMetadata())
+		for _, a := range msg.Attachments {
+			a.Message, a.MessageID = number, msg.MessageID
+			attachments = append(attachments, a)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mbox parse: %w", err)
+	}
+	if len(segments) == 0 && firstErr != nil {
+		return nil, fmt.Errorf("mbox parse: %w", firstErr)
+	}
+
+	res := newSegmentedResult(segments)
+	res.Metadata = map[string]any{
+		"message_count": len(segments),
+		"messages":      headers,
+	}
+	if skipped > 0 {
+		res.Metadata["skipped_messages"] = skipped
+	}
+	res.Attachments = attachments
+	return res, nil
+}
+
+var mboxEscapedFrom = regexp.MustCompile(`^>+From `)
+
+// splitMBOX calls fn with the raw bytes of each message in an mbox stream.
+// Messages start at "From " lines; ">From " quoting (mboxrd) is undone.
+func splitMBOX(r io.Reader, fn func(raw []byte) error) error {
+	br := bufio.NewReader(r)
+	var (
+		cur     bytes.Buffer
+		started bool
+	)
+	flush := func() error {
+		if !started || len(bytes.TrimSpace(cur.Bytes())) == 0 {
+			return nil
+		}
+		return fn(cur.Bytes())
+	}
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case bytes.HasPrefix(line, []byte("From ")):
+				if err := flush(); err != nil {
+					return err
+				}
+				cur.Reset()
+				started = true
+			case started:
+				if mboxEscapedFrom.Match(line) {
+					line = line[1:]
+				}
+				cur.Write(line)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+var headerDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+func decodeHeader(v string) string {
+	if decoded, err := headerDecoder.DecodeHeader(v); err == nil {
+		v = decoded
+	}
+	return strings.Join(strings.Fields(v), " ")
+}
+
+func parseEmail(r io.Reader) (*emailMessage, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	m := &emailMessage{
+		From:      decodeHeader(msg.Header.Get("From")),
+		To:        decodeHeader(msg.Header.Get("To")),
+		Cc:        decodeHeader(msg.Header.Get("Cc")),
+		Subject:   decodeHeader(msg.Header.Get("Subject")),
+		MessageID: strings.TrimSpace(msg.Header.Get("Message-Id")),
+	}
+	if d, err := msg.Header.Date(); err == nil {
+		m.Date = d
+	}
+
+	var p emailPartWalker
+	if err := p.walk(mimeHeader(msg.Header), msg.Body); err != nil {
+		return nil, err
+	}
+	m.Body = p.body.String()
+	m.Attachments = p.attachments
+	return m, nil
+}
+
+// mimeHeader is the subset of header access the part walker needs; both
+// mail.Header and textproto.MIMEHeader provide it.
+type mimeHeader interface {
+	Get(key string) string
+}
+
+type emailPartWalker struct {
+	body        strings.Builder
+	attachments []Attachment
+}
+
+func (w *emailPartWalker) walk(h mimeHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	body = decodeTransferEncoding(h.Get("Content-Transfer-Encoding"), body)
+
+	disposition, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	filename := decodeHeader(dparams["filename"])
+	if filename == "" {
+		filename = decodeHeader(params["name"])
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		return w.walkMultipart(mediaType, params["boundary"], body)
+
+	case mediaType == MIMETypeEML:
+		// Forwarded or attached messages become child documents.
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		if filename == "" {
+			filename = "attached-message.eml"
+		}
+		w.addAttachment(filename, MIMETypeEML, data)
+		return nil
+
+	case disposition != "attachment" && (mediaType == "text/plain" || mediaType == "text/html"):
+		text, err := decodeTextPart(mediaType, params["charset"], body)
+		if err != nil {
+			return err
+		}
+		w.appendBody(text)
+		return nil
+
+	default:
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		w.addAttachment(filename, mediaType, data)
+		return nil
+	}
+}
+
+// walkMultipart walks the parts of a multipart body. For
+// multipart/alternative only one rendering is used: plain text when it has
+// content, otherwise the HTML converted to text.
+func (w *emailPartWalker) walkMultipart(mediaType, boundary string, body io.Reader) error {
+	if boundary == "" {
+		return fmt.Errorf("%s without boundary", mediaType)
+	}
+	mr := multipart.NewReader(body, boundary)
+
+	if mediaType != "multipart/alternative" {
+		for {
+			part, err := mr.NextPart()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := w.walk(part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	var plain, rich *emailPartWalker
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var alt emailPartWalker
+		if err := alt.walk(part.Header, part); err != nil {
+			return err
+		}
+		ct, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch {
+		case ct != "text/plain":
+			if rich == nil {
+				rich = &alt
+			}
+		case plain == nil && strings.TrimSpace(alt.body.String()) != "":
+			plain = &alt
+		}
+	}
+	chosen := plain
+	if chosen == nil {
+		chosen = rich
+	}
+	if chosen != nil {
+		w.appendBody(chosen.body.String())
+		w.attachments = append(w.attachments, chosen.attachments...)
+	}
+	return nil
+}
+
+func (w *emailPartWalker) appendBody(text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if w.body.Len() > 0 {
+		w.body.WriteString("\n\n")
+	}
+	w.body.WriteString(text)
+}
+
+// addAttachment keeps attachments we can ingest; everything else (images,
+// signatures, calendar invites, ...) is dropped.
+func (w *emailPartWalker) addAttachment(filename, mediaType string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	mimeType := MIMETypeForFilename(filename)
+	if mimeType == "" && IsSupportedMIMEType(mediaType) {
+		mimeType = mediaType
+	}
+	if mimeType == "" {
+		return
+	}
+	if filename == "" {
+		filename = "attachment"
+	}
+	w.attachments = append(w.attachments, Attachment{
+		Filename: path.Base(filename),
+		MIMEType: mimeType,
+		Data:     data,
+	})
+}
+
+func decodeTransferEncoding(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
+
+func decodeTextPart(mediaType, charsetLabel string, body io.Reader) (string, error) {
+	if charsetLabel != "" {
+		if r, err := charset.NewReaderLabel(charsetLabel, body); err == nil {
+			body = r
+		}
+	}
+	if mediaType == "text/html" {
+		doc, err := htmlToText(body, false)
+		if err != nil {
+			return "", err
+		}
+		return doc.Text, nil
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
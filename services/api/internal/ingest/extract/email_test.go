@@ -0,0 +1,203 @@
+package extract
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitMBOX(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			name: "two messages",
+			in:   "From a@x Mon Jan 1 00:00:00 2024\nSubject: one\n\nbody one\nFrom b@x Mon Jan 1 00:00:00 2024\nSubject: two\n\nbody two\n",
+			want: []string{"Subject: one\n\nbody one\n", "Subject: two\n\nbody two\n"},
+		},
+		{
+			name: "mboxrd quoting is undone",
+			in:   "From a@x Mon Jan 1 00:00:00 2024\nSubject: q\n\n>From here\n>>From there\n",
+			want: []string{"Subject: q\n\nFrom here\n>From there\n"},
+		},
+		{
+			name: "text before the first From line is ignored",
+			in:   "junk\nFrom a@x Mon Jan 1 00:00:00 2024\nSubject: s\n\nb",
+			want: []string{"Subject: s\n\nb"},
+		},
+		{
+			name: "empty",
+			in:   "",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := splitMBOX(strings.NewReader(tt.in), func(raw []byte) error {
+				got = append(got, string(raw))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("splitMBOX: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+const (
+	mboxGood = "From a@x Mon Jan 1 00:00:00 2024\n" +
+		"From: Alice <a@x>\n" +
+		"Subject: Report\n" +
+		"Message-ID: <1@x>\n" +
+		"Content-Type: multipart/mixed; boundary=b\n" +
+		"\n" +
+		"--b\n" +
+		"Content-Type: text/plain\n" +
+		"\n" +
+		"See attached.\n" +
+		"--b\n" +
+		"Content-Type: text/plain\n" +
+		"Content-Disposition: attachment; filename=notes.txt\n" +
+		"\n" +
+		"attached notes\n" +
+		"--b--\n"
+	mboxMalformed = "From c@x Mon Jan 1 00:00:00 2024\n" +
+		"Subject: broken\n" +
+		"this header line has no colon\n" +
+		"\n" +
+		"body\n"
+	mboxPlain = "From d@x Mon Jan 1 00:00:00 2024\n" +
+		"Subject: Second\n" +
+		"Message-ID: <2@x>\n" +
+		"Content-Type: multipart/mixed; boundary=c\n" +
+		"\n" +
+		"--c\n" +
+		"Content-Type: text/plain\n" +
+		"\n" +
+		"Hello.\n" +
+		"--c\n" +
+		"Content-Type: text/markdown\n" +
+		"Content-Disposition: attachment; filename=readme.md\n" +
+		"\n" +
+		"# Readme\n" +
+		"--c--\n"
+)
+
+func TestExtractMBOX(t *testing.T) {
+	tests := []struct {
+		name         string
+		mbox         string
+		wantErr      bool
+		wantMessages int
+		wantSkipped  int
+		wantAttach   []Attachment
+	}{
+		{
+			name:         "attachments record their message",
+			mbox:         mboxGood + mboxPlain,
+			wantMessages: 2,
+			wantAttach: []Attachment{
+				{Filename: "notes.txt", MIMEType: "text/plain", Message: 1, MessageID: "<1@x>"},
+				{Filename: "readme.md", MIMEType: "text/markdown", Message: 2, MessageID: "<2@x>"},
+			},
+		},
+		{
+			name:         "malformed messages are skipped",
+			mbox:         mboxMalformed + mboxGood + mboxMalformed + mboxPlain,
+			wantMessages: 2,
+			wantSkipped:  2,
+			wantAttach: []Attachment{
+				{Filename: "notes.txt", MIMEType: "text/plain", Message: 1, MessageID: "<1@x>"},
+				{Filename: "readme.md", MIMEType: "text/markdown", Message: 2, MessageID: "<2@x>"},
+			},
+		},
+		{
+			name:    "no message parses",
+			mbox:    mboxMalformed,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "in.mbox")
+			if err := os.WriteFile(path, []byte(tt.mbox), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			res, err := extractMBOX(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("extractMBOX: %v", err)
+			}
+			if got := res.Metadata["message_count"]; got != tt.wantMessages {
+				t.Errorf("message_count = %v, want %d", got, tt.wantMessages)
+			}
+			if len(res.Segments) != tt.wantMessages {
+				t.Errorf("got %d segments, want %d", len(res.Segments), tt.wantMessages)
+			}
+			skipped, _ := res.Metadata["skipped_messages"].(int)
+			if skipped != tt.wantSkipped {
+				t.Errorf("skipped_messages = %d, want %d", skipped, tt.wantSkipped)
+			}
+			for i := range res.Attachments {
+				res.Attachments[i].Data = nil
+			}
+			if !reflect.DeepEqual(res.Attachments, tt.wantAttach) {
+				t.Errorf("attachments = %+v, want %+v", res.Attachments, tt.wantAttach)
+			}
+		})
+	}
+}
+
+func TestParseEmailAlternative(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{
+			name: "plain text preferred",
+			msg: "Content-Type: multipart/alternative; boundary=a\n\n" +
+				"--a\nContent-Type: text/plain\n\nplain body\n" +
+				"--a\nContent-Type: text/html\n\n<p>html body</p>\n" +
+				"--a--\n",
+			want: "plain body",
+		},
+		{
+			name: "html when plain is empty",
+			msg: "Content-Type: multipart/alternative; boundary=a\n\n" +
+				"--a\nContent-Type: text/plain\n\n \n" +
+				"--a\nContent-Type: text/html\n\n<p>html body</p>\n" +
+				"--a--\n",
+			want: "html body",
+		},
+		{
+			name: "quoted-printable",
+			msg:  "Content-Type: text/plain; charset=utf-8\nContent-Transfer-Encoding: quoted-printable\n\ncaf=C3=A9 =\nopen\n",
+			want: "café open",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := parseEmail(strings.NewReader(tt.msg))
+			if err != nil {
+				t.Fatalf("parseEmail: %v", err)
+			}
+			if got := strings.TrimSpace(m.Body); got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
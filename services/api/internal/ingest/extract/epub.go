@@ -0,0 +1,292 @@
+package extract
+
+import (
+	"archive/zip"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// SegmentKindChapter marks segments extracted from e-book chapters.
+const SegmentKindChapter = "chapter"
+
+// MIMETypeEPUB is the registered EPUB media type.
+const MIMETypeEPUB = "application/epub+zip"
+
+type opfPackage struct {
+	Metadata struct {
+		Titles     []string `xml:"title"`
+		Creators   []string `xml:"creator"`
+		Languages  []string `xml:"language"`
+		Publishers []string `xml:"publisher"`
+		Dates      []string `xml:"date"`
+		Subjects   []string `xml:"subject"`
+	} `xml:"metadata"`
+	Manifest []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine struct {
+		TOC      string `xml:"toc,attr"`
+		ItemRefs []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+// extractEPUB reads the chapters of an EPUB in spine order.
+//
+// Chapter titles come from the table of contents (EPUB 3 nav document or
+// EPUB 2 NCX), falling back to the chapter's first heading. Spine items that
+// render to no text (covers, image-only pages) are skipped, so chapter
+// numbers count only readable chapters.
+func extractEPUB(filePath string) (*Result, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("epub open: %w", err)
+	}
+	defer zr.Close()
+	files := zipEntries(&zr.Reader)
+
+	opfPath, err := epubPackagePath(files)
+	if err != nil {
+		return nil, fmt.Errorf("epub container: %w", err)
+	}
+	var pkg opfPackage
+	if err := decodeZipXML(files, opfPath, &pkg); err != nil {
+		return nil, fmt.Errorf("epub package: %w", err)
+	}
+
+	opfDir := path.Dir(opfPath)
+	type manifestItem struct{ path, mediaType string }
+	manifest := make(map[string]manifestItem, len(pkg.Manifest))
+	tocTitles := map[string]string{}
+	for _, item := range pkg.Manifest {
+		p := resolveHref(opfDir, item.Href)
+		manifest[item.ID] = manifestItem{path: p, mediaType: item.MediaType}
+		if hasToken(item.Properties, "nav") {
+			// A broken TOC only costs us chapter titles.
+			_ = readEPUBNav(files, p, tocTitles)
+		}
+	}
+	if ncx, ok := manifest[pkg.Spine.TOC]; ok && len(tocTitles) == 0 {
+		_ = readEPUBNCX(files, ncx.path, tocTitles)
+	}
+
+	var segments []Segment
+	for _, ref := range pkg.Spine.ItemRefs {
+		item, ok := manifest[ref.IDRef]
+		if !ok || !isXHTML(item.mediaType) {
+			continue
+		}
+		rc, err := openZipEntry(files, item.path)
+		if err != nil {
+			return nil, fmt.Errorf("epub chapter %s: %w", item.path, err)
+		}
+		doc, err := htmlToText(rc, true)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("epub chapter %s: %w", item.path, err)
+		}
+		if doc.Text == "" {
+			continue
+		}
+
+		title := tocTitles[item.path]
+		if title == "" {
+			title = doc.Heading
+		}
+		if title == "" {
+			title = doc.Title
+		}
+		segments = append(segments, Segment{
+			Kind:   SegmentKindChapter,
+			Number: len(segments) + 1,
+			Title:  title,
+			Text:   doc.Text,
+		})
+	}
+
+	res := newSegmentedResult(segments)
+	res.Title = firstNonEmpty(pkg.Metadata.Titles)
+	res.Markdown = true
+	res.Metadata = map[string]any{}
+	if authors := nonEmpty(pkg.Metadata.Creators); len(authors) > 0 {
+		res.Metadata["author"] = strings.Join(authors, ", ")
+		res.Metadata["authors"] = authors
+	}
+	if v := firstNonEmpty(pkg.Metadata.Languages); v != "" {
+		res.Metadata["language"] = v
+	}
+	if v := firstNonEmpty(pkg.Metadata.Publishers); v != "" {
+		res.Metadata["publisher"] = v
+	}
+	if v := firstNonEmpty(pkg.Metadata.Dates); v != "" {
+		res.Metadata["published"] = v
+	}
+	if subjects := nonEmpty(pkg.Metadata.Subjects); len(subjects) > 0 {
+		res.Metadata["subjects"] = subjects
+	}
+	return res, nil
+}
+
+// epubPackagePath finds the OPF package document via META-INF/container.xml.
+func epubPackagePath(files map[string]*zip.File) (string, error) {
+	var container struct {
+		RootFiles []struct {
+			FullPath  string `xml:"full-path,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := decodeZipXML(files, "META-INF/container.xml", &container); err != nil {
+		return "", err
+	}
+	for _, rf := range container.RootFiles {
+		if rf.MediaType == "" || rf.MediaType == "application/oebps-package+xml" {
+			return rf.FullPath, nil
+		}
+	}
+	return "", fmt.Errorf("no package document")
+}
+
+// readEPUBNav collects chapter titles from an EPUB 3 navigation document:
+// the links of its <nav epub:type="toc">.
+func readEPUBNav(files map[string]*zip.File, navPath string, titles map[string]string) error {
+	rc, err := openZipEntry(files, navPath)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	root, err := html.Parse(rc)
+	if err != nil {
+		return err
+	}
+
+	var toc *html.Node
+	var find func(n *html.Node)
+	find = func(n *html.Node) {
+		if toc != nil {
+			return
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Nav {
+			for _, a := range n.Attr {
+				if a.Key == "epub:type" && hasToken(a.Val, "toc") {
+					toc = n
+					return
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(root)
+	if toc == nil {
+		return nil
+	}
+
+	navDir := path.Dir(navPath)
+	var collect func(n *html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			for _, a := range n.Attr {
+				if a.Key == "href" {
+					addTOCTitle(titles, resolveHref(navDir, a.Val), nodeText(n))
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(toc)
+	return nil
+}
+
+// readEPUBNCX collects chapter titles from an EPUB 2 NCX table of contents.
+func readEPUBNCX(files map[string]*zip.File, ncxPath string, titles map[string]string) error {
+	type navPoint struct {
+		Label   string `xml:"navLabel>text"`
+		Content struct {
+			Src string `xml:"src,attr"`
+		} `xml:"content"`
+		Children []navPoint `xml:"navPoint"`
+	}
+	var ncx struct {
+		Points []navPoint `xml:"navMap>navPoint"`
+	}
+	if err := decodeZipXML(files, ncxPath, &ncx); err != nil {
+		return err
+	}
+
+	ncxDir := path.Dir(ncxPath)
+	var walk func(points []navPoint)
+	walk = func(points []navPoint) {
+		for _, p := range points {
+			addTOCTitle(titles, resolveHref(ncxDir, p.Content.Src), p.Label)
+			walk(p.Children)
+		}
+	}
+	walk(ncx.Points)
+	return nil
+}
+
+// addTOCTitle records the first TOC entry pointing into a file; later entries
+// are usually subsections of the same chapter.
+func addTOCTitle(titles map[string]string, target, label string) {
+	label = strings.Join(strings.Fields(label), " ")
+	if label == "" {
+		return
+	}
+	if _, ok := titles[target]; !ok {
+		titles[target] = label
+	}
+}
+
+// resolveHref resolves a (possibly escaped) relative href against a
+// directory in the archive, dropping any fragment.
+func resolveHref(dir, href string) string {
+	if i := strings.IndexByte(href, '#'); i >= 0 {
+		href = href[:i]
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return path.Join(dir, href)
+}
+
+func isXHTML(mediaType string) bool {
+	return mediaType == "application/xhtml+xml" || mediaType == "text/html"
+}
+
+func hasToken(list, token string) bool {
+	for _, t := range strings.Fields(list) {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+func nonEmpty(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func firstNonEmpty(values []string) string {
+	if v := nonEmpty(values); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
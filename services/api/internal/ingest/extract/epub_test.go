@@ -0,0 +1,142 @@
+package extract
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const epubContainer = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+
+func epubChapter(body string) string {
+	return `<?xml version="1.0"?><html xmlns="http://www.w3.org/1999/xhtml"><head><title>Book</title></head><body>` + body + `</body></html>`
+}
+
+func TestExtractEPUB(t *testing.T) {
+	const opf3 = `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" xmlns:dc="http://purl.org/dc/elements/1.1/" version="3.0">
+  <metadata>
+    <dc:title>The Book</dc:title>
+    <dc:creator>Ann Author</dc:creator><dc:creator>Bob Writer</dc:creator>
+    <dc:language>en</dc:language>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="cover" href="cover.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c1" href="text/ch%201.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c2" href="text/ch2.xhtml" media-type="application/xhtml+xml"/>
+    <item id="img" href="img.png" media-type="image/png"/>
+  </manifest>
+  <spine><itemref idref="cover"/><itemref idref="c1"/><itemref idref="img"/><itemref idref="c2"/></spine>
+</package>`
+	const nav = `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><body>
+  <nav epub:type="toc"><ol>
+    <li><a href="text/ch%201.xhtml">Beginnings</a><ol><li><a href="text/ch%201.xhtml#s2">Subsection</a></li></ol></li>
+  </ol></nav></body></html>`
+
+	const opf2 = `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" xmlns:dc="http://purl.org/dc/elements/1.1/" version="2.0">
+  <metadata><dc:title>Old Book</dc:title></metadata>
+  <manifest>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="c1" href="one.html" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine toc="ncx"><itemref idref="c1"/></spine>
+</package>`
+	const ncx = `<?xml version="1.0"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/"><navMap>
+  <navPoint><navLabel><text>Part One</text></navLabel><content src="one.html#top"/></navPoint>
+</navMap></ncx>`
+
+	tests := []struct {
+		name       string
+		entries    [][2]string
+		wantTitle  string
+		wantTitles []string
+		wantText   []string
+		wantMeta   map[string]any
+	}{
+		{
+			name: "epub 3 with nav",
+			entries: [][2]string{
+				{"META-INF/container.xml", epubContainer},
+				{"OEBPS/content.opf", opf3},
+				{"OEBPS/nav.xhtml", nav},
+				{"OEBPS/cover.xhtml", epubChapter(`<img src="cover.png"/>`)},
+				{"OEBPS/text/ch 1.xhtml", epubChapter(`<h1>Chapter 1</h1><p>It begins.</p>`)},
+				{"OEBPS/text/ch2.xhtml", epubChapter(`<h2>Chapter 2</h2><p>It ends.</p>`)},
+			},
+			wantTitle:  "The Book",
+			wantTitles: []string{"Beginnings", "Chapter 2"},
+			wantText:   []string{"It begins.", "It ends."},
+			wantMeta: map[string]any{
+				"author":   "Ann Author, Bob Writer",
+				"authors":  []string{"Ann Author", "Bob Writer"},
+				"language": "en",
+			},
+		},
+		{
+			name: "epub 2 with ncx",
+			entries: [][2]string{
+				{"META-INF/container.xml", epubContainer},
+				{"OEBPS/content.opf", opf2},
+				{"OEBPS/toc.ncx", ncx},
+				{"OEBPS/one.html", epubChapter(`<p>Once upon a time.</p>`)},
+			},
+			wantTitle:  "Old Book",
+			wantTitles: []string{"Part One"},
+			wantText:   []string{"Once upon a time."},
+			wantMeta:   map[string]any{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := extractEPUB(writeZip(t, "book.epub", tt.entries))
+			if err != nil {
+				t.Fatalf("extractEPUB: %v", err)
+			}
+			if res.Title != tt.wantTitle {
+				t.Errorf("Title = %q, want %q", res.Title, tt.wantTitle)
+			}
+			if !res.Markdown {
+				t.Error("Markdown not set")
+			}
+			if len(res.Segments) != len(tt.wantTitles) {
+				t.Fatalf("got %d segments, want %d: %+v", len(res.Segments), len(tt.wantTitles), res.Segments)
+			}
+			for i, s := range res.Segments {
+				if s.Kind != SegmentKindChapter || s.Number != i+1 {
+					t.Errorf("segment %d: kind %q number %d", i, s.Kind, s.Number)
+				}
+				if s.Title != tt.wantTitles[i] {
+					t.Errorf("segment %d title = %q, want %q", i, s.Title, tt.wantTitles[i])
+				}
+				if !strings.Contains(s.Text, tt.wantText[i]) {
+					t.Errorf("segment %d text = %q, want it to contain %q", i, s.Text, tt.wantText[i])
+				}
+			}
+			if !reflect.DeepEqual(res.Metadata, tt.wantMeta) {
+				t.Errorf("Metadata = %v, want %v", res.Metadata, tt.wantMeta)
+			}
+		})
+	}
+}
+
+func TestResolveHref(t *testing.T) {
+	tests := []struct {
+		dir, href, want string
+	}{
+		{"OEBPS", "text/ch1.xhtml", "OEBPS/text/ch1.xhtml"},
+		{"OEBPS/text", "../nav.xhtml#toc", "OEBPS/nav.xhtml"},
+		{"OEBPS", "ch%201.xhtml", "OEBPS/ch 1.xhtml"},
+		{".", "ch1.xhtml", "ch1.xhtml"},
+	}
+	for _, tt := range tests {
+		if got := resolveHref(tt.dir, tt.href); got != tt.want {
+			t.Errorf("resolveHref(%q, %q) = %q, want %q", tt.dir, tt.href, got, tt.want)
+		}
+	}
+}
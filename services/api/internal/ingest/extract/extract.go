@@ -2,49 +2,211 @@ package extract
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
-	pdf "github.com/ledongthuc/pdf"
+	"docsense/api/internal/ingest/normalize"
 )
 
+// Supported MIME types beyond plain text and PDF.
+const (
+	MIMETypePPTX = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	MIMETypeODP  = "application/vnd.oasis.opendocument.presentation"
+)
+
+// Extraction failures that callers report to users specifically. Extractors
+// wrap them, so test with errors.Is.
+var (
+	// ErrEncrypted means the document is encrypted and no correct password
+	// was given (or its encryption scheme is not supported).
+	ErrEncrypted = errors.New("document is encrypted")
+	// ErrCorrupt means the file is malformed and cannot be read.
+	ErrCorrupt = errors.New("document is corrupt")
+	// ErrNoTextLayer means the document has pages but no extractable text,
+	// typically a scan that needs OCR. See Result.NoTextLayer.
+	ErrNoTextLayer = errors.New("document has no text layer")
+)
+
+// Options tunes extraction of a single document.
+type Options struct {
+	// Password decrypts password-protected PDFs.
+	Password string
+}
+
+// Segment is a numbered structural unit of a document, such as a slide.
+//
+// Segments let chunks and citations point at "slide 12" instead of only a
+// chunk index.
+type Segment struct {
+	// Kind names the unit (e.g. "slide"); it is the same for every segment
+	// of a document.
+	Kind string
+	// Number is 1-based, in document order.
+	Number int
+	Title  string
+	Text   string
+}
+
+// Result is the output of an extractor.
+type Result struct {
+	// Text is the full extracted text. For segmented documents it is the
+	// segment texts joined by blank lines.
+	Text string
+
+	// Segments is empty for formats without a natural structure (e.g. .txt).
+	Segments []Segment
+
+	// Title is the document's own title (e.g. from package metadata), if any.
+	Title string
+
+	// Metadata holds format-specific document properties such as "author".
+	// It is merged into documents.metadata.
+	Metadata map[string]any
+
+	// Attachments are embedded files in a supported format, to be ingested
+	// as child documents.
+	Attachments []Attachment
+
+	// Outline is the document's own table of contents (PDF bookmarks), if any.
+	Outline []OutlineEntry
+
+	// Markdown is set when the text marks section headings Markdown-style
+	// ("# Title"), either because it is Markdown or because the extractor
+	// rendered the format's headings that way.
+	Markdown bool
+
+	// NoTextLayer is set when the document has pages but (almost) no text,
+	// e.g. a scanned PDF. The rest of the result is still filled in.
+	NoTextLayer bool
+}
+
+// segmentSeparator is placed between segment texts when building Result.Text.
+const segmentSeparator = "\n\n"
+
+func newSegmentedResult(segments []Segment) *Result {
+	return &Result{Text: JoinSegments(segments), Segments: segments}
+}
+
+// JoinSegments builds the full text of a segmented document.
+func JoinSegments(segments []Segment) string {
+	texts := make([]string, 0, len(segments))
+	for _, s := range segments {
+		texts = append(texts, s.Text)
+	}
+	return strings.Join(texts, segmentSeparator)
+}
+
+// SegmentOffsets returns the byte offset of each segment's text in
+// JoinSegments(segments).
+func SegmentOffsets(segments []Segment) []int {
+	offsets := make([]int, len(segments))
+	off := 0
+	for i, s := range segments {
+		offsets[i] = off
+		off += len(s.Text) + len(segmentSeparator)
+	}
+	return offsets
+}
+
+// MIMETypeForFilename returns the MIME type implied by a filename extension,
+// or "" when the extension is not one we extract.
+//
+// Browsers are inconsistent about the Content-Type they send for office
+// formats and Markdown, so the extension is the more reliable signal.
+func MIMETypeForFilename(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".pdf":
+		return "application/pdf"
+	case ".txt":
+		return "text/plain"
+	case ".md":
+		return "text/markdown"
+	case ".pptx":
+		return MIMETypePPTX
+	case ".odp":
+		return MIMETypeODP
+	case ".epub":
+		return MIMETypeEPUB
+	case ".eml":
+		return MIMETypeEML
+	case ".mbox":
+		return MIMETypeMBOX
+	default:
+		return ""
+	}
+}
+
+// IsSupportedMIMEType reports whether Extract handles mimeType.
+func IsSupportedMIMEType(mimeType string) bool {
+	switch mimeType {
+	case "application/pdf", "text/plain", "text/markdown",
+		MIMETypePPTX, MIMETypeODP, MIMETypeEPUB, MIMETypeEML, MIMETypeMBOX:
+		return true
+	default:
+		return false
+	}
+}
+
 // ExtractText reads plain text from supported file types.
 func ExtractText(filePath string, mimeType string) (string, error) {
+	res, err := Extract(filePath, mimeType)
+	if err != nil {
+		return "", err
+	}
+	return res.Text, nil
+}
+
+// Extract reads text, and structure where the format has one, from
+// supported file types.
+func Extract(filePath string, mimeType string) (*Result, error) {
+	return ExtractWithOptions(filePath, mimeType, Options{})
+}
+
+// ExtractWithOptions is Extract with per-document options.
+func ExtractWithOptions(filePath string, mimeType string, opts Options) (*Result, error) {
 	switch mimeType {
 	case "application/pdf":
-		// Use ledongthuc/pdf to extract plain text from PDF.
-		f, r, err := pdf.Open(filePath)
-		if err != nil {
-			return "", fmt.Errorf("pdf open: %w", err)
-		}
-		defer f.Close()
-
-		// r.GetPlainText returns an io.Reader; read it into a string.
-		pr, err := r.GetPlainText()
-		if err != nil {
-			return "", fmt.Errorf("pdf get plain text reader: %w", err)
-		}
-		b, err := io.ReadAll(pr)
-		if err != nil {
-			return "", fmt.Errorf("pdf extract: %w", err)
-		}
-		return string(b), nil
+		return extractPDF(filePath, opts.Password)
 
 	case "text/plain", "text/markdown":
 		// Read file content directly (supports .txt and .md files).
 		f, err := os.Open(filePath)
 		if err != nil {
-			return "", fmt.Errorf("open text file: %w", err)
+			return nil, fmt.Errorf("open text file: %w", err)
 		}
 		defer f.Close()
 		var buf bytes.Buffer
 		if _, err := io.Copy(&buf, f); err != nil {
-			return "", fmt.Errorf("read text file: %w", err)
+			return nil, fmt.Errorf("read text file: %w", err)
 		}
-		return buf.String(), nil
+		// Text files carry no declared encoding; detect it.
+		text, encoding := normalize.Decode(buf.Bytes())
+		return &Result{
+			Text:     text,
+			Metadata: map[string]any{"encoding": encoding},
+			Markdown: mimeType == "text/markdown",
+		}, nil
+
+	case MIMETypePPTX:
+		return extractPPTX(filePath)
+
+	case MIMETypeODP:
+		return extractODP(filePath)
+
+	case MIMETypeEPUB:
+		return extractEPUB(filePath)
+
+	case MIMETypeEML:
+		return extractEML(filePath)
+
+	case MIMETypeMBOX:
+		return extractMBOX(filePath)
 
 	default:
-		return "", fmt.Errorf("unsupported mime type: %s", mimeType)
+		return nil, fmt.Errorf("unsupported mime type: %s", mimeType)
 	}
 }
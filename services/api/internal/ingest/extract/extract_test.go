@@ -0,0 +1,53 @@
+package extract
+
+import "testing"
+
+func TestSegmentOffsets(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []Segment
+	}{
+		{"none", nil},
+		{"one", []Segment{{Text: "only page"}}},
+		{"with empty pages", []Segment{{Text: "first"}, {Text: ""}, {Text: "third"}}},
+		{"multibyte", []Segment{{Text: "naïve café"}, {Text: "日本語のページ"}, {Text: "end"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := JoinSegments(tt.segments)
+			offsets := SegmentOffsets(tt.segments)
+			if len(offsets) != len(tt.segments) {
+				t.Fatalf("got %d offsets for %d segments", len(offsets), len(tt.segments))
+			}
+			for i, s := range tt.segments {
+				if got := text[offsets[i] : offsets[i]+len(s.Text)]; got != s.Text {
+					t.Errorf("segment %d: text at offset %d = %q, want %q", i, offsets[i], got, s.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestMIMETypeForFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"report.pdf", "application/pdf"},
+		{"REPORT.PDF", "application/pdf"},
+		{"notes.md", "text/markdown"},
+		{"deck.pptx", MIMETypePPTX},
+		{"book.epub", MIMETypeEPUB},
+		{"inbox.mbox", MIMETypeMBOX},
+		{"image.png", ""},
+		{"noext", ""},
+	}
+	for _, tt := range tests {
+		if got := MIMETypeForFilename(tt.name); got != tt.want {
+			t.Errorf("MIMETypeForFilename(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		if tt.want != "" && !IsSupportedMIMEType(tt.want) {
+			t.Errorf("IsSupportedMIMEType(%q) = false", tt.want)
+		}
+	}
+}
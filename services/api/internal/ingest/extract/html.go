@@ -0,0 +1,206 @@
+package extract
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlDocument is the text rendering of an HTML or XHTML document.
+type htmlDocument struct {
+	Text string
+	// Title is the <title> element; Heading is the first h1-h3.
+	Title   string
+	Heading string
+}
+
+// htmlToText renders HTML as plain text: block elements become line breaks,
+// inline whitespace is collapsed, list items are bulleted and table cells are
+// joined by " | ". Scripts, styles and the document head are dropped. With
+// markHeadings, h1-h6 are prefixed with Markdown "#" markers so the text's
+// sections can be recovered (see Result.Markdown).
+func htmlToText(r io.Reader, markHeadings bool) (htmlDocument, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return htmlDocument{}, err
+	}
+	w := htmlTextWriter{markHeadings: markHeadings}
+	w.walk(root, false)
+	return htmlDocument{
+		Text:    strings.TrimSpace(w.b.String()),
+		Title:   w.title,
+		Heading: w.heading,
+	}, nil
+}
+
+var htmlBlockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Figcaption: true,
+	atom.Figure: true, atom.Footer: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.Header: true, atom.Hr: true,
+	atom.Li: true, atom.Main: true, atom.Nav: true, atom.Ol: true, atom.P: true,
+	atom.Pre: true, atom.Section: true, atom.Table: true, atom.Tr: true, atom.Ul: true,
+}
+
+// htmlParagraphElements end with a blank line rather than a single break.
+var htmlParagraphElements = map[atom.Atom]bool{
+	atom.Blockquote: true, atom.Dl: true, atom.Figure: true, atom.H1: true, atom.H2: true,
+	atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true, atom.Hr: true, atom.Ol: true,
+	atom.P: true, atom.Pre: true, atom.Table: true, atom.Ul: true,
+}
+
+// htmlHeadingLevels maps heading elements to their level.
+var htmlHeadingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+type htmlTextWriter struct {
+	markHeadings bool
+
+	b       strings.Builder
+	title   string
+	heading string
+	// cellOpen is set after the first cell of a table row so later cells
+	// get a separator.
+	cellOpen bool
+}
+
+func (w *htmlTextWriter) walk(n *html.Node, pre bool) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data, pre)
+		return
+	case html.ElementNode:
+		switch n.DataAtom {
+		case atom.Script, atom.Style, atom.Noscript, atom.Template:
+			return
+		case atom.Head:
+			if t := findElement(n, atom.Title); t != nil {
+				w.title = strings.Join(strings.Fields(nodeText(t)), " ")
+			}
+			return
+		case atom.Br:
+			w.b.WriteString("\n")
+			return
+		case atom.Pre:
+			pre = true
+		case atom.H1, atom.H2, atom.H3:
+			if w.heading == "" {
+				w.heading = strings.Join(strings.Fields(nodeText(n)), " ")
+			}
+		case atom.Tr:
+			w.cellOpen = false
+		case atom.Td, atom.Th:
+			if w.cellOpen {
+				w.b.WriteString(" | ")
+			}
+			w.cellOpen = true
+		}
+	}
+
+	block := n.Type == html.ElementNode && htmlBlockElements[n.DataAtom]
+	if block {
+		w.newline()
+		if n.DataAtom == atom.Li {
+			w.b.WriteString("- ")
+		}
+		if level := htmlHeadingLevels[n.DataAtom]; level > 0 && w.markHeadings {
+			w.b.WriteString(strings.Repeat("#", level) + " ")
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c, pre)
+	}
+	switch {
+	case block && htmlParagraphElements[n.DataAtom]:
+		w.paragraphBreak()
+	case block:
+		w.newline()
+	}
+}
+
+func (w *htmlTextWriter) text(s string, pre bool) {
+	if pre {
+		w.b.WriteString(s)
+		return
+	}
+	s = collapseSpace(s)
+	if s == "" {
+		// Whitespace between inline elements still separates words.
+		if w.b.Len() > 0 && !w.atLineStart() && !strings.HasSuffix(w.b.String(), " ") {
+			w.b.WriteString(" ")
+		}
+		return
+	}
+	if w.atLineStart() || strings.HasSuffix(w.b.String(), " ") {
+		s = strings.TrimLeft(s, " ")
+	}
+	w.b.WriteString(s)
+}
+
+func (w *htmlTextWriter) atLineStart() bool {
+	return w.b.Len() == 0 || strings.HasSuffix(w.b.String(), "\n")
+}
+
+// newline ends the current line unless already at the start of one.
+func (w *htmlTextWriter) newline() {
+	if w.atLineStart() {
+		return
+	}
+	if s := w.b.String(); strings.HasSuffix(s, " ") {
+		trimmed := strings.TrimRight(s, " ")
+		w.b.Reset()
+		w.b.WriteString(trimmed)
+	}
+	w.b.WriteString("\n")
+}
+
+// paragraphBreak ends the current line and leaves one blank line.
+func (w *htmlTextWriter) paragraphBreak() {
+	w.newline()
+	if s := w.b.String(); s != "" && !strings.HasSuffix(s, "\n\n") {
+		w.b.WriteString("\n")
+	}
+}
+
+// collapseSpace replaces runs of whitespace with single spaces, keeping a
+// single leading/trailing space when the input had one.
+func collapseSpace(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return ""
+	}
+	out := strings.Join(fields, " ")
+	if strings.TrimLeft(s, " \t\r\n\f") != s {
+		out = " " + out
+	}
+	if strings.TrimRight(s, " \t\r\n\f") != s {
+		out += " "
+	}
+	return out
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(nodeText(c))
+	}
+	return b.String()
+}
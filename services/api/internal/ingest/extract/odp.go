@@ -0,0 +1,204 @@
+package extract
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	odfDrawNS         = "urn:oasis:names:tc:opendocument:xmlns:drawing:1.0"
+	odfPresentationNS = "urn:oasis:names:tc:opendocument:xmlns:presentation:1.0"
+)
+
+// maxSpaceRun caps the spaces a single text:s element expands to; its count
+// comes from the file, and a huge one would allocate without bound.
+const maxSpaceRun = 1024
+
+// odpSkippedClasses are presentation frame classes that repeat on every
+// slide or only hold a slide thumbnail.
+var odpSkippedClasses = map[string]bool{
+	"page-number": true,
+	"footer":      true,
+	"header":      true,
+	"date-time":   true,
+	"page":        true,
+}
+
+// extractODP reads an OpenDocument presentation slide by slide.
+//
+// All slides live in content.xml as draw:page elements, in order. Speaker
+// notes are nested in each page under presentation:notes.
+func extractODP(filePath string) (*Result, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("odp open: %w", err)
+	}
+	defer zr.Close()
+
+	rc, err := openZipEntry(zipEntries(&zr.Reader), "content.xml")
+	if err != nil {
+		return nil, fmt.Errorf("odp content: %w", err)
+	}
+	defer rc.Close()
+
+	segments, err := parseODPContent(rc)
+	if err != nil {
+		return nil, fmt.Errorf("odp content: %w", err)
+	}
+	return newSegmentedResult(segments), nil
+}
+
+func parseODPContent(r io.Reader) ([]Segment, error) {
+	var (
+		segments []Segment
+		cur      *slide
+
+		inNotes     bool
+		frameDepth  int
+		frameClass  string
+		frameParas  []string
+		paraDepth   int
+		para        strings.Builder
+		inCell      bool
+		cellParas   []string
+		row         []string
+		rowIsInNote bool
+	)
+
+	emit := func(text string, notes bool) {
+		if cur == nil {
+			return
+		}
+		if notes {
+			cur.addNotes(text)
+		} else {
+			cur.addBody(text)
+		}
+	}
+
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return segments, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "page":
+				if t.Name.Space == odfDrawNS {
+					cur = &slide{}
+				}
+			case "notes":
+				if t.Name.Space == odfPresentationNS {
+					inNotes = true
+				}
+			case "frame", "custom-shape":
+				frameDepth++
+				if frameDepth == 1 {
+					frameClass, frameParas = "", nil
+					for _, a := range t.Attr {
+						if a.Name.Space == odfPresentationNS && a.Name.Local == "class" {
+							frameClass = a.Value
+						}
+					}
+				}
+			case "p", "h":
+				if paraDepth == 0 {
+					para.Reset()
+				}
+				paraDepth++
+			case "s":
+				if paraDepth > 0 {
+					n := 1
+					for _, a := range t.Attr {
+						if a.Name.Local == "c" {
+							if v, err := strconv.Atoi(a.Value); err == nil && v > 0 {
+								n = min(v, maxSpaceRun)
+							}
+						}
+					}
+					para.WriteString(strings.Repeat(" ", n))
+				}
+			case "tab":
+				if paraDepth > 0 {
+					para.WriteString("\t")
+				}
+			case "line-break":
+				if paraDepth > 0 {
+					para.WriteString("\n")
+				}
+			case "table-row":
+				row, rowIsInNote = nil, inNotes
+			case "table-cell":
+				inCell, cellParas = true, nil
+			}
+
+		case xml.CharData:
+			if paraDepth > 0 {
+				para.Write(t)
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p", "h":
+				if paraDepth == 0 {
+					continue
+				}
+				paraDepth--
+				if paraDepth > 0 {
+					continue
+				}
+				text := strings.TrimSpace(para.String())
+				if text == "" {
+					continue
+				}
+				switch {
+				case inCell:
+					cellParas = append(cellParas, text)
+				case frameDepth > 0:
+					frameParas = append(frameParas, text)
+				default:
+					emit(text, inNotes)
+				}
+			case "table-cell":
+				row = append(row, strings.Join(cellParas, " "))
+				inCell = false
+			case "table-row":
+				emit(strings.Join(row, " | "), rowIsInNote)
+			case "frame", "custom-shape":
+				frameDepth--
+				if frameDepth > 0 || cur == nil {
+					continue
+				}
+				switch {
+				case odpSkippedClasses[frameClass]:
+				case !inNotes && cur.title == "" && frameClass == "title":
+					cur.title = strings.Join(frameParas, " ")
+				default:
+					for _, p := range frameParas {
+						emit(p, inNotes)
+					}
+				}
+			case "notes":
+				if t.Name.Space == odfPresentationNS {
+					inNotes = false
+				}
+			case "page":
+				if t.Name.Space == odfDrawNS && cur != nil {
+					segments = append(segments, cur.segment(len(segments)+1))
+					cur = nil
+				}
+			}
+		}
+	}
+}
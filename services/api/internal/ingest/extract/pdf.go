@@ -0,0 +1,111 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	pdf "github.com/ledongthuc/pdf"
+)
+
+// SegmentKindPage marks segments extracted from PDF pages.
+const SegmentKindPage = "page"
+
+// minTextRunesPerPage is the average number of non-space characters per page
+// below which a PDF is considered to have no text layer. Scans often carry a
+// few stray characters (page numbers, a producer stamp), so zero is too strict.
+const minTextRunesPerPage = 10
+
+// extractPDF reads a PDF page by page. Every page yields a segment, including
+// pages without text, so segment numbers are always physical page numbers.
+// The information dictionary and bookmark outline are read as well.
+//
+// The PDF library panics on many kinds of malformed input; those panics are
+// reported as ErrCorrupt.
+func extractPDF(filePath, password string) (res *Result, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			res, err = nil, fmt.Errorf("pdf: %w: %v", ErrCorrupt, p)
+		}
+	}()
+
+	f, r, err := openPDF(filePath, password)
+	if err != nil {
+		return nil, fmt.Errorf("pdf open: %w", err)
+	}
+	defer f.Close()
+
+	numPages := r.NumPage()
+	segments := make([]Segment, 0, numPages)
+	textRunes := 0
+	// Cache fonts across pages so we don't continually parse charmaps.
+	fonts := make(map[string]*pdf.Font)
+	for i := 1; i <= numPages; i++ {
+		p := r.Page(i)
+		for _, name := range p.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				font := p.Font(name)
+				fonts[name] = &font
+			}
+		}
+		text, err := p.GetPlainText(fonts)
+		if err != nil {
+			return nil, fmt.Errorf("pdf extract page %d: %w: %v", i, ErrCorrupt, err)
+		}
+		for _, c := range text {
+			if !unicode.IsSpace(c) {
+				textRunes++
+			}
+		}
+		segments = append(segments, Segment{Kind: SegmentKindPage, Number: i, Text: text})
+	}
+	res = newSegmentedResult(segments)
+	res.Title, res.Metadata = pdfInfo(r)
+	res.Metadata["page_count"] = numPages
+	res.Outline = pdfOutline(r)
+	res.NoTextLayer = numPages > 0 && textRunes < numPages*minTextRunesPerPage
+	return res, nil
+}
+
+// openPDF opens a PDF, decrypting it with password if it is encrypted, and
+// classifies failures as ErrEncrypted or ErrCorrupt.
+func openPDF(filePath, password string) (*os.File, *pdf.Reader, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Close the file on every failure, including a panic while parsing.
+	opened := false
+	defer func() {
+		if !opened {
+			f.Close()
+		}
+	}()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The library asks for passwords until it gets "", so offer ours once.
+	tried := false
+	r, err := pdf.NewReaderEncrypted(f, fi.Size(), func() string {
+		if tried {
+			return ""
+		}
+		tried = true
+		return password
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pdf.ErrInvalidPassword),
+			strings.HasPrefix(err.Error(), "unsupported PDF: encryption"):
+			return nil, nil, fmt.Errorf("%w: %v", ErrEncrypted, err)
+		default:
+			return nil, nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+	}
+	opened = true
+	return f, r, nil
+}
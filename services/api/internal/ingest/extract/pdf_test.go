@@ -0,0 +1,181 @@
+package extract
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPDF describes a PDF built by writePDF.
+type testPDF struct {
+	pages []string
+	// info is the body of the information dictionary, e.g. "/Title (T)".
+	info string
+	// outline holds top-level bookmarks as title and 1-based page.
+	outline []testBookmark
+}
+
+type testBookmark struct {
+	title string
+	page  int
+}
+
+// writePDF writes a minimal PDF with one line of Helvetica text per page and
+// returns its path.
+func writePDF(t *testing.T, p testPDF) string {
+	t.Helper()
+	var objs []string
+	add := func(body string) int {
+		objs = append(objs, body)
+		return len(objs)
+	}
+	catalog := add("")
+	pagesObj := add("")
+	font := add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+
+	pageRefs := make([]int, len(p.pages))
+	for i, text := range p.pages {
+		stream := ""
+		if text != "" {
+			stream = fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		}
+		content := add(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream))
+		pageRefs[i] = add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+			pagesObj, font, content))
+	}
+	kids := make([]string, len(pageRefs))
+	for i, ref := range pageRefs {
+		kids[i] = fmt.Sprintf("%d 0 R", ref)
+	}
+	objs[pagesObj-1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	outlines := ""
+	if len(p.outline) > 0 {
+		root := add("")
+		first := len(objs) + 1
+		for i, b := range p.outline {
+			links := ""
+			if i > 0 {
+				links += fmt.Sprintf(" /Prev %d 0 R", first+i-1)
+			}
+			if i < len(p.outline)-1 {
+				links += fmt.Sprintf(" /Next %d 0 R", first+i+1)
+			}
+			add(fmt.Sprintf("<< /Title (%s) /Parent %d 0 R /Dest [%d 0 R /Fit]%s >>", b.title, root, pageRefs[b.page-1], links))
+		}
+		objs[root-1] = fmt.Sprintf("<< /Type /Outlines /First %d 0 R /Last %d 0 R /Count %d >>", first, len(objs), len(p.outline))
+		outlines = fmt.Sprintf(" /Outlines %d 0 R", root)
+	}
+	objs[catalog-1] = fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R%s >>", pagesObj, outlines)
+	info := add(fmt.Sprintf("<< %s >>", p.info))
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objs))
+	for i, body := range objs {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, catalog, info, xref)
+
+	path := filepath.Join(t.TempDir(), "doc.pdf")
+	if err := os.WriteFile(path, b.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractPDFPages(t *testing.T) {
+	tests := []struct {
+		name      string
+		pages     []string
+		wantTexts []string
+	}{
+		{
+			name:      "one segment per page",
+			pages:     []string{"First page text", "Second page text"},
+			wantTexts: []string{"First page text", "Second page text"},
+		},
+		{
+			name:      "blank pages keep their number",
+			pages:     []string{"Intro to the topic", "", "Conclusion of it all"},
+			wantTexts: []string{"Intro to the topic", "", "Conclusion of it all"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := extractPDF(writePDF(t, testPDF{pages: tt.pages}), "")
+			if err != nil {
+				t.Fatalf("extractPDF: %v", err)
+			}
+			if len(res.Segments) != len(tt.wantTexts) {
+				t.Fatalf("got %d segments, want %d", len(res.Segments), len(tt.wantTexts))
+			}
+			for i, s := range res.Segments {
+				if s.Kind != SegmentKindPage || s.Number != i+1 {
+					t.Errorf("segment %d: kind %q number %d", i, s.Kind, s.Number)
+				}
+				if got := strings.TrimSpace(s.Text); got != tt.wantTexts[i] {
+					t.Errorf("page %d text = %q, want %q", i+1, got, tt.wantTexts[i])
+				}
+			}
+			if got := res.Metadata["page_count"]; got != len(tt.pages) {
+				t.Errorf("page_count = %v, want %d", got, len(tt.pages))
+			}
+		})
+	}
+}
+
+func TestExtractPDFCorrupt(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not a pdf", "hello, world"},
+		{"truncated", "%PDF-1.4\n1 0 obj\n<< /Type /Catalog"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "bad.pdf")
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := extractPDF(path, "")
+			if !errors.Is(err, ErrCorrupt) {
+				t.Errorf("err = %v, want ErrCorrupt", err)
+			}
+		})
+	}
+}
+
+func TestExtractPDFNoTextLayer(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages []string
+		want  bool
+	}{
+		{"text on every page", []string{"A page with plenty of text", "Another page of text"}, false},
+		{"only page numbers", []string{"1", "2", "3"}, true},
+		{"no text at all", []string{"", ""}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := extractPDF(writePDF(t, testPDF{pages: tt.pages}), "")
+			if err != nil {
+				t.Fatalf("extractPDF: %v", err)
+			}
+			if res.NoTextLayer != tt.want {
+				t.Errorf("NoTextLayer = %v, want %v", res.NoTextLayer, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package extract
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	pdf "github.com/ledongthuc/pdf"
+)
+
+// OutlineEntry is a bookmark from a document outline (table of contents).
+type OutlineEntry struct {
+	Title string `json:"title"`
+	// Page is the 1-based target page, or 0 when it cannot be resolved.
+	Page     int            `json:"page,omitempty"`
+	Children []OutlineEntry `json:"children,omitempty"`
+}
+
+// Outline walks are bounded: outlines are linked lists that a malformed or
+// hostile file can make cyclic.
+const (
+	maxOutlineEntries = 5000
+	maxOutlineDepth   = 32
+)
+
+// pdfInfo reads the document information dictionary. It returns the title
+// (only when it looks like a real title) and the remaining properties as
+// metadata.
+func pdfInfo(r *pdf.Reader) (string, map[string]any) {
+	info := r.Trailer().Key("Info")
+	meta := map[string]any{}
+
+	for key, field := range map[string]string{
+		"Author":   "author",
+		"Subject":  "subject",
+		"Creator":  "creator",
+		"Producer": "producer",
+	} {
+		if v := strings.TrimSpace(info.Key(key).Text()); v != "" {
+			meta[field] = v
+		}
+	}
+	if kw := splitKeywords(info.Key("Keywords").Text()); len(kw) > 0 {
+		meta["keywords"] = kw
+	}
+	for key, field := range map[string]string{
+		"CreationDate": "creation_date",
+		"ModDate":      "modification_date",
+	} {
+		if t, ok := parsePDFDate(info.Key(key).Text()); ok {
+			meta[field] = t.UTC().Format(time.RFC3339)
+		}
+	}
+
+	title := strings.Join(strings.Fields(info.Key("Title").Text()), " ")
+	if !isMeaningfulPDFTitle(title) {
+		title = ""
+	}
+	return title, meta
+}
+
+var (
+	// Titles authoring tools fill in on their own.
+	placeholderTitle = regexp.MustCompile(`(?i)^(untitled|title|document\d*|slide \d+|microsoft (word|powerpoint|excel) - .*|.*\.(docx?|pptx?|xlsx?|pdf|txt|rtf|odt|indd|tex|dvi))$`)
+	pdfDate          = regexp.MustCompile(`^(?:D:)?(\d{4})(\d{2})?(\d{2})?(\d{2})?(\d{2})?(\d{2})?([Zz+\-])?(\d{2})?'?(\d{2})?'?$`)
+)
+
+// isMeaningfulPDFTitle rejects empty titles and the file names and
+// placeholders that tools write when the author never set one.
+func isMeaningfulPDFTitle(title string) bool {
+	if title == "" || placeholderTitle.MatchString(title) {
+		return false
+	}
+	return filepath.Base(title) == title
+}
+
+func splitKeywords(s string) []string {
+	var out []string
+	for _, kw := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		if kw = strings.TrimSpace(kw); kw != "" {
+			out = append(out, kw)
+		}
+	}
+	return out
+}
+
+// parsePDFDate parses a PDF date string (D:YYYYMMDDHHmmSSOHH'mm'), where
+// everything after the year is optional.
+func parsePDFDate(s string) (time.Time, bool) {
+	m := pdfDate.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return time.Time{}, false
+	}
+	num := func(v string, def int) int {
+		if v == "" {
+			return def
+		}
+		n := 0
+		for _, c := range v {
+			n = n*10 + int(c-'0')
+		}
+		return n
+	}
+	loc := time.UTC
+	if sign := m[7]; sign == "+" || sign == "-" {
+		offset := num(m[8], 0)*3600 + num(m[9], 0)*60
+		if sign == "-" {
+			offset = -offset
+		}
+		loc = time.FixedZone("", offset)
+	}
+	t := time.Date(num(m[1], 0), time.Month(num(m[2], 1)), num(m[3], 1),
+		num(m[4], 0), num(m[5], 0), num(m[6], 0), 0, loc)
+	return t, true
+}
+
+// pdfOutline reads the bookmark tree, resolving each entry's destination to
+// a page number where possible.
+func pdfOutline(r *pdf.Reader) []OutlineEntry {
+	pages := pdfPageNumbers(r)
+	root := r.Trailer().Key("Root")
+	count := 0
+
+	var walk func(first pdf.Value, depth int) []OutlineEntry
+	walk = func(first pdf.Value, depth int) []OutlineEntry {
+		if depth > maxOutlineDepth {
+			return nil
+		}
+		var entries []OutlineEntry
+		for item := first; item.Kind() == pdf.Dict && count < maxOutlineEntries; item = item.Key("Next") {
+			count++
+			entry := OutlineEntry{
+				Title: strings.Join(strings.Fields(item.Key("Title").Text()), " "),
+				Page:  pdfDestinationPage(root, item, pages),
+			}
+			entry.Children = walk(item.Key("First"), depth+1)
+			if entry.Title != "" || len(entry.Children) > 0 {
+				entries = append(entries, entry)
+			}
+		}
+		return entries
+	}
+	return walk(root.Key("Outlines").Key("First"), 0)
+}
+
+// pdfPageNumbers maps each page object to its page number. pdf.Value has no
+// identity, so page dictionaries are keyed by their serialized form, which
+// includes the (distinct) references to their content streams.
+func pdfPageNumbers(r *pdf.Reader) map[string]int {
+	n := r.NumPage()
+	pages := make(map[string]int, n)
+	for i := 1; i <= n; i++ {
+		if p := r.Page(i); !p.V.IsNull() {
+			pages[p.V.String()] = i
+		}
+	}
+	return pages
+}
+
+// pdfDestinationPage returns the page number an outline item points to,
+// or 0. The target is given either through /Dest or a GoTo action, and
+// either explicitly or through a named destination.
+func pdfDestinationPage(root, item pdf.Value, pages map[string]int) int {
+	dest := item.Key("Dest")
+	if dest.IsNull() {
+		if action := item.Key("A"); action.Key("S").Name() == "GoTo" {
+			dest = action.Key("D")
+		}
+	}
+	switch dest.Kind() {
+	case pdf.Name:
+		dest = root.Key("Dests").Key(dest.Name())
+	case pdf.String:
+		dest = lookupNameTree(root.Key("Names").Key("Dests"), dest.RawString(), 0)
+	}
+	if dest.Kind() == pdf.Dict {
+		dest = dest.Key("D")
+	}
+	if dest.Kind() != pdf.Array {
+		return 0
+	}
+	// Some writers give a 0-based page index instead of a page reference.
+	target := dest.Index(0)
+	if target.Kind() == pdf.Integer {
+		if n := int(target.Int64()) + 1; n >= 1 && n <= len(pages) {
+			return n
+		}
+		return 0
+	}
+	return pages[target.String()]
+}
+
+// lookupNameTree finds key in a PDF name tree.
+func lookupNameTree(node pdf.Value, key string, depth int) pdf.Value {
+	if node.Kind() != pdf.Dict || depth > maxOutlineDepth {
+		return pdf.Value{}
+	}
+	names := node.Key("Names")
+	for i := 0; i+1 < names.Len(); i += 2 {
+		if names.Index(i).RawString() == key {
+			return names.Index(i + 1)
+		}
+	}
+	kids := node.Key("Kids")
+	for i := 0; i < kids.Len(); i++ {
+		kid := kids.Index(i)
+		if limits := kid.Key("Limits"); limits.Len() == 2 {
+			if key < limits.Index(0).RawString() || key > limits.Index(1).RawString() {
+				continue
+			}
+		}
+		if v := lookupNameTree(kid, key, depth+1); !v.IsNull() {
+			return v
+		}
+	}
+	return pdf.Value{}
+}
@@ -0,0 +1,109 @@
+package extract
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePDFDate(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   time.Time
+		wantOK bool
+	}{
+		{"D:20240315103000Z", time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC), true},
+		{"D:20240315103000+02'00'", time.Date(2024, 3, 15, 8, 30, 0, 0, time.UTC), true},
+		{"D:20240315103000-05'30", time.Date(2024, 3, 15, 16, 0, 0, 0, time.UTC), true},
+		{"20240315", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), true},
+		{"D:2024", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{"", time.Time{}, false},
+		{"yesterday", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePDFDate(tt.in)
+		if ok != tt.wantOK || !got.Equal(tt.want) {
+			t.Errorf("parsePDFDate(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestIsMeaningfulPDFTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  bool
+	}{
+		{"Annual Report 2024", true},
+		{"", false},
+		{"Untitled", false},
+		{"Microsoft Word - draft.docx", false},
+		{"report_final.pdf", false},
+		{"Slide 1", false},
+		{"/home/alice/report", false},
+	}
+	for _, tt := range tests {
+		if got := isMeaningfulPDFTitle(tt.title); got != tt.want {
+			t.Errorf("isMeaningfulPDFTitle(%q) = %v, want %v", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestSplitKeywords(t *testing.T) {
+	got := splitKeywords(" go, pdf ;; parsing ,")
+	want := []string{"go", "pdf", "parsing"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitKeywords = %q, want %q", got, want)
+	}
+}
+
+func TestExtractPDFInfoAndOutline(t *testing.T) {
+	tests := []struct {
+		name        string
+		pdf         testPDF
+		wantTitle   string
+		wantMeta    map[string]any
+		wantOutline []OutlineEntry
+	}{
+		{
+			name: "info and bookmarks",
+			pdf: testPDF{
+				pages:   []string{"Introduction page", "Methods page", "Results page"},
+				info:    "/Title (Field Study) /Author (A. Writer) /Keywords (soil, water) /CreationDate (D:20230102030405Z)",
+				outline: []testBookmark{{"Introduction", 1}, {"Results", 3}},
+			},
+			wantTitle: "Field Study",
+			wantMeta: map[string]any{
+				"author":        "A. Writer",
+				"keywords":      []string{"soil", "water"},
+				"creation_date": "2023-01-02T03:04:05Z",
+				"page_count":    3,
+			},
+			wantOutline: []OutlineEntry{{Title: "Introduction", Page: 1}, {Title: "Results", Page: 3}},
+		},
+		{
+			name: "placeholder title is dropped",
+			pdf: testPDF{
+				pages: []string{"Some page text"},
+				info:  "/Title (Microsoft Word - notes.docx)",
+			},
+			wantMeta: map[string]any{"page_count": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := extractPDF(writePDF(t, tt.pdf), "")
+			if err != nil {
+				t.Fatalf("extractPDF: %v", err)
+			}
+			if res.Title != tt.wantTitle {
+				t.Errorf("Title = %q, want %q", res.Title, tt.wantTitle)
+			}
+			if !reflect.DeepEqual(res.Metadata, tt.wantMeta) {
+				t.Errorf("Metadata = %v, want %v", res.Metadata, tt.wantMeta)
+			}
+			if !reflect.DeepEqual(res.Outline, tt.wantOutline) {
+				t.Errorf("Outline = %+v, want %+v", res.Outline, tt.wantOutline)
+			}
+		})
+	}
+}
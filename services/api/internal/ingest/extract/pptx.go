@@ -0,0 +1,242 @@
+package extract
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	ooxmlSlideRelType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/slide"
+	ooxmlNotesRelType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/notesSlide"
+)
+
+// extractPPTX reads an Office Open XML presentation slide by slide, in the
+// order given by the presentation's slide list (not archive order).
+func extractPPTX(filePath string) (*Result, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("pptx open: %w", err)
+	}
+	defer zr.Close()
+	files := zipEntries(&zr.Reader)
+
+	slidePaths, err := pptxSlidePaths(files)
+	if err != nil {
+		return nil, fmt.Errorf("pptx slide list: %w", err)
+	}
+
+	segments := make([]Segment, 0, len(slidePaths))
+	for i, slidePath := range slidePaths {
+		var s slide
+		if err := readPPTXPart(files, slidePath, &s, false); err != nil {
+			return nil, fmt.Errorf("pptx slide %d: %w", i+1, err)
+		}
+
+		rels, err := readOOXMLRels(files, slidePath)
+		if err != nil {
+			return nil, fmt.Errorf("pptx slide %d rels: %w", i+1, err)
+		}
+		for _, rel := range rels {
+			if rel.Type != ooxmlNotesRelType {
+				continue
+			}
+			if err := readPPTXPart(files, rel.resolve(slidePath), &s, true); err != nil {
+				return nil, fmt.Errorf("pptx slide %d notes: %w", i+1, err)
+			}
+		}
+
+		segments = append(segments, s.segment(i+1))
+	}
+	return newSegmentedResult(segments), nil
+}
+
+// pptxSlidePaths returns archive paths of slides in presentation order.
+func pptxSlidePaths(files map[string]*zip.File) ([]string, error) {
+	const presentationPath = "ppt/presentation.xml"
+
+	var pres struct {
+		SlideIDs []struct {
+			RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sldIdLst>sldId"`
+	}
+	if err := decodeZipXML(files, presentationPath, &pres); err != nil {
+		return nil, err
+	}
+
+	rels, err := readOOXMLRels(files, presentationPath)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]ooxmlRel, len(rels))
+	for _, rel := range rels {
+		byID[rel.ID] = rel
+	}
+
+	paths := make([]string, 0, len(pres.SlideIDs))
+	for _, sid := range pres.SlideIDs {
+		rel, ok := byID[sid.RelID]
+		if !ok || rel.Type != ooxmlSlideRelType {
+			continue
+		}
+		paths = append(paths, rel.resolve(presentationPath))
+	}
+	return paths, nil
+}
+
+type ooxmlRel struct {
+	ID     string `xml:"Id,attr"`
+	Type   string `xml:"Type,attr"`
+	Target string `xml:"Target,attr"`
+}
+
+// resolve returns the archive path of the relationship target for a part.
+func (r ooxmlRel) resolve(partPath string) string {
+	if strings.HasPrefix(r.Target, "/") {
+		return strings.TrimPrefix(r.Target, "/")
+	}
+	return path.Join(path.Dir(partPath), r.Target)
+}
+
+// readOOXMLRels reads the relationships of a part; a part without a
+// relationships file simply has none.
+func readOOXMLRels(files map[string]*zip.File, partPath string) ([]ooxmlRel, error) {
+	relsPath := path.Join(path.Dir(partPath), "_rels", path.Base(partPath)+".rels")
+	if _, ok := files[relsPath]; !ok {
+		return nil, nil
+	}
+	var doc struct {
+		Rels []ooxmlRel `xml:"Relationship"`
+	}
+	if err := decodeZipXML(files, relsPath, &doc); err != nil {
+		return nil, err
+	}
+	return doc.Rels, nil
+}
+
+func readPPTXPart(files map[string]*zip.File, partPath string, s *slide, notes bool) error {
+	rc, err := openZipEntry(files, partPath)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return parsePPTXShapes(rc, s, notes)
+}
+
+// pptxSkippedPlaceholders are placeholders that repeat on every slide or
+// notes page and carry no content.
+var pptxSkippedPlaceholders = map[string]bool{
+	"sldNum": true,
+	"dt":     true,
+	"ftr":    true,
+	"hdr":    true,
+	"sldImg": true,
+}
+
+// parsePPTXShapes walks a slide (or notes slide) part and adds its text to s.
+//
+// Text lives in DrawingML paragraphs (a:p) inside shapes (p:sp) and table
+// cells (a:tc). Shapes whose placeholder type is a title become the slide
+// title; table rows are flattened to a single line of " | "-joined cells.
+func parsePPTXShapes(r io.Reader, s *slide, notes bool) error {
+	var (
+		inShape     bool
+		placeholder string
+		shapeParas  []string
+
+		inCell    bool
+		cellParas []string
+		row       []string
+
+		inText bool
+		para   strings.Builder
+	)
+
+	emit := func(text string) {
+		if notes {
+			s.addNotes(text)
+		} else {
+			s.addBody(text)
+		}
+	}
+
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "sp":
+				inShape, placeholder, shapeParas = true, "", nil
+			case "ph":
+				placeholder = "body"
+				for _, a := range t.Attr {
+					if a.Name.Local == "type" {
+						placeholder = a.Value
+					}
+				}
+			case "p":
+				para.Reset()
+			case "t":
+				inText = true
+			case "br":
+				para.WriteString("\n")
+			case "tr":
+				row = nil
+			case "tc":
+				inCell, cellParas = true, nil
+			}
+
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text := strings.TrimSpace(para.String())
+				if text == "" {
+					continue
+				}
+				switch {
+				case inCell:
+					cellParas = append(cellParas, text)
+				case inShape:
+					shapeParas = append(shapeParas, text)
+				default:
+					emit(text)
+				}
+			case "tc":
+				row = append(row, strings.Join(cellParas, " "))
+				inCell = false
+			case "tr":
+				emit(strings.Join(row, " | "))
+			case "sp":
+				inShape = false
+				switch {
+				case pptxSkippedPlaceholders[placeholder]:
+				case !notes && s.title == "" && (placeholder == "title" || placeholder == "ctrTitle"):
+					s.title = strings.Join(shapeParas, " ")
+				default:
+					for _, p := range shapeParas {
+						emit(p)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package extract
+
+import "strings"
+
+// SegmentKindSlide marks segments extracted from presentation slides.
+const SegmentKindSlide = "slide"
+
+// slide collects the text of one presentation slide in reading order.
+type slide struct {
+	title string
+	// body holds paragraphs and table rows (cells joined by " | ").
+	body  []string
+	notes []string
+}
+
+func (s *slide) addBody(text string) {
+	if text = strings.TrimSpace(text); text != "" {
+		s.body = append(s.body, text)
+	}
+}
+
+func (s *slide) addNotes(text string) {
+	if text = strings.TrimSpace(text); text != "" {
+		s.notes = append(s.notes, text)
+	}
+}
+
+// segment renders the slide as a Segment. The title leads the text so a
+// chunk that starts on this slide carries it; speaker notes follow the body
+// under an explicit label so they are never mistaken for slide content.
+func (s *slide) segment(number int) Segment {
+	var b strings.Builder
+	if s.title != "" {
+		b.WriteString(s.title)
+		b.WriteString("\n")
+	}
+	for _, line := range s.body {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	if len(s.notes) > 0 {
+		b.WriteString("Speaker notes:\n")
+		for _, line := range s.notes {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+	return Segment{
+		Kind:   SegmentKindSlide,
+		Number: number,
+		Title:  s.title,
+		Text:   strings.TrimRight(b.String(), "\n"),
+	}
+}
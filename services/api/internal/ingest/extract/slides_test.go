@@ -0,0 +1,150 @@
+package extract
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeZip writes an archive with the given entries and returns its path.
+func writeZip(t *testing.T, name string, entries [][2]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		w, err := zw.Create(e[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const (
+	pptxNS = `xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" ` +
+		`xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+	relsNS = `xmlns="http://schemas.openxmlformats.org/package/2006/relationships"`
+)
+
+func pptxShape(placeholder string, paras ...string) string {
+	ph := ""
+	if placeholder != "" {
+		ph = `<p:nvSpPr><p:nvPr><p:ph type="` + placeholder + `"/></p:nvPr></p:nvSpPr>`
+	}
+	var b strings.Builder
+	for _, p := range paras {
+		b.WriteString("<a:p><a:r><a:t>" + p + "</a:t></a:r></a:p>")
+	}
+	return "<p:sp>" + ph + "<p:txBody>" + b.String() + "</p:txBody></p:sp>"
+}
+
+func pptxSlide(shapes ...string) string {
+	return `<p:sld ` + pptxNS + `><p:cSld><p:spTree>` + strings.Join(shapes, "") + `</p:spTree></p:cSld></p:sld>`
+}
+
+func TestExtractPPTX(t *testing.T) {
+	table := `<a:tbl><a:tr><a:tc><a:txBody><a:p><a:r><a:t>Q1</a:t></a:r></a:p></a:txBody></a:tc>` +
+		`<a:tc><a:txBody><a:p><a:r><a:t>10</a:t></a:r></a:p></a:txBody></a:tc></a:tr></a:tbl>`
+	path := writeZip(t, "deck.pptx", [][2]string{
+		// Slides are listed in presentation order, not archive order.
+		{"ppt/presentation.xml", `<p:presentation ` + pptxNS + `><p:sldIdLst>` +
+			`<p:sldId id="256" r:id="rId2"/><p:sldId id="257" r:id="rId1"/></p:sldIdLst></p:presentation>`},
+		{"ppt/_rels/presentation.xml.rels", `<Relationships ` + relsNS + `>` +
+			`<Relationship Id="rId1" Type="` + ooxmlSlideRelType + `" Target="slides/slide1.xml"/>` +
+			`<Relationship Id="rId2" Type="` + ooxmlSlideRelType + `" Target="slides/slide2.xml"/></Relationships>`},
+		{"ppt/slides/slide1.xml", pptxSlide(pptxShape("title", "Results"), `<p:graphicFrame>`+table+`</p:graphicFrame>`, pptxShape("sldNum", "2"))},
+		// Tab stops (a:tab in a:tabLst) are not tabs; those are literal in a:t.
+		{"ppt/slides/slide2.xml", pptxSlide(pptxShape("ctrTitle", "Welcome"), pptxShape("", "First point", "Second point"),
+			`<p:sp><p:txBody><a:p><a:pPr><a:tabLst><a:tab pos="914400" algn="l"/></a:tabLst></a:pPr>`+
+				"<a:r><a:t>Name\tValue</a:t></a:r></a:p></p:txBody></p:sp>")},
+		{"ppt/slides/_rels/slide2.xml.rels", `<Relationships ` + relsNS + `>` +
+			`<Relationship Id="rId1" Type="` + ooxmlNotesRelType + `" Target="../notesSlides/notesSlide1.xml"/></Relationships>`},
+		{"ppt/notesSlides/notesSlide1.xml", pptxSlide(pptxShape("sldImg"), pptxShape("body", "Greet the audience"))},
+	})
+
+	res, err := extractPPTX(path)
+	if err != nil {
+		t.Fatalf("extractPPTX: %v", err)
+	}
+	want := []Segment{
+		{Kind: SegmentKindSlide, Number: 1, Title: "Welcome", Text: "Welcome\nFirst point\nSecond point\nName\tValue\nSpeaker notes:\nGreet the audience"},
+		{Kind: SegmentKindSlide, Number: 2, Title: "Results", Text: "Results\nQ1 | 10"},
+	}
+	if !reflect.DeepEqual(res.Segments, want) {
+		t.Errorf("segments:\n got %+v\nwant %+v", res.Segments, want)
+	}
+	if res.Text != want[0].Text+"\n\n"+want[1].Text {
+		t.Errorf("text = %q", res.Text)
+	}
+}
+
+func TestParseODPContent(t *testing.T) {
+	const ns = `xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" ` +
+		`xmlns:draw="` + odfDrawNS + `" xmlns:presentation="` + odfPresentationNS + `" ` +
+		`xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" ` +
+		`xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"`
+	tests := []struct {
+		name    string
+		content string
+		want    []Segment
+	}{
+		{
+			name: "title, body, notes and footer",
+			content: `<draw:page>` +
+				`<draw:frame presentation:class="title"><draw:text-box><text:p>Agenda</text:p></draw:text-box></draw:frame>` +
+				`<draw:frame presentation:class="outline"><draw:text-box><text:p>One<text:s text:c="2"/>two</text:p><text:p>Three</text:p></draw:text-box></draw:frame>` +
+				`<draw:frame presentation:class="footer"><draw:text-box><text:p>ACME confidential</text:p></draw:text-box></draw:frame>` +
+				`<presentation:notes><draw:frame presentation:class="notes"><draw:text-box><text:p>Keep it short</text:p></draw:text-box></draw:frame></presentation:notes>` +
+				`</draw:page>`,
+			want: []Segment{{Kind: SegmentKindSlide, Number: 1, Title: "Agenda", Text: "Agenda\nOne  two\nThree\nSpeaker notes:\nKeep it short"}},
+		},
+		{
+			name: "space runs are capped",
+			content: `<draw:page><draw:frame><draw:text-box>` +
+				`<text:p>a<text:s text:c="2000000000"/>b</text:p>` +
+				`</draw:text-box></draw:frame></draw:page>`,
+			want: []Segment{{Kind: SegmentKindSlide, Number: 1, Text: "a" + strings.Repeat(" ", maxSpaceRun) + "b"}},
+		},
+		{
+			name: "tables and multiple pages",
+			content: `<draw:page><draw:frame><table:table><table:table-row>` +
+				`<table:table-cell><text:p>a</text:p></table:table-cell><table:table-cell><text:p>b</text:p></table:table-cell>` +
+				`</table:table-row></table:table></draw:frame></draw:page>` +
+				`<draw:page><draw:frame presentation:class="title"><draw:text-box><text:p>End</text:p></draw:text-box></draw:frame></draw:page>`,
+			want: []Segment{
+				{Kind: SegmentKindSlide, Number: 1, Text: "a | b"},
+				{Kind: SegmentKindSlide, Number: 2, Title: "End", Text: "End"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := `<office:document-content ` + ns + `><office:body><office:presentation>` +
+				tt.content + `</office:presentation></office:body></office:document-content>`
+			got, err := parseODPContent(strings.NewReader(doc))
+			if err != nil {
+				t.Fatalf("parseODPContent: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("segments:\n got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package normalize
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Encodings reported by Decode.
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingWindows1252 = "windows-1252"
+)
+
+// utf16ZeroRatio is the share of NUL bytes in one byte position (odd for
+// little-endian, even for big-endian) above which BOM-less text is taken to be
+// UTF-16. Mostly-ASCII UTF-16 has a NUL in nearly every such position; other
+// encodings have almost none.
+const utf16ZeroRatio = 0.3
+
+// Decode converts raw text to UTF-8 and reports the encoding it detected.
+//
+// A byte order mark decides; without one, valid UTF-8 is kept as is, text
+// that looks like UTF-16 is decoded as such, and anything else is read as
+// Windows-1252 (a superset of printable Latin-1), which never fails.
+func Decode(b []byte) (string, string) {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xEF, 0xBB, 0xBF}):
+		return decodeWith(unicode.UTF8BOM, b, EncodingUTF8)
+	case bytes.HasPrefix(b, []byte{0xFF, 0xFE}):
+		return decodeWith(unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), b, EncodingUTF16LE)
+	case bytes.HasPrefix(b, []byte{0xFE, 0xFF}):
+		return decodeWith(unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), b, EncodingUTF16BE)
+	}
+
+	if le, be := utf16Likelihood(b); le >= utf16ZeroRatio || be >= utf16ZeroRatio {
+		if le >= be {
+			return decodeWith(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), b, EncodingUTF16LE)
+		}
+		return decodeWith(unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), b, EncodingUTF16BE)
+	}
+	if utf8.Valid(b) {
+		return string(b), EncodingUTF8
+	}
+	return decodeWith(charmap.Windows1252, b, EncodingWindows1252)
+}
+
+func decodeWith(enc encoding.Encoding, b []byte, name string) (string, string) {
+	out, err := enc.NewDecoder().Bytes(b)
+	if err != nil {
+		// Decoders substitute U+FFFD for invalid input, so this is rare;
+		// fall back to the bytes as they are.
+		return string(b), EncodingUTF8
+	}
+	return string(out), name
+}
+
+// utf16Likelihood returns the share of NUL bytes at odd and at even offsets.
+func utf16Likelihood(b []byte) (le, be float64) {
+	if len(b) < 2 || len(b)%2 != 0 {
+		return 0, 0
+	}
+	var odd, even int
+	for i := 0; i+1 < len(b); i += 2 {
+		if b[i] == 0 {
+			even++
+		}
+		if b[i+1] == 0 {
+			odd++
+		}
+	}
+	pairs := float64(len(b) / 2)
+	return float64(odd) / pairs, float64(even) / pairs
+}
@@ -0,0 +1,248 @@
+// Package normalize cleans extracted text before it is chunked.
+//
+// Extractors return text as the source format laid it out: PDF text comes
+// with ligatures, soft hyphens, words hyphenated across lines, hard-wrapped
+// lines and the same header and footer on every page; plain-text files come
+// in whatever encoding they were saved in. The functions here undo that so
+// chunks, embeddings and snippets see clean running text.
+package normalize
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// invisibles are characters removed outright: soft hyphens (which only mark
+// possible break points), zero-width spaces, word joiners and stray byte
+// order marks. Zero-width (non-)joiners are kept; some scripts need them.
+var invisibles = strings.NewReplacer(
+	"\u00ad", "",
+	"\u200b", "",
+	"\u2060", "",
+	"\ufeff", "",
+)
+
+// Clean applies Unicode NFKC (which expands ligatures such as "ﬁ" and folds
+// full-width and other compatibility forms), removes invisible characters,
+// normalizes line endings and trims trailing space from every line.
+func Clean(s string) string {
+	s = norm.NFKC.String(s)
+	s = invisibles.Replace(s)
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	// structuralLine matches lines that must stay on their own: Markdown
+	// headings, list items, quotes, tables and indented code.
+	structuralLine = regexp.MustCompile(`^(#{1,6}\s|[-*+•]\s|\d{1,3}[.)]\s|>|\||\t|    )`)
+	codeFence      = regexp.MustCompile("^\\s*(```|~~~)")
+)
+
+// Reflow joins hard-wrapped lines back into paragraphs and removes
+// hyphenation at line ends ("exam-\nple" becomes "example"). A compound that
+// breaks at its own hyphen keeps it when the hyphenated form appears
+// elsewhere in the text ("well-\nknown" stays "well-known").
+//
+// Blank lines separate paragraphs and are kept. A line is joined to the
+// previous one only when that line does not end a sentence and is about as
+// long as the text's typical line (so a short heading is left alone), or
+// when the next line continues in lowercase. Structural Markdown lines and
+// fenced code blocks are never joined.
+func Reflow(s string) string {
+	lines := strings.Split(s, "\n")
+	full := typicalLineLength(lines) / 2
+	compounds := hyphenatedWords(s)
+
+	var (
+		out     []string
+		inFence bool
+		// joinable reports whether the last output line may take the next
+		// line as its continuation.
+		joinable bool
+	)
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case codeFence.MatchString(line):
+			inFence = !inFence
+			out, joinable = append(out, line), false
+			continue
+		case inFence || trimmed == "" || structuralLine.MatchString(line):
+			out, joinable = append(out, line), false
+			continue
+		}
+
+		if joinable {
+			prev := out[len(out)-1]
+			if continuesLine(prev, trimmed, full) {
+				out[len(out)-1] = joinLines(prev, trimmed, compounds)
+				continue
+			}
+		}
+		out, joinable = append(out, line), true
+	}
+	return strings.Join(out, "\n")
+}
+
+// continuesLine reports whether next continues the paragraph ending in prev.
+func continuesLine(prev, next string, full int) bool {
+	last, _ := utf8.DecodeLastRuneInString(prev)
+	first, _ := utf8.DecodeRuneInString(next)
+	if last == '-' {
+		return true
+	}
+	if strings.ContainsRune(".!?:;", last) {
+		return unicode.IsLower(first)
+	}
+	return unicode.IsLower(first) || utf8.RuneCountInString(prev) >= full
+}
+
+// joinLines joins a wrapped line to its continuation, removing a hyphen
+// that split a lowercase word across the break unless the hyphenated word is
+// a known compound.
+func joinLines(prev, next string, compounds map[string]bool) string {
+	if strings.HasSuffix(prev, "-") && !strings.HasSuffix(prev, "--") {
+		head := prev[:len(prev)-1]
+		before, _ := utf8.DecodeLastRuneInString(head)
+		first, _ := utf8.DecodeRuneInString(next)
+		if !unicode.IsLower(before) || !unicode.IsLower(first) {
+			return prev + next
+		}
+		word := lastWord(head) + "-" + firstWord(next)
+		if compounds[strings.ToLower(word)] {
+			return prev + next
+		}
+		return head + next
+	}
+	return prev + " " + next
+}
+
+var hyphenatedWord = regexp.MustCompile(`\pL+(?:-\pL+)+`)
+
+// hyphenatedWords returns the lowercased hyphenated words in s.
+func hyphenatedWords(s string) map[string]bool {
+	words := map[string]bool{}
+	for _, w := range hyphenatedWord.FindAllString(s, -1) {
+		words[strings.ToLower(w)] = true
+	}
+	return words
+}
+
+func lastWord(s string) string {
+	return s[strings.LastIndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) })+1:]
+}
+
+func firstWord(s string) string {
+	if i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) }); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// typicalLineLength is the 75th percentile length of non-blank lines, with
+// a floor so short texts are not reflowed aggressively.
+func typicalLineLength(lines []string) int {
+	const floor = 40
+	var lengths []int
+	for _, line := range lines {
+		if n := utf8.RuneCountInString(strings.TrimSpace(line)); n > 0 {
+			lengths = append(lengths, n)
+		}
+	}
+	if len(lengths) == 0 {
+		return floor
+	}
+	sort.Ints(lengths)
+	return max(lengths[len(lengths)*3/4], floor)
+}
+
+// Repeated header and footer detection: a line is boilerplate when it is
+// among the first or last edgeLines non-blank lines of at least
+// repeatedShare of the pages, and there are at least minRepeatedPages pages.
+const (
+	edgeLines        = 2
+	repeatedShare    = 0.5
+	minRepeatedPages = 3
+)
+
+var digitRun = regexp.MustCompile(`\d+`)
+
+// lineKey identifies a line for header/footer detection. Digits are masked
+// so "Page 3 of 10" and "Page 4 of 10" count as the same line.
+func lineKey(line string) string {
+	return digitRun.ReplaceAllString(strings.Join(strings.Fields(line), " "), "#")
+}
+
+// StripRepeatedLines removes running headers and footers: lines that recur
+// at the top or bottom of most pages. Pages keep their positions (and may
+// become empty) so page numbers stay aligned.
+func StripRepeatedLines(pages []string) []string {
+	if len(pages) < minRepeatedPages {
+		return pages
+	}
+
+	edges := func(lines []string) []int {
+		var idx []int
+		for i := 0; i < len(lines) && len(idx) < edgeLines; i++ {
+			if strings.TrimSpace(lines[i]) != "" {
+				idx = append(idx, i)
+			}
+		}
+		var tail []int
+		for i := len(lines) - 1; i >= 0 && len(tail) < edgeLines; i-- {
+			if strings.TrimSpace(lines[i]) != "" {
+				tail = append(tail, i)
+			}
+		}
+		return append(idx, tail...)
+	}
+
+	split := make([][]string, len(pages))
+	counts := map[string]int{}
+	for p, page := range pages {
+		split[p] = strings.Split(page, "\n")
+		seen := map[string]bool{}
+		for _, i := range edges(split[p]) {
+			key := lineKey(split[p][i])
+			if !seen[key] {
+				seen[key] = true
+				counts[key]++
+			}
+		}
+	}
+
+	threshold := max(int(float64(len(pages))*repeatedShare+0.5), minRepeatedPages)
+	out := make([]string, len(pages))
+	for p, lines := range split {
+		drop := map[int]bool{}
+		for _, i := range edges(lines) {
+			if counts[lineKey(lines[i])] >= threshold {
+				drop[i] = true
+			}
+		}
+		if len(drop) == 0 {
+			out[p] = pages[p]
+			continue
+		}
+		kept := make([]string, 0, len(lines))
+		for i, line := range lines {
+			if !drop[i] {
+				kept = append(kept, line)
+			}
+		}
+		out[p] = strings.Join(kept, "\n")
+	}
+	return out
+}
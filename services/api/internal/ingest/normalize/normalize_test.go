@@ -0,0 +1,135 @@
+package normalize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []byte
+		want    string
+		wantEnc string
+	}{
+		{"utf-8", []byte("café"), "café", EncodingUTF8},
+		{"utf-8 bom", []byte("\xEF\xBB\xBFcafé"), "café", EncodingUTF8},
+		{"utf-16le bom", []byte{0xFF, 0xFE, 'h', 0, 'i', 0, 0xE9, 0}, "hié", EncodingUTF16LE},
+		{"utf-16be bom", []byte{0xFE, 0xFF, 0, 'h', 0, 'i', 0, 0xE9}, "hié", EncodingUTF16BE},
+		{"utf-16le without bom", []byte{'a', 0, 'b', 0, 'c', 0, 'd', 0}, "abcd", EncodingUTF16LE},
+		{"utf-16be without bom", []byte{0, 'a', 0, 'b', 0, 'c', 0, 'd'}, "abcd", EncodingUTF16BE},
+		{"windows-1252", []byte("caf\xE9 \x93quoted\x94 \x80"), "café “quoted” €", EncodingWindows1252},
+		{"empty", nil, "", EncodingUTF8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, enc := Decode(tt.in)
+			if got != tt.want || enc != tt.wantEnc {
+				t.Errorf("Decode = %q, %q; want %q, %q", got, enc, tt.want, tt.wantEnc)
+			}
+		})
+	}
+}
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"ligatures", "ﬁnal ﬂow", "final flow"},
+		{"full-width", "ＡＢＣ１２３", "ABC123"},
+		{"soft hyphen and zero width", "co\u00adop\u200beration\ufeff", "cooperation"},
+		{"line endings and trailing space", "one  \r\ntwo\t\rthree", "one\ntwo\nthree"},
+		{"zero-width joiner kept", "a\u200db", "a\u200db"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Clean(tt.in); got != tt.want {
+				t.Errorf("Clean(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReflow(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			name: "wrapped paragraph",
+			in:   "The quick brown fox jumps over the lazy dog and\nkeeps running through the field until it reaches\nthe river.",
+			want: "The quick brown fox jumps over the lazy dog and keeps running through the field until it reaches the river.",
+		},
+		{
+			name: "line-end hyphenation",
+			in:   "This sentence has a word that was hyphen-\nated at the end of a line of text here.",
+			want: "This sentence has a word that was hyphenated at the end of a line of text here.",
+		},
+		{
+			name: "known compound keeps its hyphen",
+			in:   "A well-known fact is that this is a well-\nknown problem in typesetting of documents.",
+			want: "A well-known fact is that this is a well-known problem in typesetting of documents.",
+		},
+		{
+			name: "paragraphs stay apart",
+			in:   "First paragraph ends here.\n\nSecond paragraph starts here.",
+			want: "First paragraph ends here.\n\nSecond paragraph starts here.",
+		},
+		{
+			name: "short heading is not joined",
+			in:   "Introduction\nThis chapter explains how the system works in enough detail.",
+			want: "Introduction\nThis chapter explains how the system works in enough detail.",
+		},
+		{
+			name: "lists and code fences are kept",
+			in:   "Steps:\n- one\n- two\n```\ncode line\nmore code\n```",
+			want: "Steps:\n- one\n- two\n```\ncode line\nmore code\n```",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Reflow(tt.in); got != tt.want {
+				t.Errorf("Reflow:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripRepeatedLines(t *testing.T) {
+	page := func(n, body string) string {
+		return "ACME Corp Annual Report\n" + body + "\nPage " + n + " of 4"
+	}
+	tests := []struct {
+		name  string
+		pages []string
+		want  []string
+	}{
+		{
+			name:  "headers and numbered footers",
+			pages: []string{page("1", "alpha"), page("2", "beta"), page("3", "gamma"), page("4", "delta")},
+			want:  []string{"alpha", "beta", "gamma", "delta"},
+		},
+		{
+			name:  "too few pages",
+			pages: []string{page("1", "alpha"), page("2", "beta")},
+			want:  []string{page("1", "alpha"), page("2", "beta")},
+		},
+		{
+			name:  "lines repeated only in the body are kept",
+			pages: []string{"a1\na2\nsame\na3\na4", "b1\nb2\nsame\nb3\nb4", "c1\nc2\nsame\nc3\nc4"},
+			want:  []string{"a1\na2\nsame\na3\na4", "b1\nb2\nsame\nb3\nb4", "c1\nc2\nsame\nc3\nc4"},
+		},
+		{
+			name:  "pages keep their positions",
+			pages: []string{page("1", "alpha"), "ACME Corp Annual Report\nPage 2 of 4", page("3", "gamma")},
+			want:  []string{"alpha", "", "gamma"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := StripRepeatedLines(tt.pages)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StripRepeatedLines:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"docsense/api/internal/adapters/config"
+	"docsense/api/internal/ingest/extract"
+)
+
+// TestInProcessAbandoned hangs extractors on FIFOs, which block reads until
+// a writer opens them, to check that timed-out runs are counted until they
+// return and that the runner refuses work past maxAbandoned.
+func TestInProcessAbandoned(t *testing.T) {
+	dir := t.TempDir()
+	r := newRunner(t, config.ExtractConfig{Timeout: 20 * time.Millisecond})
+	text := writeFile(t, "a.txt", "fine")
+
+	fifos := make([]string, maxAbandoned)
+	for i := range fifos {
+		fifos[i] = filepath.Join(dir, "hang"+string(rune('a'+i)))
+		if err := syscall.Mkfifo(fifos[i], 0o600); err != nil {
+			t.Skipf("mkfifo: %v", err)
+		}
+		_, err := r.Extract(context.Background(), fifos[i], "text/plain", extract.Options{})
+		if !errors.Is(err, ErrTimeout) {
+			t.Fatalf("run %d: err = %v, want ErrTimeout", i, err)
+		}
+		if i < maxAbandoned-1 {
+			if err := r.Health(); err != nil {
+				t.Fatalf("after %d abandoned runs: Health = %v", i+1, err)
+			}
+		}
+	}
+
+	if err := r.Health(); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Health = %v, want ErrUnavailable", err)
+	}
+	if _, err := r.Extract(context.Background(), text, "text/plain", extract.Options{}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Extract = %v, want ErrUnavailable", err)
+	}
+
+	// Releasing the hung readers brings the runner back.
+	for _, p := range fifos {
+		f, err := os.OpenFile(p, os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for r.Health() != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("still unhealthy: %v", r.Health())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := r.Extract(context.Background(), text, "text/plain", extract.Options{}); err != nil {
+		t.Fatalf("Extract after recovery: %v", err)
+	}
+}
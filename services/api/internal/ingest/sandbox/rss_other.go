@@ -0,0 +1,11 @@
+//go:build !linux
+
+package sandbox
+
+import "errors"
+
+// processRSS is unavailable here; the worker then relies on the Go
+// runtime's soft memory limit alone.
+func processRSS(pid int) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
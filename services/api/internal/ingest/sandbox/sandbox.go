@@ -0,0 +1,207 @@
+// Package sandbox runs document extractors under resource limits.
+//
+// Extractors parse untrusted files with third-party code. A Runner bounds
+// each extraction by a deadline and an output-size cap and, when isolation
+// is enabled, runs it in a child process (the API binary's extract-worker
+// subcommand) with a memory limit, so a parser that hangs, panics or
+// allocates without bound only takes down that one job.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+
+	"docsense/api/internal/adapters/config"
+	"docsense/api/internal/ingest/extract"
+	"docsense/api/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WorkerCommand is the subcommand that runs a single extraction in a child
+// process; see RunWorker.
+const WorkerCommand = "extract-worker"
+
+// Failures of the sandbox itself, as opposed to the document being unreadable
+// (see extract.ErrEncrypted and friends).
+var (
+	// ErrTimeout means extraction did not finish before the deadline.
+	ErrTimeout = errors.New("extraction timed out")
+	// ErrOutputTooLarge means the extracted text and attachments exceed the
+	// configured cap.
+	ErrOutputTooLarge = errors.New("extracted output too large")
+	// ErrCrashed means the extractor panicked or its worker process died,
+	// for example by hitting the memory limit.
+	ErrCrashed = errors.New("extractor crashed")
+	// ErrUnavailable means in-process extraction is refused because too
+	// many timed-out extractions are still running (see Runner.Health).
+	ErrUnavailable = errors.New("extraction unavailable")
+)
+
+// maxAbandoned is how many timed-out in-process extractions may still be
+// running before the runner refuses new ones and reports itself unhealthy.
+const maxAbandoned = 4
+
+// Runner extracts documents within the configured limits.
+type Runner struct {
+	cfg config.ExtractConfig
+
+	// executable is the binary started as the worker; empty runs in-process.
+	executable string
+
+	// abandoned counts in-process extractions that timed out but whose
+	// goroutine has not returned yet.
+	abandoned atomic.Int64
+}
+
+// NewRunner returns a Runner for cfg. Isolation needs the path of the running
+// binary; when it cannot be determined extraction falls back to in-process.
+func NewRunner(cfg config.ExtractConfig) (*Runner, error) {
+	r := &Runner{cfg: cfg}
+	if cfg.Isolate {
+		exe, err := os.Executable()
+		if err != nil {
+			return nil, fmt.Errorf("extract worker executable: %w", err)
+		}
+		r.executable = exe
+	}
+	return r, nil
+}
+
+// Extract runs extract.ExtractWithOptions on a file under the runner's
+// limits, in an "extract" span recording the file's size and what was
+// extracted.
+func (r *Runner) Extract(ctx context.Context, filePath, mimeType string, opts extract.Options) (res *extract.Result, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "extract", trace.WithAttributes(
+		attribute.String("document.mime_type", mimeType),
+		attribute.Bool("extract.isolated", r.executable != ""),
+	))
+	if fi, err := os.Stat(filePath); err == nil {
+		span.SetAttributes(attribute.Int64("document.size_bytes", fi.Size()))
+	}
+	defer func() {
+		if res != nil {
+			span.SetAttributes(resultAttrs(res)...)
+		}
+		tracing.End(span, err)
+	}()
+
+	if r.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.cfg.Timeout)
+		defer cancel()
+	}
+
+	req := workerRequest{
+		Path:             filePath,
+		MIMEType:         mimeType,
+		Password:         opts.Password,
+		MaxOutputBytes:   r.cfg.MaxOutputBytes,
+		MemoryLimitBytes: r.cfg.MemoryLimitBytes,
+	}
+	if r.executable != "" {
+		return r.extractInWorker(ctx, req)
+	}
+	return r.extractInProcess(ctx, req)
+}
+
+// Health reports whether the runner accepts extractions. Only in-process
+// runs can fail it: timed-out parsers cannot be stopped, and once
+// maxAbandoned of them are still running the process needs a restart (or
+// EXTRACT_ISOLATE) to get their CPU and memory back.
+func (r *Runner) Health() error {
+	if n := r.abandoned.Load(); n >= maxAbandoned {
+		return fmt.Errorf("%w: %d timed-out extractions still running", ErrUnavailable, n)
+	}
+	return nil
+}
+
+// resultAttrs describes an extraction result for its span.
+func resultAttrs(res *extract.Result) []attribute.KeyValue {
+	pages := 0
+	for _, s := range res.Segments {
+		if s.Kind == extract.SegmentKindPage {
+			pages++
+		}
+	}
+	return []attribute.KeyValue{
+		attribute.Int("extract.text_bytes", len(res.Text)),
+		attribute.Int("extract.segments", len(res.Segments)),
+		attribute.Int("extract.pages", pages),
+		attribute.Int("extract.attachments", len(res.Attachments)),
+		attribute.Bool("extract.no_text_layer", res.NoTextLayer),
+	}
+}
+
+// extractInProcess runs the extractor on its own goroutine. The deadline
+// only stops the wait: Go cannot kill a goroutine, so a hung parser keeps
+// running in the background. Isolation is the fix for that; without it such
+// runs are counted and, past maxAbandoned, new extractions are refused.
+func (r *Runner) extractInProcess(ctx context.Context, req workerRequest) (*extract.Result, error) {
+	if err := r.Health(); err != nil {
+		return nil, err
+	}
+	type outcome struct {
+		res *extract.Result
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- outcome{nil, fmt.Errorf("%w: %v", ErrCrashed, p)}
+			}
+		}()
+		res, err := runExtract(req)
+		done <- outcome{res, err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.res, o.err
+	case <-ctx.Done():
+		n := r.abandoned.Add(1)
+		log.Printf("warning: extract: abandoned a timed-out in-process extraction of %s (%d still running)", req.Path, n)
+		go func() {
+			<-done
+			r.abandoned.Add(-1)
+		}()
+		return nil, contextError(ctx)
+	}
+}
+
+// runExtract performs one extraction and enforces the output cap.
+func runExtract(req workerRequest) (*extract.Result, error) {
+	res, err := extract.ExtractWithOptions(req.Path, req.MIMEType, extract.Options{Password: req.Password})
+	if err != nil {
+		return nil, err
+	}
+	if req.MaxOutputBytes > 0 {
+		if size := resultSize(res); size > req.MaxOutputBytes {
+			return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrOutputTooLarge, size, req.MaxOutputBytes)
+		}
+	}
+	return res, nil
+}
+
+// resultSize approximates the memory a result holds: its text (segment
+// texts are the same text split up) and attachment contents.
+func resultSize(res *extract.Result) int64 {
+	size := int64(len(res.Text))
+	for _, a := range res.Attachments {
+		size += int64(len(a.Data))
+	}
+	return size
+}
+
+func contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,116 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"docsense/api/internal/adapters/config"
+	"docsense/api/internal/ingest/extract"
+)
+
+// TestMain lets the test binary stand in for the API binary as the
+// extract worker.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == WorkerCommand {
+		os.Exit(RunWorker(os.Stdin, os.Stdout))
+	}
+	os.Exit(m.Run())
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newRunner(t *testing.T, cfg config.ExtractConfig) *Runner {
+	t.Helper()
+	r, err := NewRunner(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRunnerExtract(t *testing.T) {
+	text := writeFile(t, "a.txt", "hello sandbox")
+	notPDF := writeFile(t, "a.pdf", "not a pdf")
+	for _, isolate := range []bool{false, true} {
+		tests := []struct {
+			name     string
+			path     string
+			mimeType string
+			maxBytes int64
+			want     string
+			wantErr  error
+		}{
+			{"text", text, "text/plain", 0, "hello sandbox", nil},
+			{"under the cap", text, "text/plain", 100, "hello sandbox", nil},
+			{"over the cap", text, "text/plain", 5, "", ErrOutputTooLarge},
+			{"corrupt keeps its sentinel", notPDF, "application/pdf", 0, "", extract.ErrCorrupt},
+		}
+		for _, tt := range tests {
+			name := tt.name
+			if isolate {
+				name = "isolated/" + name
+			}
+			t.Run(name, func(t *testing.T) {
+				r := newRunner(t, config.ExtractConfig{Timeout: 30 * time.Second, MaxOutputBytes: tt.maxBytes, Isolate: isolate})
+				res, err := r.Extract(context.Background(), tt.path, tt.mimeType, extract.Options{})
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("err = %v, want %v", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Extract: %v", err)
+				}
+				if res.Text != tt.want {
+					t.Errorf("text = %q, want %q", res.Text, tt.want)
+				}
+			})
+		}
+	}
+}
+
+// samplePDF is a real-world document from the repository's sample data.
+const samplePDF = "../../../data/00000000-0000-0000-0000-000000000001/7a27ec64-abf1-4738-ab10-307cfdd0552b_Resume.pdf"
+
+// TestRunnerDefaultConfig extracts a real PDF under the default limits,
+// which must leave room for an ordinary document.
+func TestRunnerDefaultConfig(t *testing.T) {
+	t.Setenv("RAG_MODE", "fake") // needs no RAG signing key
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Extract.Isolate || cfg.Extract.MemoryLimitBytes == 0 {
+		t.Fatalf("defaults no longer isolate with a memory limit: %+v", cfg.Extract)
+	}
+	res, err := newRunner(t, cfg.Extract).Extract(context.Background(), samplePDF, "application/pdf", extract.Options{})
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if len(res.Text) == 0 {
+		t.Error("no text extracted")
+	}
+}
+
+func TestRunnerMemoryLimit(t *testing.T) {
+	if _, err := processRSS(os.Getpid()); err != nil {
+		t.Skipf("resident memory unavailable: %v", err)
+	}
+	r := newRunner(t, config.ExtractConfig{Timeout: 30 * time.Second, Isolate: true, MemoryLimitBytes: 1 << 20})
+	_, err := r.Extract(context.Background(), samplePDF, "application/pdf", extract.Options{})
+	if !errors.Is(err, ErrCrashed) {
+		t.Fatalf("err = %v, want ErrCrashed", err)
+	}
+}
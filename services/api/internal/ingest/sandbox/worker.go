@@ -0,0 +1,212 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"docsense/api/internal/ingest/extract"
+)
+
+// workerRequest is sent to the worker on stdin. The password travels here
+// rather than on the command line, where other users could read it.
+type workerRequest struct {
+	Path             string `json:"path"`
+	MIMEType         string `json:"mime_type"`
+	Password         string `json:"password,omitempty"`
+	MaxOutputBytes   int64  `json:"max_output_bytes"`
+	MemoryLimitBytes int64  `json:"memory_limit_bytes"`
+}
+
+// workerResponse is written by the worker to stdout.
+type workerResponse struct {
+	Result *extract.Result `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	// Code identifies errors the parent re-creates as sentinel errors.
+	Code string `json:"code,omitempty"`
+}
+
+// Worker error codes and the sentinel errors they stand for.
+var workerErrors = map[string]error{
+	"encrypted":        extract.ErrEncrypted,
+	"corrupt":          extract.ErrCorrupt,
+	"output_too_large": ErrOutputTooLarge,
+	"crashed":          ErrCrashed,
+}
+
+// maxWorkerStderr bounds how much of a crashed worker's stderr is kept for
+// the error message.
+const maxWorkerStderr = 4 << 10
+
+// rssPollInterval is how often the parent samples a worker's resident memory
+// against the memory limit.
+const rssPollInterval = 50 * time.Millisecond
+
+// RunWorker is the extract-worker subcommand: it reads one request from
+// stdin, extracts the document and writes the response to stdout. It returns
+// the process exit code.
+func RunWorker(stdin io.Reader, stdout io.Writer) int {
+	var req workerRequest
+	if err := json.NewDecoder(stdin).Decode(&req); err != nil {
+		fmt.Fprintf(os.Stderr, "extract-worker: read request: %v\n", err)
+		return 2
+	}
+	if req.MemoryLimitBytes > 0 {
+		// The soft limit makes the GC work harder before the parent's
+		// watchdog kills the worker for exceeding the hard limit.
+		debug.SetMemoryLimit(req.MemoryLimitBytes * 3 / 4)
+	}
+
+	var resp workerResponse
+	res, err := func() (res *extract.Result, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("%w: %v", ErrCrashed, p)
+			}
+		}()
+		return runExtract(req)
+	}()
+	if err != nil {
+		resp.Error = err.Error()
+		for code, sentinel := range workerErrors {
+			if errors.Is(err, sentinel) {
+				resp.Code = code
+			}
+		}
+	} else {
+		resp.Result = res
+	}
+
+	if err := json.NewEncoder(stdout).Encode(resp); err != nil {
+		fmt.Fprintf(os.Stderr, "extract-worker: write response: %v\n", err)
+		return 2
+	}
+	return 0
+}
+
+// extractInWorker runs one extraction in a child process. The child is killed
+// when ctx ends or its resident memory exceeds the memory limit; its response
+// is read through a cap derived from the output limit (JSON encodes text and
+// base64 attachments with some overhead).
+func (r *Runner) extractInWorker(ctx context.Context, req workerRequest) (*extract.Result, error) {
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, r.executable, WorkerCommand)
+	cmd.Stdin = bytes.NewReader(reqJSON)
+	// The worker needs no configuration, and no secrets, from the environment.
+	cmd.Env = []string{}
+	cmd.WaitDelay = time.Second
+
+	stdout := limitedBuffer{limit: -1}
+	if req.MaxOutputBytes > 0 {
+		stdout.limit = 2*req.MaxOutputBytes + 1<<20
+	}
+	stderr := limitedBuffer{limit: maxWorkerStderr}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%w: start worker: %v", ErrCrashed, err)
+	}
+	var overLimit atomic.Int64
+	if req.MemoryLimitBytes > 0 {
+		stop := make(chan struct{})
+		defer close(stop)
+		go watchMemory(cmd.Process, req.MemoryLimitBytes, &overLimit, stop)
+	}
+	runErr := cmd.Wait()
+	if ctx.Err() != nil {
+		return nil, contextError(ctx)
+	}
+	if rss := overLimit.Load(); rss > 0 {
+		return nil, fmt.Errorf("%w: worker used %d bytes, memory limit %d", ErrCrashed, rss, req.MemoryLimitBytes)
+	}
+	if stdout.overflow {
+		return nil, fmt.Errorf("%w: worker output exceeds %d bytes", ErrOutputTooLarge, stdout.limit)
+	}
+	if runErr != nil {
+		return nil, fmt.Errorf("%w: worker: %v: %s", ErrCrashed, runErr, strings.TrimSpace(stderr.buf.String()))
+	}
+
+	var resp workerResponse
+	if err := json.Unmarshal(stdout.buf.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("%w: worker response: %v", ErrCrashed, err)
+	}
+	if resp.Error != "" {
+		return nil, &workerError{msg: resp.Error, sentinel: workerErrors[resp.Code]}
+	}
+	if resp.Result == nil {
+		return nil, fmt.Errorf("%w: worker returned no result", ErrCrashed)
+	}
+	return resp.Result, nil
+}
+
+// watchMemory kills the worker once its resident set exceeds limit, recording
+// the size it saw in overLimit, and returns when stop is closed. RSS rather
+// than an address-space rlimit is the measure: the Go runtime reserves far
+// more virtual memory than it uses. Where RSS cannot be read the worker runs
+// under the runtime's soft limit alone.
+func watchMemory(p *os.Process, limit int64, overLimit *atomic.Int64, stop <-chan struct{}) {
+	t := time.NewTicker(rssPollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			rss, err := processRSS(p.Pid)
+			if err != nil {
+				return
+			}
+			if rss > limit {
+				overLimit.Store(rss)
+				_ = p.Kill()
+				return
+			}
+		}
+	}
+}
+
+// workerError carries an error message from the worker and, when it has one,
+// the sentinel error it wrapped there.
+type workerError struct {
+	msg      string
+	sentinel error
+}
+
+func (e *workerError) Error() string { return e.msg }
+func (e *workerError) Unwrap() error { return e.sentinel }
+
+// limitedBuffer collects up to limit bytes (unlimited when negative) and
+// records whether more were written.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit >= 0 {
+		if room := b.limit - int64(b.buf.Len()); int64(len(p)) > room {
+			b.overflow = true
+			if room > 0 {
+				b.buf.Write(p[:room])
+			}
+			// Accept the write so the child is not blocked on a full pipe.
+			return len(p), nil
+		}
+	}
+	return b.buf.Write(p)
+}
@@ -0,0 +1,293 @@
+package tokenize
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BPE is a byte-level byte-pair-encoding tokenizer with cl100k
+// pre-tokenization. Ranks come from a tiktoken file: one base64-encoded
+// token and its rank per line.
+type BPE struct {
+	name  string
+	ranks map[string]int
+}
+
+// LoadBPE reads tiktoken-format ranks from r.
+func LoadBPE(name string, r io.Reader) (*BPE, error) {
+	ranks := make(map[string]int, 100_000)
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		fields := bytes.Fields(sc.Bytes())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s vocabulary line %d: want token and rank", name, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(string(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("%s vocabulary line %d: %w", name, line, err)
+		}
+		rank, err := strconv.Atoi(string(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("%s vocabulary line %d: %w", name, line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	// Every byte must be a token, or some input could not be encoded.
+	for b := 0; b < 256; b++ {
+		if _, ok := ranks[string([]byte{byte(b)})]; !ok {
+			return nil, fmt.Errorf("%s vocabulary has no token for byte %#x", name, b)
+		}
+	}
+	return &BPE{name: name, ranks: ranks}, nil
+}
+
+func (t *BPE) Name() string { return t.name }
+
+// Encode returns the token ids of text. Special tokens such as
+// <|endoftext|> are encoded as ordinary text.
+func (t *BPE) Encode(text string) []int {
+	var ids []int
+	for _, p := range splitCL100k(text) {
+		ids = t.encodePiece(p, ids)
+	}
+	return ids
+}
+
+func (t *BPE) Count(text string) int {
+	n := 0
+	for _, p := range splitCL100k(text) {
+		if _, ok := t.ranks[p]; ok {
+			n++
+			continue
+		}
+		n += len(t.merge(p))
+	}
+	return n
+}
+
+func (t *BPE) encodePiece(p string, ids []int) []int {
+	if rank, ok := t.ranks[p]; ok {
+		return append(ids, rank)
+	}
+	bounds := t.merge(p)
+	for i, start := range bounds {
+		end := len(p)
+		if i+1 < len(bounds) {
+			end = bounds[i+1]
+		}
+		ids = append(ids, t.ranks[p[start:end]])
+	}
+	return ids
+}
+
+// merge applies byte-pair merges to p, lowest rank first (leftmost among
+// equal ranks), and returns the start offsets of the resulting tokens.
+//
+// Parts are a linked list over p's byte offsets and candidate pairs sit in a
+// heap, so a merge costs O(log n) rather than a rescan of every pair: long
+// pre-tokens such as base64 blobs or minified text stay cheap.
+func (t *BPE) merge(p string) []int {
+	n := len(p)
+	// next[i] and prev[i] link the part starting at offset i to its
+	// neighbours; next of the last part is n. gen[i] changes whenever the
+	// part starting at i does, invalidating pairs queued before.
+	next := make([]int, n)
+	prev := make([]int, n)
+	gen := make([]int, n)
+	for i := range next {
+		next[i], prev[i] = i+1, i-1
+	}
+	pairs := &pairHeap{}
+	push := func(i int) {
+		if i < 0 || next[i] >= n {
+			return
+		}
+		if r, ok := t.ranks[p[i:next[next[i]]]]; ok {
+			heap.Push(pairs, pair{rank: r, start: i, gen: gen[i], nextGen: gen[next[i]]})
+		}
+	}
+	for i := 0; i < n; i++ {
+		push(i)
+	}
+
+	for pairs.Len() > 0 {
+		pr := heap.Pop(pairs).(pair)
+		i := pr.start
+		if gen[i] != pr.gen || next[i] >= n || gen[next[i]] != pr.nextGen {
+			continue // a neighbouring merge changed this pair
+		}
+		// Absorb the right part into the left one.
+		j := next[i]
+		next[i] = next[j]
+		if next[i] < n {
+			prev[next[i]] = i
+		}
+		gen[i]++
+		gen[j] = -1
+		push(prev[i])
+		push(i)
+	}
+
+	var bounds []int
+	for i := 0; i < n; i = next[i] {
+		bounds = append(bounds, i)
+	}
+	return bounds
+}
+
+// pair is a candidate merge of the part starting at start with the one after
+// it, valid while both parts still have the generations recorded here.
+type pair struct {
+	rank, start  int
+	gen, nextGen int
+}
+
+// pairHeap orders pairs by rank, then position.
+type pairHeap []pair
+
+func (h pairHeap) Len() int { return len(h) }
+func (h pairHeap) Less(i, j int) bool {
+	if h[i].rank != h[j].rank {
+		return h[i].rank < h[j].rank
+	}
+	return h[i].start < h[j].start
+}
+func (h pairHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *pairHeap) Push(x any)   { *h = append(*h, x.(pair)) }
+func (h *pairHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// splitCL100k pre-tokenizes text the way cl100k_base does, i.e. it matches
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}|
+//	 ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// which Go's regexp cannot express (it has no lookahead).
+func splitCL100k(text string) []string {
+	var out []string
+	for i := 0; i < len(text); {
+		n := cl100kPiece(text[i:])
+		out = append(out, text[i:i+n])
+		i += n
+	}
+	return out
+}
+
+// cl100kPiece returns the length of the pre-token at the start of s.
+func cl100kPiece(s string) int {
+	r, size := utf8.DecodeRuneInString(s)
+	rest := s[size:]
+
+	// Contractions.
+	if r == '\'' {
+		for _, suffix := range []string{"s", "t", "re", "ve", "m", "ll", "d"} {
+			if len(rest) >= len(suffix) && equalFoldASCII(rest[:len(suffix)], suffix) {
+				return size + len(suffix)
+			}
+		}
+	}
+
+	// Letters, optionally preceded by one other character.
+	if isLetter(r) {
+		return size + runLen(rest, isLetter)
+	}
+	if r != '\r' && r != '\n' && !isNumber(r) {
+		if next, _ := utf8.DecodeRuneInString(rest); isLetter(next) {
+			return size + runLen(rest, isLetter)
+		}
+	}
+
+	// Up to three digits.
+	if isNumber(r) {
+		n := size
+		for k := 1; k < 3; k++ {
+			next, sz := utf8.DecodeRuneInString(s[n:])
+			if !isNumber(next) {
+				break
+			}
+			n += sz
+		}
+		return n
+	}
+
+	// Punctuation, optionally preceded by a space, and trailing newlines.
+	if r == ' ' {
+		if next, _ := utf8.DecodeRuneInString(rest); isPunct(next) {
+			n := size + runLen(rest, isPunct)
+			return n + runLen(s[n:], isNewline)
+		}
+	}
+	if isPunct(r) {
+		n := size + runLen(rest, isPunct)
+		return n + runLen(s[n:], isNewline)
+	}
+
+	// Whitespace: up to and including the last newline of the run;
+	// otherwise all but the last character when non-space follows, so that
+	// character can prefix the next word.
+	n := size + runLen(rest, unicode.IsSpace)
+	lastNewline := -1
+	for i, c := range s[:n] {
+		if isNewline(c) {
+			lastNewline = i + 1
+		}
+	}
+	if lastNewline > 0 {
+		return lastNewline
+	}
+	if n < len(s) {
+		_, last := utf8.DecodeLastRuneInString(s[:n])
+		if n-last > 0 {
+			return n - last
+		}
+	}
+	return n
+}
+
+func runLen(s string, f func(rune) bool) int {
+	for i, r := range s {
+		if !f(r) {
+			return i
+		}
+	}
+	return len(s)
+}
+
+func equalFoldASCII(a, b string) bool {
+	for i := 0; i < len(a); i++ {
+		c := a[i]
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if c != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isLetter(r rune) bool  { return unicode.IsLetter(r) }
+func isNumber(r rune) bool  { return unicode.IsNumber(r) }
+func isNewline(r rune) bool { return r == '\r' || r == '\n' }
+
+// isPunct matches [^\s\p{L}\p{N}].
+func isPunct(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
@@ -0,0 +1,91 @@
+// Package tokenize counts text in model tokens, so chunks can be sized to
+// what the embedding model and LLM actually see.
+//
+// Two vocabularies are supported: cl100k (byte-level BPE, as used by recent
+// OpenAI models) and WordPiece (BERT-style embedding models). Vocabulary
+// files are embedded from the vocab directory at build time or read from a
+// path given at runtime; see vocab/README.md.
+package tokenize
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Tokenizer names.
+const (
+	NameCL100k    = "cl100k"
+	NameWordPiece = "wordpiece"
+	NameWords     = "words"
+)
+
+// ErrNoVocabulary is returned by Load when the tokenizer's vocabulary is
+// neither embedded nor given as a file.
+var ErrNoVocabulary = errors.New("tokenizer vocabulary not available")
+
+// Tokenizer counts tokens.
+type Tokenizer interface {
+	// Name identifies the tokenizer, e.g. "cl100k".
+	Name() string
+	// Count returns the number of tokens text encodes to.
+	Count(text string) int
+}
+
+//go:embed vocab
+var embedded embed.FS
+
+// embeddedFiles maps tokenizer names to their vocabulary in the vocab
+// directory.
+var embeddedFiles = map[string]string{
+	NameCL100k:    "vocab/cl100k_base.tiktoken",
+	NameWordPiece: "vocab/wordpiece.txt",
+}
+
+// Load returns the named tokenizer. Its vocabulary is read from vocabFile
+// when set, otherwise from the embedded vocab directory.
+func Load(name, vocabFile string) (Tokenizer, error) {
+	if name == NameWords {
+		return Words{}, nil
+	}
+	path, ok := embeddedFiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tokenizer %q", name)
+	}
+
+	var (
+		r   io.ReadCloser
+		err error
+	)
+	if vocabFile != "" {
+		r, err = os.Open(vocabFile)
+	} else {
+		r, err = embedded.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s (set TOKENIZER_VOCAB_FILE or add %s)", ErrNoVocabulary, name, path)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open %s vocabulary: %w", name, err)
+	}
+	defer r.Close()
+
+	switch name {
+	case NameCL100k:
+		return LoadBPE(NameCL100k, r)
+	default:
+		return LoadWordPiece(r)
+	}
+}
+
+// Words counts whitespace-separated words, for setups that size chunks
+// without a model vocabulary (TOKENIZER=words).
+type Words struct{}
+
+func (Words) Name() string { return NameWords }
+
+func (Words) Count(text string) int { return len(strings.Fields(text)) }
@@ -0,0 +1,142 @@
+package tokenize
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCL100k checks the embedded vocabulary against ids produced by
+// OpenAI's tiktoken for cl100k_base.
+func TestCL100k(t *testing.T) {
+	tok, err := Load(NameCL100k, "")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	bpe := tok.(*BPE)
+	tests := []struct {
+		text string
+		want []int
+	}{
+		{"", nil},
+		{"hello world", []int{15339, 1917}},
+		{"Hello, world!", []int{9906, 11, 1917, 0}},
+		{"tiktoken is great!", []int{83, 1609, 5963, 374, 2294, 0}},
+		{"antidisestablishmentarianism", []int{519, 85342, 34500, 479, 8997, 2191}},
+		{"2 + 2 = 4", []int{17, 489, 220, 17, 284, 220, 19}},
+		{"I'm here, aren't you?", []int{40, 2846, 1618, 11, 7784, 956, 499, 30}},
+		{"HELLO'S WE'LL they've", []int{51812, 1623, 13575, 20255, 6, 4178, 814, 3077}},
+		{"1234567 89", []int{4513, 10961, 22, 220, 4578}},
+		{"  leading spaces\n\n\ttabs and\r\nnewlines  ", []int{220, 6522, 12908, 271, 3324, 3518, 323, 319, 943, 8128, 256}},
+		{"func main() {\n\treturn x+1\n}", []int{2900, 1925, 368, 341, 862, 865, 10, 16, 198, 92}},
+		{"The quick brown fox jumps over the lazy dog.", []int{791, 4062, 14198, 39935, 35308, 927, 279, 16053, 5679, 13}},
+		{"naïve café — “quoted”", []int{3458, 38672, 588, 53050, 2001, 1054, 64825, 863}},
+		{"你好，世界", []int{57668, 53901, 3922, 3574, 244, 98220}},
+		{"🙂👍🏽", []int{9468, 19044, 9468, 239, 235, 9468, 237, 121}},
+		// Special tokens are ordinary text when counting.
+		{"<|endoftext|>", []int{27, 91, 8862, 728, 428, 91, 29}},
+	}
+	for _, tt := range tests {
+		got := bpe.Encode(tt.text)
+		if !reflect.DeepEqual(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+			t.Errorf("Encode(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+		if n := bpe.Count(tt.text); n != len(tt.want) {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, n, len(tt.want))
+		}
+	}
+}
+
+const testWordPieceVocab = `[PAD]
+[UNK]
+[CLS]
+[SEP]
+the
+un
+##aff
+##able
+cafe
+!
+,
+token
+##izer
+##s
+中
+`
+
+func TestWordPiece(t *testing.T) {
+	tok, err := LoadWordPiece(strings.NewReader(testWordPieceVocab))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text string
+		want []int
+	}{
+		{"", nil},
+		{"the", []int{4}},
+		{"unaffable", []int{5, 6, 7}},
+		// Uncased vocabularies lowercase and strip accents.
+		{"The CAFÉ!", []int{4, 8, 9}},
+		{"tokenizers,the", []int{11, 12, 13, 10, 4}},
+		// A word with an unknown piece is a single [UNK].
+		{"unknowable", []int{1}},
+		{"中中", []int{14, 14}},
+		{strings.Repeat("un", 51), []int{1}},
+	}
+	for _, tt := range tests {
+		got := tok.Encode(tt.text)
+		if !reflect.DeepEqual(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+			t.Errorf("Encode(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLoadWordPieceWithoutUnk(t *testing.T) {
+	if _, err := LoadWordPiece(strings.NewReader("the\n##s\n")); err == nil {
+		t.Error("LoadWordPiece accepted a vocabulary without [UNK]")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	if tok, err := Load(NameWords, ""); err != nil || tok.Count(" one two\tthree\n") != 3 {
+		t.Errorf("Load(words) = %v, %v", tok, err)
+	}
+	if _, err := Load("gpt2", ""); err == nil {
+		t.Error("Load accepted an unknown tokenizer")
+	}
+	if _, err := Load(NameCL100k, "/nonexistent/vocab"); err == nil {
+		t.Error("Load accepted a missing vocabulary file")
+	}
+	// The WordPiece vocabulary is not committed; without it Load must fail
+	// rather than fall back to another tokenizer.
+	if _, err := embedded.Open(embeddedFiles[NameWordPiece]); err != nil {
+		if _, err := Load(NameWordPiece, ""); !errors.Is(err, ErrNoVocabulary) {
+			t.Errorf("Load(wordpiece) = %v, want ErrNoVocabulary", err)
+		}
+	}
+}
+
+// TestCL100kLongPiece counts a pre-token with no spaces, such as a base64
+// blob or minified text, which must not cost time quadratic in its length.
+// The input is letters only, so it stays a single pre-token.
+func TestCL100kLongPiece(t *testing.T) {
+	tok, err := Load(NameCL100k, "")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var b strings.Builder
+	for b.Len() < 1<<20 {
+		b.WriteString("QmFzZSBibGIgaWRoZXQgcBhYVzqXKx")
+	}
+	start := time.Now()
+	n := tok.Count(b.String())
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Count of a %d-byte piece took %s", b.Len(), elapsed)
+	}
+	if n == 0 || n > b.Len() {
+		t.Errorf("Count = %d for %d bytes", n, b.Len())
+	}
+}
@@ -0,0 +1,133 @@
+package tokenize
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxWordPieceRunes is the longest word WordPiece will split; longer words
+// become a single unknown token, as in BERT.
+const maxWordPieceRunes = 100
+
+// WordPiece is a BERT-style tokenizer: text is split on whitespace and
+// punctuation, then each word greedily into the longest vocabulary entries,
+// continuations prefixed with "##". The vocabulary file has one token per
+// line, ids being line numbers.
+type WordPiece struct {
+	vocab map[string]int
+	unk   int
+	// lowercase is set for uncased vocabularies, which also strip accents.
+	lowercase bool
+}
+
+// LoadWordPiece reads a vocab.txt from r. Whether the vocabulary is cased
+// is inferred from its entries.
+func LoadWordPiece(r io.Reader) (*WordPiece, error) {
+	t := &WordPiece{vocab: make(map[string]int, 32_000), lowercase: true}
+	sc := bufio.NewScanner(r)
+	for id := 0; sc.Scan(); id++ {
+		token := strings.TrimRight(sc.Text(), "\r")
+		t.vocab[token] = id
+		if !strings.HasPrefix(token, "[") && strings.ToLower(token) != token {
+			t.lowercase = false
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	unk, ok := t.vocab["[UNK]"]
+	if !ok {
+		return nil, fmt.Errorf("wordpiece vocabulary has no [UNK] token")
+	}
+	t.unk = unk
+	return t, nil
+}
+
+func (t *WordPiece) Name() string { return NameWordPiece }
+
+// Encode returns the token ids of text, without [CLS] and [SEP].
+func (t *WordPiece) Encode(text string) []int {
+	var ids []int
+	for _, w := range t.words(text) {
+		ids = t.encodeWord(w, ids)
+	}
+	return ids
+}
+
+func (t *WordPiece) Count(text string) int {
+	return len(t.Encode(text))
+}
+
+func (t *WordPiece) encodeWord(w string, ids []int) []int {
+	runes := []rune(w)
+	if len(runes) > maxWordPieceRunes {
+		return append(ids, t.unk)
+	}
+	start := len(ids)
+	for i := 0; i < len(runes); {
+		end, id := len(runes), -1
+		for ; end > i; end-- {
+			sub := string(runes[i:end])
+			if i > 0 {
+				sub = "##" + sub
+			}
+			if v, ok := t.vocab[sub]; ok {
+				id = v
+				break
+			}
+		}
+		if id < 0 {
+			return append(ids[:start], t.unk)
+		}
+		ids = append(ids, id)
+		i = end
+	}
+	return ids
+}
+
+// words runs BERT's basic tokenization: control characters are dropped, CJK
+// ideographs and punctuation become words of their own, and uncased
+// vocabularies get lowercased, accent-free text.
+func (t *WordPiece) words(text string) []string {
+	if t.lowercase {
+		text = strings.ToLower(text)
+		text = norm.NFD.String(text)
+	}
+	var words []string
+	var b strings.Builder
+	flush := func() {
+		if b.Len() > 0 {
+			words = append(words, b.String())
+			b.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case r == 0 || r == unicode.ReplacementChar || (unicode.IsControl(r) && !unicode.IsSpace(r)):
+		case t.lowercase && unicode.Is(unicode.Mn, r):
+		case unicode.IsSpace(r):
+			flush()
+		case isBERTPunct(r) || unicode.Is(unicode.Han, r):
+			flush()
+			words = append(words, string(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	flush()
+	return words
+}
+
+// isBERTPunct treats all non-alphanumeric ASCII as punctuation, like BERT,
+// in addition to Unicode punctuation.
+func isBERTPunct(r rune) bool {
+	if r < 128 && r > ' ' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+		return true
+	}
+	return unicode.IsPunct(r)
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Histogram buckets, in seconds or bytes.
+var (
+	requestBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+	stageBuckets   = []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120}
+	sizeBuckets    = []float64{1 << 10, 16 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20, 64 << 20}
+)
+
+// The API's metrics.
+var (
+	HTTPRequests = promauto.With(Default).NewCounterVec(prometheus.CounterOpts{
+		Name: "docsense_http_requests_total",
+		Help: "HTTP requests handled, by method, route and status.",
+	}, []string{"method", "route", "status"})
+	HTTPRequestDuration = promauto.With(Default).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "docsense_http_request_duration_seconds",
+		Help:    "Time to handle HTTP requests, by method, route and status.",
+		Buckets: requestBuckets,
+	}, []string{"method", "route", "status"})
+
+	UploadSize = promauto.With(Default).NewHistogram(prometheus.HistogramOpts{
+		Name:    "docsense_upload_size_bytes",
+		Help:    "Size of uploaded files.",
+		Buckets: sizeBuckets,
+	})
+	IngestStageDuration = promauto.With(Default).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "docsense_ingest_stage_duration_seconds",
+		Help:    "Time spent in each ingestion stage (save, extract, normalize, chunk, store, index).",
+		Buckets: stageBuckets,
+	}, []string{"stage"})
+
+	RAGRequestDuration = promauto.With(Default).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "docsense_rag_request_duration_seconds",
+		Help:    "Time of calls to the RAG service including retries, by operation.",
+		Buckets: stageBuckets,
+	}, []string{"operation"})
+	RAGErrors = promauto.With(Default).NewCounterVec(prometheus.CounterOpts{
+		Name: "docsense_rag_errors_total",
+		Help: "Failed calls to the RAG service, by operation and kind (unavailable, bad_request, circuit_open, other).",
+	}, []string{"operation", "kind"})
+
+	QueriesInFlight = promauto.With(Default).NewGauge(prometheus.GaugeOpts{
+		Name: "docsense_queries_in_flight",
+		Help: "Queries being answered.",
+	})
+)
@@ -0,0 +1,51 @@
+// Package metrics holds the API's Prometheus metrics and the registry
+// served at /metrics.
+//
+// Metrics are prometheus/client_golang collectors registered on Default,
+// which also carries the Go runtime and process collectors. The metrics
+// the API records are defined in docsense.go; values read rather than
+// updated, such as connection pool stats and documents by status, are
+// collectors queried at scrape time.
+package metrics
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Default is the registry served at /metrics.
+var Default = prometheus.NewRegistry()
+
+func init() {
+	Default.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves Default's metrics in the Prometheus exposition formats.
+// A collector that fails is logged and left out of that scrape.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Default, promhttp.HandlerOpts{
+		ErrorLog:      log.Default(),
+		ErrorHandling: promhttp.ContinueOnError,
+		Registry:      Default,
+	})
+}
+
+// RegisterDBStats registers db's connection pool stats (go_sql_*, labelled
+// db_name="docsense"), read at scrape time.
+func RegisterDBStats(r prometheus.Registerer, db *sql.DB) error {
+	return r.Register(collectors.NewDBStatsCollector(db, "docsense"))
+}
+
+// Since returns the seconds elapsed since start, for histograms.
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	RAGErrors.WithLabelValues("retrieve", "unavailable").Inc()
+	QueriesInFlight.Inc()
+	defer QueriesInFlight.Dec()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE docsense_rag_errors_total counter",
+		`docsense_rag_errors_total{kind="unavailable",operation="retrieve"} 1`,
+		"docsense_queries_in_flight 1",
+		"# TYPE docsense_upload_size_bytes histogram",
+		"go_goroutines ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape has no %q", want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package ports
+
+import (
+	"context"
+
+	"docsense/api/internal/adapters/rag"
+	"docsense/api/internal/adapters/vector"
+)
+
+// VectorIndex stores chunk embeddings. Point IDs are chunk IDs.
+type VectorIndex interface {
+	// EmbedChunks embeds and upserts chunks of a document and returns the
+	// IDs of the points written.
+	EmbedChunks(ctx context.Context, documentID string, chunks []rag.ChunkIn) ([]string, error)
+	// UpdatePayloads replaces the stored metadata of embedded chunks without
+	// embedding them again.
+	UpdatePayloads(ctx context.Context, documentID string, chunks []rag.ChunkIn) (int, error)
+	// ListPoints pages through all points; offset is "" for the first page.
+	ListPoints(ctx context.Context, offset string, limit int) (*rag.PointsResponse, error)
+	// DeletePoints deletes points by ID, ignoring unknown IDs.
+	DeletePoints(ctx context.Context, pointIDs []string) (int, error)
+}
+
+// Retriever finds the chunks most relevant to a query.
+type Retriever interface {
+	Retrieve(ctx context.Context, query string, topK int) (*rag.RetrieveResponse, error)
+}
+
+// FilteredRetriever is a Retriever that can restrict the search to a
+// user's documents, or some of them. Handlers use it when the backend
+// implements it.
+type FilteredRetriever interface {
+	RetrieveFiltered(ctx context.Context, query string, topK int, filter rag.Filter) (*rag.RetrieveResponse, error)
+}
+
+// Generator answers a query from passages, citing them.
+type Generator interface {
+	Generate(ctx context.Context, query string, contexts []rag.ContextChunk) (*rag.GenerateResponse, error)
+	// GenerateStream is Generate with onDelta called with each piece of
+	// the answer as it is produced.
+	GenerateStream(ctx context.Context, query string, contexts []rag.ContextChunk, onDelta func(string) error) (*rag.GenerateResponse, error)
+}
+
+// RAG is the retrieval-augmented generation backend the document handlers
+// use: the RAG service (rag.Client) or, for offline development, the
+// in-process rag.Fake. The embedded vector.Index can take over indexing and
+// retrieval from either.
+type RAG interface {
+	VectorIndex
+	Retriever
+	Generator
+}
+
+var (
+	_ RAG               = (*rag.Client)(nil)
+	_ FilteredRetriever = (*rag.Client)(nil)
+	_ RAG               = (*rag.Fake)(nil)
+
+	_ VectorIndex       = (*vector.Index)(nil)
+	_ FilteredRetriever = (*vector.Index)(nil)
+)
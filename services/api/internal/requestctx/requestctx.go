@@ -0,0 +1,51 @@
+// Package requestctx carries a request's correlation ID through
+// context.Context.
+//
+// The HTTP middleware stores it for each incoming request; outgoing calls
+// (the RAG client) forward it with Inject so logs on both sides can be
+// correlated. Trace context travels separately, as OpenTelemetry spans
+// (see package tracing).
+package requestctx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader carries the request ID.
+const RequestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random 16-byte hex ID.
+func NewRequestID() string {
+	return randomHex(16)
+}
+
+// Inject sets the request ID carried by ctx on an outgoing request's
+// headers.
+func Inject(ctx context.Context, h http.Header) {
+	if id := RequestID(ctx); id != "" {
+		h.Set(RequestIDHeader, id)
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	// crypto/rand.Read does not fail on supported platforms.
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
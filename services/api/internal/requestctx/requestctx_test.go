@@ -0,0 +1,27 @@
+package requestctx
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestInject(t *testing.T) {
+	h := http.Header{}
+	Inject(context.Background(), h)
+	if len(h) != 0 {
+		t.Errorf("Inject without a request ID set %v", h)
+	}
+
+	Inject(WithRequestID(context.Background(), "req-1"), h)
+	if got := h.Get(RequestIDHeader); got != "req-1" {
+		t.Errorf("%s = %q, want req-1", RequestIDHeader, got)
+	}
+}
+
+func TestNewRequestID(t *testing.T) {
+	a, b := NewRequestID(), NewRequestID()
+	if len(a) != 32 || a == b {
+		t.Errorf("NewRequestID() = %q, %q", a, b)
+	}
+}
@@ -0,0 +1,108 @@
+// Package tracing sets up OpenTelemetry tracing: the global tracer
+// provider, exporting spans over OTLP/HTTP or as JSON to stdout or a file,
+// and W3C trace context propagation.
+//
+// Instrumentation uses the OpenTelemetry API directly: otelgin starts a
+// span per request (middleware.Tracing), otelsql one per SQL statement
+// (postgres.Open) and otelhttp one per RAG request attempt (rag.Client).
+// The API's own spans, such as ingestion stages, come from Tracer.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"docsense/api/internal/adapters/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName names the instrumentation that produces the API's own spans.
+const ScopeName = "docsense/api"
+
+// Tracer returns the tracer for the API's own spans. Before Setup it
+// records nothing.
+func Tracer() trace.Tracer {
+	return otel.Tracer(ScopeName)
+}
+
+// Setup installs a global tracer provider exporting as configured and the
+// W3C trace context propagator. It returns a function that flushes queued
+// spans and stops the provider.
+//
+// With the "none" exporter nothing is recorded, but spans still get IDs,
+// so trace context is propagated and error bodies carry a trace ID.
+func Setup(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	var (
+		exp  sdktrace.SpanExporter
+		file *os.File
+		err  error
+	)
+	switch cfg.Exporter {
+	case "none":
+	case "otlp":
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint)}
+		if len(cfg.OTLPHeaders) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.OTLPHeaders))
+		}
+		exp, err = otlptracehttp.New(ctx, opts...)
+	case "console":
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "file":
+		file, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+		if err != nil {
+			return nil, fmt.Errorf("open trace file: %w", err)
+		}
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		if file != nil {
+			_ = file.Close()
+		}
+		return nil, fmt.Errorf("trace exporter: %w", err)
+	}
+
+	// New traces are sampled at SampleRatio; others follow the caller.
+	root := sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName))),
+	}
+	if exp == nil {
+		root = sdktrace.NeverSample()
+	} else {
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	}
+	opts = append(opts, sdktrace.WithSampler(sdktrace.ParentBased(root)))
+	tp := sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if file != nil {
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}, nil
+}
+
+// End ends span, marking it failed with err if err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
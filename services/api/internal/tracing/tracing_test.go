@@ -0,0 +1,110 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"docsense/api/internal/adapters/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetupFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := Setup(context.Background(), config.TracingConfig{
+		Exporter:    "file",
+		File:        path,
+		ServiceName: "test",
+		SampleRatio: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, parent := Tracer().Start(context.Background(), "ingest")
+	_, child := Tracer().Start(ctx, "extract")
+	End(child, errors.New("parser crashed"))
+	End(parent, nil)
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type span struct {
+		Name        string
+		SpanContext struct{ TraceID string }
+		Parent      struct{ SpanID string }
+		Status      struct{ Code, Description string }
+	}
+	spans := map[string]span{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var s span
+		if err := json.Unmarshal([]byte(line), &s); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		spans[s.Name] = s
+	}
+	in, ex := spans["ingest"], spans["extract"]
+	if in.Name == "" || ex.Name == "" {
+		t.Fatalf("exported spans = %s", data)
+	}
+	if ex.SpanContext.TraceID != in.SpanContext.TraceID || ex.Parent.SpanID == "" {
+		t.Errorf("extract is not a child of ingest: %+v %+v", ex, in)
+	}
+	if ex.Status.Code != "Error" || ex.Status.Description != "parser crashed" {
+		t.Errorf("extract status = %+v, want Error", ex.Status)
+	}
+	if in.Status.Code == "Error" {
+		t.Errorf("ingest status = %+v, want unset", in.Status)
+	}
+}
+
+func TestSetupNonePropagates(t *testing.T) {
+	shutdown, err := Setup(context.Background(), config.TracingConfig{Exporter: "none", SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	ctx, span := Tracer().Start(context.Background(), "request")
+	defer span.End()
+	if span.IsRecording() {
+		t.Error("span recorded without an exporter")
+	}
+	if !span.SpanContext().IsValid() {
+		t.Fatal("span has no trace ID")
+	}
+	h := propagation.HeaderCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, h)
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-00"
+	if got := h.Get("traceparent"); got != want {
+		t.Errorf("traceparent = %q, want %q", got, want)
+	}
+
+	// A sampled caller's decision is followed.
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	_, child := Tracer().Start(trace.ContextWithRemoteSpanContext(context.Background(), parent), "child")
+	defer child.End()
+	if !child.SpanContext().IsSampled() || child.SpanContext().TraceID() != parent.TraceID() {
+		t.Errorf("child of a sampled caller = %+v", child.SpanContext())
+	}
+}
+
+func TestSetupUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), config.TracingConfig{Exporter: "zipkin"}); err == nil {
+		t.Error("Setup accepted an unknown exporter")
+	}
+}
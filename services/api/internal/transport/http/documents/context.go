@@ -0,0 +1,149 @@
+package documents
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"docsense/api/internal/transport/http/middleware"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/google/uuid"
+)
+
+const (
+	defaultContextWindow = 300
+	maxContextWindow     = 5000
+)
+
+// ChunkContext returns a chunk with the document text around it, so a
+// client can show a citation in place: "before", "text" (the chunk, to be
+// highlighted) and "after" concatenate to a contiguous excerpt of the
+// document starting at character offset "context_start".
+//
+// Route: GET /api/documents/:id/chunks/:chunk_id/context?window=300
+//
+// window is the number of characters of context on each side; the excerpt
+// is trimmed to whole words. Chunks stored without offsets (ingested before
+// they were recorded) are returned without context and null offsets.
+func (h *Handler) ChunkContext(c *gin.Context) {
+	userID, ok := middleware.GetAuthenticatedUserID(c)
+	if !ok {
+		middleware.AbortUnauthorized(c)
+		return
+	}
+
+	docID, chunkID := c.Param("id"), c.Param("chunk_id")
+	if _, err := uuid.Parse(docID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+	if _, err := uuid.Parse(chunkID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "chunk not found"})
+		return
+	}
+
+	window := defaultContextWindow
+	if v := c.Query("window"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid window: " + v})
+			return
+		}
+		window = min(n, maxContextWindow)
+	}
+
+	var (
+		chunkIndex           int
+		text                 string
+		start, end           sql.NullInt64
+		before, cited, after sql.NullString
+	)
+	// Postgres substr counts characters from 1, matching the stored
+	// character offsets. Context is fetched one character longer than the
+	// window on each side to tell whether it cuts a word.
+	err := h.db.QueryRowContext(
+		c.Request.Context(),
+		`SELECT dc.chunk_index, dc.content_text, dc.start_offset, dc.end_offset,
+            substr(ct.content, greatest(dc.start_offset - $3 - 1, 0) + 1, least(dc.start_offset, $3 + 1)),
+            substr(ct.content, dc.start_offset + 1, dc.end_offset - dc.start_offset),
+            substr(ct.content, dc.end_offset + 1, $3 + 1)
+     FROM document_chunks dc
+     JOIN documents d ON d.id = dc.document_id
+     LEFT JOIN document_contents ct ON ct.document_id = dc.document_id
+     WHERE dc.id = $1 AND dc.document_id = $2 AND d.user_id = $4`,
+		chunkID,
+		docID,
+		window,
+		userID,
+	).Scan(&chunkIndex, &text, &start, &end, &before, &cited, &after)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "chunk not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query chunk"})
+		return
+	}
+
+	resp := gin.H{
+		"document_id":   docID,
+		"chunk_id":      chunkID,
+		"chunk_index":   chunkIndex,
+		"start_offset":  nil,
+		"end_offset":    nil,
+		"context_start": nil,
+		"before":        "",
+		"text":          text,
+		"after":         "",
+	}
+	if start.Valid && end.Valid && cited.Valid {
+		b := trimToWordStart(before.String, window)
+		resp["start_offset"] = start.Int64
+		resp["end_offset"] = end.Int64
+		resp["context_start"] = start.Int64 - int64(len([]rune(b)))
+		resp["before"] = b
+		resp["text"] = cited.String
+		resp["after"] = trimToWordEnd(after.String, window)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// trimToWordStart cuts the context before a chunk, fetched one character
+// longer than window, to at most window characters without a leading
+// partial word.
+func trimToWordStart(s string, window int) string {
+	r := []rune(s)
+	if len(r) <= window {
+		return s
+	}
+	if unicode.IsSpace(r[0]) {
+		return string(r[1:])
+	}
+	s = string(r[1:])
+	if i := strings.IndexFunc(s, unicode.IsSpace); i >= 0 {
+		return s[i:]
+	}
+	return ""
+}
+
+// trimToWordEnd cuts the context after a chunk, fetched one character
+// longer than window, to at most window characters without a trailing
+// partial word.
+func trimToWordEnd(s string, window int) string {
+	r := []rune(s)
+	if len(r) <= window {
+		return s
+	}
+	if unicode.IsSpace(r[window]) {
+		return string(r[:window])
+	}
+	s = string(r[:window])
+	if i := strings.LastIndexFunc(s, unicode.IsSpace); i >= 0 {
+		return s[:i+1]
+	}
+	return ""
+}
@@ -0,0 +1,29 @@
+package documents
+
+import "testing"
+
+func TestTrimContext(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		window        int
+		wantB, wantA  string
+	}{
+		{"shorter than window", "one two", "three four", 10, "one two", "three four"},
+		{"cut at a space", " one two", "one two ", 7, "one two", "one two"},
+		{"partial words dropped", "xone two", "three fourx", 7, " two", "three "},
+		{"single long word", "abcdefgh", "abcdefgh", 5, "", ""},
+		{"multibyte", "ßüñ café", "naïve çà", 7, " café", "naïve "},
+		{"zero window", "a", "b", 0, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimToWordStart(tt.before, tt.window); got != tt.wantB {
+				t.Errorf("trimToWordStart(%q, %d) = %q, want %q", tt.before, tt.window, got, tt.wantB)
+			}
+			if got := trimToWordEnd(tt.after, tt.window); got != tt.wantA {
+				t.Errorf("trimToWordEnd(%q, %d) = %q, want %q", tt.after, tt.window, got, tt.wantA)
+			}
+		})
+	}
+}
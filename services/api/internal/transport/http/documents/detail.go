@@ -0,0 +1,110 @@
+package documents
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"docsense/api/internal/transport/http/middleware"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/google/uuid"
+)
+
+// Get returns one document with its extracted metadata.
+//
+// Route: GET /api/documents/:id
+//
+// The document's own outline (PDF bookmarks) is returned as "toc" rather
+// than inside "metadata".
+func (h *Handler) Get(c *gin.Context) {
+	userID, ok := middleware.GetAuthenticatedUserID(c)
+	if !ok {
+		middleware.AbortUnauthorized(c)
+		return
+	}
+
+	docID := c.Param("id")
+	if _, err := uuid.Parse(docID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+
+	type docResp struct {
+		ID               string                     `json:"id"`
+		Title            *string                    `json:"title"`
+		Filename         *string                    `json:"filename"`
+		MimeType         *string                    `json:"mime_type"`
+		SizeBytes        *int64                     `json:"size_bytes"`
+		CreatedAt        time.Time                  `json:"created_at"`
+		UpdatedAt        time.Time                  `json:"updated_at"`
+		Status           *string                    `json:"status"`
+		ParentDocumentID *string                    `json:"parent_document_id"`
+		Metadata         map[string]json.RawMessage `json:"metadata"`
+		TOC              json.RawMessage            `json:"toc"`
+		Embedding        embeddingProgress          `json:"embedding"`
+	}
+
+	var (
+		d                                 docResp
+		title, filename, mimeType, status sql.NullString
+		parentID                          sql.NullString
+		size                              sql.NullInt64
+		metaJSON                          []byte
+	)
+	err := h.db.QueryRowContext(
+		c.Request.Context(),
+		`SELECT d.id, d.title, d.filename, d.mime_type, d.size_bytes, d.created_at, d.updated_at, d.status,
+            d.parent_document_id::text, d.metadata, p.expected, p.embedded
+     FROM documents d
+     CROSS JOIN LATERAL (
+       SELECT count(*) AS expected, count(embedded_at) AS embedded
+       FROM document_chunks
+       WHERE document_id = d.id AND NOT is_parent
+     ) p
+     WHERE d.id = $1 AND d.user_id = $2`,
+		docID,
+		userID,
+	).Scan(&d.ID, &title, &filename, &mimeType, &size, &d.CreatedAt, &d.UpdatedAt, &status, &parentID, &metaJSON, &d.Embedding.Expected, &d.Embedding.Embedded)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query document"})
+		return
+	}
+
+	if err := json.Unmarshal(metaJSON, &d.Metadata); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decode document metadata"})
+		return
+	}
+	d.TOC = json.RawMessage("[]")
+	if outline, ok := d.Metadata["outline"]; ok {
+		d.TOC = outline
+		delete(d.Metadata, "outline")
+	}
+
+	if title.Valid {
+		d.Title = &title.String
+	}
+	if filename.Valid {
+		d.Filename = &filename.String
+	}
+	if mimeType.Valid {
+		d.MimeType = &mimeType.String
+	}
+	if size.Valid {
+		d.SizeBytes = &size.Int64
+	}
+	if status.Valid {
+		d.Status = &status.String
+	}
+	if parentID.Valid {
+		d.ParentDocumentID = &parentID.String
+	}
+
+	c.JSON(http.StatusOK, d)
+}
@@ -0,0 +1,246 @@
+package documents
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"docsense/api/internal/adapters/rag"
+	"docsense/api/internal/transport/http/middleware"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// embeddingProgress is how many of a document's chunks (parents excluded)
+// are embedded.
+type embeddingProgress struct {
+	Expected int `json:"expected"`
+	Embedded int `json:"embedded"`
+}
+
+// Embed resumes indexing of a partially indexed document: chunks that are
+// not embedded yet are sent to the RAG service again.
+//
+// Route: POST /api/documents/:id/embed
+//
+// The response has the document's resulting status ("ready" or "partial")
+// and its "embedding" progress.
+func (h *Handler) Embed(c *gin.Context) {
+	userID, ok := middleware.GetAuthenticatedUserID(c)
+	if !ok {
+		middleware.AbortUnauthorized(c)
+		return
+	}
+
+	docID := c.Param("id")
+	if _, err := uuid.Parse(docID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+
+	var status string
+	err := h.db.QueryRowContext(
+		c.Request.Context(),
+		`SELECT status FROM documents WHERE id = $1 AND user_id = $2`,
+		docID,
+		userID,
+	).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query document"})
+		return
+	}
+	if status != statusReady && status != statusPartial {
+		c.JSON(http.StatusConflict, gin.H{"error": "document is not ingested", "status": status})
+		return
+	}
+
+	chunks, err := h.pendingChunks(c.Request.Context(), docID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query chunks"})
+		return
+	}
+	_, embedErr := h.embedChunks(c.Request.Context(), docID, chunks)
+	status, progress, err := h.updateEmbeddingStatus(c.Request.Context(), docID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update document status"})
+		return
+	}
+
+	resp := gin.H{"document_id": docID, "status": status, "embedding": progress}
+	if embedErr != nil {
+		log.Printf("warning: document %s: failed to embed chunks: %v", docID, embedErr)
+		resp["error"] = "failed to embed chunks"
+		c.JSON(ragErrorStatus(embedErr), resp)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// embedChunks embeds chunks of a document in batches of
+// h.embedding.BatchSize, up to h.embedding.Concurrency at a time, and
+// records each batch as indexed when it succeeds; a failing batch does not
+// stop the others. It returns how many chunks were recorded as indexed and
+// the first batch error.
+func (h *Handler) embedChunks(ctx context.Context, documentID string, chunks []rag.ChunkIn) (int, error) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		embedded int
+		firstErr error
+		slots    = make(chan struct{}, h.embedding.Concurrency)
+	)
+	for start := 0; start < len(chunks) && ctx.Err() == nil; start += h.embedding.BatchSize {
+		batch := chunks[start:min(start+h.embedding.BatchSize, len(chunks))]
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			n, err := h.embedBatch(ctx, documentID, batch)
+			mu.Lock()
+			defer mu.Unlock()
+			embedded += n
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("chunks %d-%d: %w", batch[0].ChunkIndex, batch[len(batch)-1].ChunkIndex, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr == nil && embedded < len(chunks) {
+		firstErr = ctx.Err()
+	}
+	return embedded, firstErr
+}
+
+// embedBatch embeds one batch of chunks (the client retries transient
+// failures) and records the batch as indexed.
+func (h *Handler) embedBatch(ctx context.Context, documentID string, batch []rag.ChunkIn) (int, error) {
+	pointIDs, err := h.ragClient.EmbedChunks(ctx, documentID, batch)
+	if err != nil {
+		return 0, err
+	}
+	if err := h.recordEmbedded(ctx, pointIDs); err != nil {
+		return 0, err
+	}
+	return len(pointIDs), nil
+}
+
+// recordEmbedded links chunks to their vector points, whose IDs are the
+// chunk IDs.
+func (h *Handler) recordEmbedded(ctx context.Context, pointIDs []string) error {
+	if len(pointIDs) == 0 {
+		return nil
+	}
+	_, err := h.db.ExecContext(
+		ctx,
+		`UPDATE document_chunks
+		 SET qdrant_point_id = id, embedded_at = now(), updated_at = now()
+		 WHERE id = ANY($1::uuid[])`,
+		pq.Array(pointIDs),
+	)
+	return err
+}
+
+// updateEmbeddingStatus counts the embedded chunks of an ingested document
+// and marks it ready if all are embedded, partial otherwise. Documents in
+// other states are left alone and reported with an empty status.
+func (h *Handler) updateEmbeddingStatus(ctx context.Context, documentID string) (string, embeddingProgress, error) {
+	var (
+		status   string
+		progress embeddingProgress
+	)
+	err := h.db.QueryRowContext(
+		ctx,
+		`WITH p AS (
+		   SELECT count(*) AS expected, count(embedded_at) AS embedded
+		   FROM document_chunks
+		   WHERE document_id = $1 AND NOT is_parent
+		 )
+		 UPDATE documents d
+		 SET status = CASE WHEN p.embedded < p.expected THEN $3 ELSE $2 END, updated_at = now()
+		 FROM p
+		 WHERE d.id = $1 AND d.status IN ($2, $3)
+		 RETURNING d.status, p.expected, p.embedded`,
+		documentID,
+		statusReady,
+		statusPartial,
+	).Scan(&status, &progress.Expected, &progress.Embedded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", progress, nil
+	}
+	return status, progress, err
+}
+
+// documentStatus returns a document's stored status and embedding progress.
+func (h *Handler) documentStatus(ctx context.Context, documentID string) (string, embeddingProgress, error) {
+	var (
+		status   string
+		progress embeddingProgress
+	)
+	err := h.db.QueryRowContext(
+		ctx,
+		`SELECT d.status,
+		        count(c.id) FILTER (WHERE NOT c.is_parent),
+		        count(c.embedded_at) FILTER (WHERE NOT c.is_parent)
+		 FROM documents d
+		 LEFT JOIN document_chunks c ON c.document_id = d.id
+		 WHERE d.id = $1
+		 GROUP BY d.status`,
+		documentID,
+	).Scan(&status, &progress.Expected, &progress.Embedded)
+	return status, progress, err
+}
+
+// pendingChunks returns a document's chunks that are not embedded, in
+// document order.
+func (h *Handler) pendingChunks(ctx context.Context, documentID string) ([]rag.ChunkIn, error) {
+	rows, err := h.db.QueryContext(
+		ctx,
+		`SELECT `+chunkInColumns+`
+		 FROM document_chunks
+		 WHERE document_id = $1 AND NOT is_parent AND embedded_at IS NULL
+		 ORDER BY chunk_index`,
+		documentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanChunkIns(rows)
+}
+
+// chunkInColumns selects the document_chunks columns scanChunkIns reads.
+const chunkInColumns = `id::text, chunk_index, content_text, coalesce(segment_kind, ''),
+		        coalesce(segment_start, 0), coalesce(segment_end, 0), coalesce(segment_title, ''), section_path`
+
+// scanChunkIns reads chunks to embed from rows selecting chunkInColumns and
+// closes rows.
+func scanChunkIns(rows *sql.Rows) ([]rag.ChunkIn, error) {
+	defer rows.Close()
+	var chunks []rag.ChunkIn
+	for rows.Next() {
+		var ch rag.ChunkIn
+		if err := rows.Scan(&ch.ChunkID, &ch.ChunkIndex, &ch.Text, &ch.SegmentKind,
+			&ch.SegmentStart, &ch.SegmentEnd, &ch.SegmentTitle, pq.Array(&ch.SectionPath)); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, ch)
+	}
+	return chunks, rows.Err()
+}
@@ -0,0 +1,255 @@
+package documents
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"docsense/api/internal/adapters/rag"
+
+	"github.com/lib/pq"
+)
+
+// contextWindow is a span of a document's stored content, in characters,
+// that one or more retrieved chunks expanded to. hit is the best-scoring
+// of them, which citations refer to.
+type contextWindow struct {
+	documentID string
+	start, end int
+	hit        rag.RetrievedChunkOut
+	text       string
+}
+
+// ownedMatches drops matches that are not in one of userID's documents,
+// including matches without a document. The RAG service may search chunks
+// of every user, so nothing it returns is trusted before this check.
+func (h *Handler) ownedMatches(ctx context.Context, userID string, matches []rag.RetrievedChunkOut) ([]rag.RetrievedChunkOut, error) {
+	var docIDs []string
+	for _, m := range matches {
+		if m.DocumentID != nil {
+			docIDs = append(docIDs, *m.DocumentID)
+		}
+	}
+	if len(docIDs) == 0 {
+		return nil, nil
+	}
+
+	rows, err := h.db.QueryContext(
+		ctx,
+		`SELECT id FROM documents WHERE id = ANY($1::uuid[]) AND user_id = $2`,
+		pq.Array(docIDs),
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	owned := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		owned[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []rag.RetrievedChunkOut
+	for _, m := range matches {
+		if m.DocumentID != nil && owned[*m.DocumentID] {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+// expandMatches turns retrieved chunks of userID's documents into the
+// passages to answer from. A chunk cut from a parent (hierarchical chunking) expands to the parent;
+// other chunks expand to h.neighborWindow chunks on each side. Windows
+// that overlap or touch within a document are merged. Matches that cannot
+// be expanded (no location, chunks stored without offsets, or no longer in
+// the database) are passed through as retrieved.
+//
+// Passages are returned best score first.
+func (h *Handler) expandMatches(ctx context.Context, userID string, matches []rag.RetrievedChunkOut) ([]rag.ContextChunk, error) {
+	var (
+		windows     []contextWindow
+		passthrough []rag.RetrievedChunkOut
+		docIDs      []string
+		indexes     []int64
+		located     []rag.RetrievedChunkOut
+	)
+	for _, m := range matches {
+		if m.DocumentID == nil || m.ChunkIndex == nil {
+			passthrough = append(passthrough, m)
+			continue
+		}
+		docIDs = append(docIDs, *m.DocumentID)
+		indexes = append(indexes, int64(*m.ChunkIndex))
+		located = append(located, m)
+	}
+
+	if len(located) > 0 {
+		spans, err := h.expansionSpans(ctx, userID, docIDs, indexes)
+		if err != nil {
+			return nil, err
+		}
+		for i, m := range located {
+			if s, ok := spans[i]; ok {
+				windows = append(windows, contextWindow{documentID: docIDs[i], start: s[0], end: s[1], hit: m})
+			} else {
+				passthrough = append(passthrough, m)
+			}
+		}
+	}
+
+	windows = mergeWindows(windows)
+	if err := h.loadWindowText(ctx, userID, windows); err != nil {
+		return nil, err
+	}
+
+	var out []rag.ContextChunk
+	for _, w := range windows {
+		if w.text == "" {
+			passthrough = append(passthrough, w.hit)
+			continue
+		}
+		out = append(out, contextChunk(w.hit, w.text))
+	}
+	for _, m := range passthrough {
+		if m.Text != nil && *m.Text != "" {
+			out = append(out, contextChunk(m, *m.Text))
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out, nil
+}
+
+// expansionSpans returns, for each (document, chunk index) pair of userID's
+// documents that can be expanded, the character span it expands to, keyed
+// by the pair's position.
+func (h *Handler) expansionSpans(ctx context.Context, userID string, docIDs []string, indexes []int64) (map[int][2]int, error) {
+	rows, err := h.db.QueryContext(
+		ctx,
+		`SELECT m.ord - 1,
+            coalesce(p.start_offset, n.start_offset),
+            coalesce(p.end_offset, n.end_offset)
+     FROM unnest($1::uuid[], $2::int[]) WITH ORDINALITY AS m(document_id, chunk_index, ord)
+     JOIN documents d ON d.id = m.document_id AND d.user_id = $4
+     JOIN document_chunks c
+       ON c.document_id = m.document_id AND c.chunk_index = m.chunk_index AND NOT c.is_parent
+     LEFT JOIN document_chunks p ON p.id = c.parent_chunk_id
+     LEFT JOIN LATERAL (
+       SELECT min(start_offset) AS start_offset, max(end_offset) AS end_offset
+       FROM document_chunks n
+       WHERE n.document_id = m.document_id AND NOT n.is_parent
+         AND n.chunk_index BETWEEN m.chunk_index - $3 AND m.chunk_index + $3
+     ) n ON true`,
+		pq.Array(docIDs),
+		pq.Array(indexes),
+		h.neighborWindow,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	spans := make(map[int][2]int)
+	for rows.Next() {
+		var (
+			i          int
+			start, end sql.NullInt64
+		)
+		if err := rows.Scan(&i, &start, &end); err != nil {
+			return nil, err
+		}
+		// Chunks stored before offsets were recorded cannot be expanded.
+		if start.Valid && end.Valid {
+			spans[i] = [2]int{int(start.Int64), int(end.Int64)}
+		}
+	}
+	return spans, rows.Err()
+}
+
+// mergeWindows merges windows that overlap or touch within a document,
+// keeping the best-scoring hit.
+func mergeWindows(windows []contextWindow) []contextWindow {
+	sort.Slice(windows, func(i, j int) bool {
+		if windows[i].documentID != windows[j].documentID {
+			return windows[i].documentID < windows[j].documentID
+		}
+		return windows[i].start < windows[j].start
+	})
+	var out []contextWindow
+	for _, w := range windows {
+		if n := len(out); n > 0 && out[n-1].documentID == w.documentID && w.start <= out[n-1].end {
+			last := &out[n-1]
+			last.end = max(last.end, w.end)
+			if w.hit.Score > last.hit.Score {
+				last.hit = w.hit
+			}
+			continue
+		}
+		out = append(out, w)
+	}
+	return out
+}
+
+// loadWindowText fills in the text of each window from the stored content
+// of userID's documents.
+func (h *Handler) loadWindowText(ctx context.Context, userID string, windows []contextWindow) error {
+	if len(windows) == 0 {
+		return nil
+	}
+	docIDs := make([]string, len(windows))
+	starts := make([]int64, len(windows))
+	ends := make([]int64, len(windows))
+	for i, w := range windows {
+		docIDs[i], starts[i], ends[i] = w.documentID, int64(w.start), int64(w.end)
+	}
+
+	rows, err := h.db.QueryContext(
+		ctx,
+		`SELECT w.ord - 1, substr(ct.content, w.start_offset + 1, w.end_offset - w.start_offset)
+     FROM unnest($1::uuid[], $2::int[], $3::int[]) WITH ORDINALITY AS w(document_id, start_offset, end_offset, ord)
+     JOIN documents d ON d.id = w.document_id AND d.user_id = $4
+     JOIN document_contents ct ON ct.document_id = w.document_id`,
+		pq.Array(docIDs),
+		pq.Array(starts),
+		pq.Array(ends),
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			i    int
+			text string
+		)
+		if err := rows.Scan(&i, &text); err != nil {
+			return err
+		}
+		windows[i].text = text
+	}
+	return rows.Err()
+}
+
+func contextChunk(m rag.RetrievedChunkOut, text string) rag.ContextChunk {
+	return rag.ContextChunk{
+		ChunkID:      m.ID,
+		Text:         text,
+		Score:        m.Score,
+		DocumentID:   m.DocumentID,
+		ChunkIndex:   m.ChunkIndex,
+		SegmentKind:  m.SegmentKind,
+		SegmentStart: m.SegmentStart,
+		SegmentEnd:   m.SegmentEnd,
+		SegmentTitle: m.SegmentTitle,
+		SectionPath:  m.SectionPath,
+	}
+}
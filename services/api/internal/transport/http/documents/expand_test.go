@@ -0,0 +1,29 @@
+package documents
+
+import (
+	"reflect"
+	"testing"
+
+	"docsense/api/internal/adapters/rag"
+)
+
+func TestMergeWindows(t *testing.T) {
+	hit := func(id string, score float64) rag.RetrievedChunkOut {
+		return rag.RetrievedChunkOut{ID: id, Score: score}
+	}
+	windows := []contextWindow{
+		{documentID: "b", start: 0, end: 10, hit: hit("b1", 0.5)},
+		{documentID: "a", start: 40, end: 60, hit: hit("a3", 0.2)},
+		{documentID: "a", start: 0, end: 20, hit: hit("a1", 0.4)},
+		{documentID: "a", start: 20, end: 30, hit: hit("a2", 0.9)},
+		{documentID: "b", start: 5, end: 8, hit: hit("b2", 0.1)},
+	}
+	want := []contextWindow{
+		{documentID: "a", start: 0, end: 30, hit: hit("a2", 0.9)},
+		{documentID: "a", start: 40, end: 60, hit: hit("a3", 0.2)},
+		{documentID: "b", start: 0, end: 10, hit: hit("b1", 0.5)},
+	}
+	if got := mergeWindows(windows); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeWindows:\n got %+v\nwant %+v", got, want)
+	}
+}
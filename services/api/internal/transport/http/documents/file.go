@@ -0,0 +1,74 @@
+package documents
+
+import (
+	"database/sql"
+	"errors"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"docsense/api/internal/transport/http/middleware"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/google/uuid"
+)
+
+// File serves the stored original of a document to its owner.
+//
+// Route: GET /api/documents/:id/file
+//
+// The file is served inline so citation links such as
+// /api/documents/:id/file#page=12 open the PDF at the cited page.
+func (h *Handler) File(c *gin.Context) {
+	userID, ok := middleware.GetAuthenticatedUserID(c)
+	if !ok {
+		middleware.AbortUnauthorized(c)
+		return
+	}
+
+	docID := c.Param("id")
+	if _, err := uuid.Parse(docID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+
+	var storagePath, filename, mimeType sql.NullString
+	err := h.db.QueryRowContext(
+		c.Request.Context(),
+		`SELECT storage_path, filename, mime_type FROM documents WHERE id = $1 AND user_id = $2`,
+		docID,
+		userID,
+	).Scan(&storagePath, &filename, &mimeType)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !storagePath.Valid) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query document"})
+		return
+	}
+
+	abs, ok := h.storageAbs(storagePath.String)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+
+	if mimeType.Valid && mimeType.String != "" {
+		c.Header("Content-Type", mimeType.String)
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": filename.String}))
+	c.File(abs)
+}
+
+// storageAbs resolves a stored document's storage path, reporting false for
+// paths that escape the storage directory.
+func (h *Handler) storageAbs(storagePath string) (string, bool) {
+	root := filepath.Clean(h.storageDir)
+	abs := filepath.Join(root, filepath.FromSlash(storagePath))
+	if !strings.HasPrefix(abs, root+string(filepath.Separator)) {
+		return "", false
+	}
+	return abs, true
+}
@@ -2,8 +2,18 @@ package documents
 
 import (
 	"database/sql"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
 
-	"docsense/api/internal/adapters/rag"
+	"docsense/api/internal/adapters/config"
+	"docsense/api/internal/ingest/chunk"
+	"docsense/api/internal/ingest/sandbox"
+	"docsense/api/internal/ingest/tokenize"
+	"docsense/api/internal/ports"
+
+	"github.com/gin-gonic/gin"
 )
 
 // Handler hosts HTTP handlers for document routes.
@@ -17,9 +27,32 @@ type Handler struct {
 	db             *sql.DB
 	storageDir     string
 	maxUploadBytes int64
-	ragClient      *rag.Client
+	ragClient      ports.RAG
+	extractor      *sandbox.Runner
+
+	// chunking is the default chunking, which uploads may override.
+	chunking chunk.Options
+	// tokenizer sizes chunks and counts their tokens.
+	tokenizer tokenize.Tokenizer
+	// neighborWindow is how many chunks on each side a query match without
+	// a parent chunk is expanded by.
+	neighborWindow int
+	// embedding configures how chunks are sent for embedding.
+	embedding config.EmbedConfig
+
+	// reconciling is set while ReconcileRoute runs.
+	reconciling atomic.Bool
+}
+
+func NewHandler(db *sql.DB, storageDir string, maxUploadBytes int64, ragClient ports.RAG, extractor *sandbox.Runner, chunking chunk.Options, tokenizer tokenize.Tokenizer, neighborWindow int, embedding config.EmbedConfig) *Handler {
+	return &Handler{db: db, storageDir: storageDir, maxUploadBytes: maxUploadBytes, ragClient: ragClient, extractor: extractor, chunking: chunking, tokenizer: tokenizer, neighborWindow: neighborWindow, embedding: embedding}
 }
 
-func NewHandler(db *sql.DB, storageDir string, maxUploadBytes int64, ragClient *rag.Client) *Handler {
-	return &Handler{db: db, storageDir: storageDir, maxUploadBytes: maxUploadBytes, ragClient: ragClient}
+// clearWriteDeadline lifts the server's write timeout for a handler whose
+// work (ingesting a document, generating an answer) may outlast it; the
+// work is bounded by its own timeouts instead.
+func clearWriteDeadline(c *gin.Context) {
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("warning: %s: clear write deadline: %v", c.Request.URL.Path, err)
+	}
 }
@@ -0,0 +1,35 @@
+package documents
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClearWriteDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", func(c *gin.Context) {
+		clearWriteDeadline(c)
+		time.Sleep(150 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+	srv := httptest.NewUnstartedServer(r)
+	srv.Config.WriteTimeout = 50 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != "done" {
+		t.Fatalf("body = %q, %v; want done", body, err)
+	}
+}
@@ -0,0 +1,651 @@
+package documents
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"docsense/api/internal/adapters/rag"
+	"docsense/api/internal/ingest/chunk"
+	"docsense/api/internal/ingest/extract"
+	"docsense/api/internal/ingest/normalize"
+	"docsense/api/internal/ingest/sandbox"
+	"docsense/api/internal/ingest/tokenize"
+	"docsense/api/internal/metrics"
+	"docsense/api/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxAttachmentDepth bounds recursion through attachments (a message
+// attached to a message attached to ...).
+const maxAttachmentDepth = 3
+
+// storedDocument is a document whose file is in storage and whose metadata
+// row exists, ready for ingestion.
+type storedDocument struct {
+	ID         string
+	UserID     string
+	StorageAbs string
+	MIMEType   string
+
+	// Password decrypts an encrypted PDF. It is never persisted.
+	Password string
+
+	// Chunking is how the document is chunked; attachments inherit it.
+	Chunking chunk.Options
+
+	// Depth is the attachment nesting level; 0 for direct uploads.
+	Depth int
+
+	// Reingest is set when the document was ingested before (a reindex or
+	// a new version). Its file is kept if ingestion fails, and its previous
+	// attachments are replaced (see ingestAttachments).
+	Reingest bool
+}
+
+// discard removes the stored file of a document that failed ingestion,
+// unless it was ingested before.
+func (doc storedDocument) discard() {
+	if !doc.Reingest {
+		_ = os.Remove(doc.StorageAbs)
+	}
+}
+
+// childDocument describes an attachment ingested as its own document.
+type childDocument struct {
+	DocumentID string             `json:"document_id"`
+	Filename   string             `json:"filename"`
+	Status     string             `json:"status"`
+	Embedding  *embeddingProgress `json:"embedding,omitempty"`
+}
+
+// Document statuses set during ingestion besides "uploaded".
+const (
+	statusReady  = "ready"
+	statusFailed = "failed"
+	// statusPartial marks ingested documents with chunks left unembedded
+	// after embedding failed; POST /api/documents/:id/embed resumes them.
+	statusPartial = "partial"
+	// statusNeedsOCR marks documents whose pages have no text layer; they
+	// are kept so they can be processed once OCR is available.
+	statusNeedsOCR = "needs_ocr"
+)
+
+// ingestError is a failed ingestion step with the message shown to clients.
+// Failures the client can act on also carry a machine-readable code.
+type ingestError struct {
+	message string
+	code    string
+	err     error
+}
+
+func (e *ingestError) Error() string { return e.message + ": " + e.err.Error() }
+func (e *ingestError) Unwrap() error { return e.err }
+
+// Error codes returned to clients for documents that cannot be ingested.
+const (
+	codePDFEncrypted = "pdf_encrypted"
+	codePDFCorrupt   = "pdf_corrupt"
+	codeNoTextLayer  = "no_text_layer"
+
+	codeExtractTimeout   = "extract_timeout"
+	codeExtractTooLarge  = "extract_output_too_large"
+	codeExtractorCrashed = "extract_crashed"
+)
+
+// extractError classifies an extraction failure.
+func extractError(err error) *ingestError {
+	switch {
+	case errors.Is(err, extract.ErrEncrypted):
+		return &ingestError{"document is password-protected; provide the correct password", codePDFEncrypted, err}
+	case errors.Is(err, extract.ErrCorrupt):
+		return &ingestError{"document is corrupt or not a valid PDF", codePDFCorrupt, err}
+	case errors.Is(err, sandbox.ErrTimeout):
+		return &ingestError{"document took too long to process", codeExtractTimeout, err}
+	case errors.Is(err, sandbox.ErrOutputTooLarge):
+		return &ingestError{"document contains too much text to process", codeExtractTooLarge, err}
+	case errors.Is(err, sandbox.ErrCrashed):
+		return &ingestError{"document could not be processed safely", codeExtractorCrashed, err}
+	case errors.Is(err, sandbox.ErrUnavailable):
+		return &ingestError{"document processing is temporarily unavailable", "", err}
+	default:
+		return &ingestError{"failed to extract document text", "", err}
+	}
+}
+
+// ingestErrorResponse returns the HTTP status and JSON body for a failed
+// ingestion. Problems with the document itself are 422s; anything else is
+// an internal error.
+func ingestErrorResponse(err error) (int, gin.H) {
+	var ie *ingestError
+	if !errors.As(err, &ie) {
+		return http.StatusInternalServerError, gin.H{"error": "failed to ingest document"}
+	}
+	if ie.code == "" {
+		return http.StatusInternalServerError, gin.H{"error": ie.message}
+	}
+	return http.StatusUnprocessableEntity, gin.H{"error": ie.message, "code": ie.code}
+}
+
+// ingestDocument extracts, chunks and indexes a stored document, then marks
+// it ready. Attachments found during extraction are ingested as child
+// documents and returned.
+//
+// Each stage (extract, normalize, chunk, store, index) is a span within an
+// "ingest" span, and is timed in metrics.IngestStageDuration.
+func (h *Handler) ingestDocument(ctx context.Context, doc storedDocument) (_ []childDocument, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ingest", trace.WithAttributes(
+		attribute.String("document.id", doc.ID),
+		attribute.String("document.mime_type", doc.MIMEType),
+		attribute.Int("ingest.depth", doc.Depth),
+		attribute.Bool("ingest.reingest", doc.Reingest),
+	))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	// Synchronously extract text and persist to document_contents. The
+	// runner traces extraction itself.
+	start := time.Now()
+	extracted, err := h.extractor.Extract(ctx, doc.StorageAbs, doc.MIMEType, extract.Options{Password: doc.Password})
+	metrics.IngestStageDuration.WithLabelValues("extract").Observe(metrics.Since(start))
+	if err != nil {
+		doc.discard()
+		return nil, extractError(err)
+	}
+	_, stage := startStage(ctx, "normalize")
+	normalizeExtracted(extracted)
+	stage.span.SetAttributes(attribute.Int("document.text_bytes", len(extracted.Text)))
+	stage.end(nil)
+
+	if err := h.applyExtractedMetadata(ctx, doc.ID, extracted); err != nil {
+		doc.discard()
+		return nil, &ingestError{"failed to persist document metadata", "", err}
+	}
+
+	// A scan without text would otherwise become a "ready" document with no
+	// chunks. Keep the file and flag it instead.
+	if extracted.NoTextLayer {
+		if err := h.updateDocumentStatus(ctx, doc.ID, statusNeedsOCR); err != nil {
+			return nil, &ingestError{"failed to update document status", "", err}
+		}
+		return nil, &ingestError{"document has no extractable text (it may be a scan that needs OCR)", codeNoTextLayer, extract.ErrNoTextLayer}
+	}
+
+	// Deterministically chunk the extracted text.
+	// Convert document id string to uuid.UUID
+	docUUID, err := uuid.Parse(doc.ID)
+	if err != nil {
+		return nil, &ingestError{"invalid document id", "", err}
+	}
+	_, stage = startStage(ctx, "chunk",
+		attribute.String("chunk.strategy", doc.Chunking.Strategy),
+		attribute.Int("chunk.size", doc.Chunking.Size),
+		attribute.Int("document.text_bytes", len(extracted.Text)),
+	)
+	chunked, err := chunkExtracted(docUUID, extracted, doc.Chunking, h.tokenizer)
+	stage.span.SetAttributes(attribute.Int("chunk.count", len(chunked.Chunks)), attribute.Int("chunk.parents", len(chunked.Parents)))
+	stage.end(err)
+	if err != nil {
+		return nil, &ingestError{"failed to chunk document text", "", err}
+	}
+
+	// Persist content and chunks and mark the document ready together.
+	storeCtx, stage := startStage(ctx, "store",
+		attribute.Int("chunk.count", len(chunked.Chunks)),
+		attribute.Int("document.text_bytes", len(extracted.Text)),
+	)
+	stored, err := h.storeIngested(storeCtx, doc, extracted.Text, chunked)
+	if err == nil {
+		stage.span.SetAttributes(attribute.Int("chunk.reused", countTrue(stored.Reused)), attribute.Int("chunk.removed", len(stored.Removed)))
+	}
+	stage.end(err)
+	if err != nil {
+		doc.discard()
+		return nil, &ingestError{"failed to persist document content", "", err}
+	}
+	indexCtx, stage := startStage(ctx, "index", attribute.Int("chunk.count", len(chunked.Chunks)))
+	h.indexChunks(indexCtx, doc.ID, chunked, stored)
+	stage.end(nil)
+
+	attachments := extracted.Attachments
+	if len(attachments) > 0 && doc.Depth >= maxAttachmentDepth {
+		log.Printf("warning: document %s: skipping %d attachments nested deeper than %d levels",
+			doc.ID, len(attachments), maxAttachmentDepth)
+		attachments = nil
+	}
+	// A document ingested before may have children to replace even when it
+	// has no attachments now.
+	if len(attachments) == 0 && !doc.Reingest {
+		return nil, nil
+	}
+	return h.ingestAttachments(ctx, doc, attachments), nil
+}
+
+// indexChunks sends a document's chunks to the RAG service: new chunks are
+// embedded, reused chunks (see storeIngested) only get their payload
+// updated, and the vectors of removed chunks are deleted. Parent chunks are
+// not embedded; queries expand to them.
+//
+// Failures are logged but do not fail the upload. A document with chunks
+// left unembedded is marked partial (see Embed); `api reconcile` repairs
+// chunks and vectors left out of step.
+func (h *Handler) indexChunks(ctx context.Context, documentID string, chunked chunkedDocument, stored storedChunks) {
+	var embed, reuse []rag.ChunkIn
+	for i, ch := range chunked.Chunks {
+		in := rag.ChunkIn{
+			ChunkID:      stored.IDs[i],
+			ChunkIndex:   ch.Index,
+			Text:         ch.Content,
+			SegmentKind:  chunked.SegmentKind,
+			SegmentStart: ch.SegmentStart,
+			SegmentEnd:   ch.SegmentEnd,
+			SegmentTitle: ch.SegmentTitle,
+			SectionPath:  ch.SectionPath,
+		}
+		if stored.Reused[i] {
+			reuse = append(reuse, in)
+		} else {
+			embed = append(embed, in)
+		}
+	}
+
+	if len(embed) > 0 {
+		if _, err := h.embedChunks(ctx, documentID, embed); err != nil {
+			log.Printf("warning: document %s: failed to embed chunks: %v", documentID, err)
+		}
+	}
+	if len(reuse) > 0 {
+		if _, err := h.ragClient.UpdatePayloads(ctx, documentID, reuse); err != nil {
+			log.Printf("warning: document %s: failed to update reused chunks: %v", documentID, err)
+		}
+	}
+	for i := 0; i < len(stored.Removed); i += reconcileBatchSize {
+		if _, err := h.ragClient.DeletePoints(ctx, stored.Removed[i:min(i+reconcileBatchSize, len(stored.Removed))]); err != nil {
+			log.Printf("warning: document %s: failed to delete vectors of removed chunks: %v", documentID, err)
+			break
+		}
+	}
+	if len(reuse) > 0 || len(stored.Removed) > 0 {
+		log.Printf("document %s: embedded %d chunks, reused %d, removed %d", documentID, len(embed), len(reuse), len(stored.Removed))
+	}
+
+	if status, progress, err := h.updateEmbeddingStatus(ctx, documentID); err != nil {
+		log.Printf("warning: document %s: failed to update embedding status: %v", documentID, err)
+	} else if status == statusPartial {
+		log.Printf("warning: document %s: %d of %d chunks embedded", documentID, progress.Embedded, progress.Expected)
+	}
+}
+
+// ingestStage is a stage of ingestion, traced as a span and timed in
+// metrics.IngestStageDuration.
+type ingestStage struct {
+	name  string
+	start time.Time
+	span  trace.Span
+}
+
+func startStage(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, *ingestStage) {
+	ctx, span := tracing.Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+	return ctx, &ingestStage{name: name, start: time.Now(), span: span}
+}
+
+// end ends the stage, which failed if err is not nil.
+func (s *ingestStage) end(err error) {
+	metrics.IngestStageDuration.WithLabelValues(s.name).Observe(metrics.Since(s.start))
+	tracing.End(s.span, err)
+}
+
+// countTrue returns how many of bs are true.
+func countTrue(bs []bool) int {
+	n := 0
+	for _, b := range bs {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+// ingestAttachments stores each attachment as a child document of parent and
+// ingests it. A failing attachment is marked "failed" and does not fail the
+// parent.
+//
+// When the parent was ingested before, its previous children are replaced:
+// an attachment with the same filename and content as one of them is
+// ingested again in place, keeping its document ID and unchanged vectors,
+// and children the parent no longer has are deleted.
+func (h *Handler) ingestAttachments(ctx context.Context, parent storedDocument, attachments []extract.Attachment) []childDocument {
+	var previous previousChildren
+	if parent.Reingest {
+		var err error
+		if previous, err = h.loadPreviousChildren(ctx, parent.ID); err != nil {
+			// Without them the old children could be neither reused nor
+			// deleted; leave them and add the attachments as new ones.
+			log.Printf("warning: document %s: failed to load previous attachments: %v", parent.ID, err)
+		}
+	}
+
+	children := make([]childDocument, 0, len(attachments))
+	for _, att := range attachments {
+		child, doc, err := h.storeAttachment(ctx, parent, att, previous)
+		if err != nil {
+			log.Printf("warning: document %s: failed to store attachment %q: %v", parent.ID, att.Filename, err)
+			continue
+		}
+
+		grandchildren, err := h.ingestDocument(ctx, doc)
+		if err != nil {
+			log.Printf("warning: document %s: failed to ingest attachment %q: %v", parent.ID, att.Filename, err)
+			child.Status = h.markIngestFailed(ctx, child.DocumentID, err)
+		} else if status, progress, err := h.documentStatus(ctx, child.DocumentID); err != nil {
+			log.Printf("warning: document %s: failed to query status of attachment %q: %v", parent.ID, att.Filename, err)
+		} else {
+			child.Status, child.Embedding = status, &progress
+		}
+		children = append(children, child)
+		children = append(children, grandchildren...)
+	}
+
+	if stale := previous.remaining(); len(stale) > 0 {
+		if err := h.deleteDocuments(ctx, stale); err != nil {
+			log.Printf("warning: document %s: failed to delete %d previous attachments: %v", parent.ID, len(stale), err)
+		}
+	}
+	return children
+}
+
+// previousChild is a child document left by an earlier ingestion of its
+// parent.
+type previousChild struct {
+	id, storagePath string
+}
+
+// previousChildren are a document's previous children by filename and
+// checksum.
+type previousChildren map[string][]previousChild
+
+func childKey(filename, checksum string) string { return filename + "\x00" + checksum }
+
+// take returns an unused previous child with the given filename and
+// checksum.
+func (p previousChildren) take(filename, checksum string) (previousChild, bool) {
+	key := childKey(filename, checksum)
+	children := p[key]
+	if len(children) == 0 {
+		return previousChild{}, false
+	}
+	p[key] = children[1:]
+	return children[0], true
+}
+
+// remaining returns the IDs of the children not taken.
+func (p previousChildren) remaining() []string {
+	var ids []string
+	for _, children := range p {
+		for _, c := range children {
+			ids = append(ids, c.id)
+		}
+	}
+	return ids
+}
+
+func (h *Handler) loadPreviousChildren(ctx context.Context, parentID string) (previousChildren, error) {
+	rows, err := h.db.QueryContext(
+		ctx,
+		`SELECT id::text, coalesce(filename, ''), coalesce(checksum_sha256, ''), coalesce(storage_path, '')
+		 FROM documents WHERE parent_document_id = $1
+		 ORDER BY created_at, id`,
+		parentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	previous := make(previousChildren)
+	for rows.Next() {
+		var (
+			c                  previousChild
+			filename, checksum string
+		)
+		if err := rows.Scan(&c.id, &filename, &checksum, &c.storagePath); err != nil {
+			return nil, err
+		}
+		key := childKey(filename, checksum)
+		previous[key] = append(previous[key], c)
+	}
+	return previous, rows.Err()
+}
+
+// deleteDocuments deletes documents and their descendants (attachments of
+// attachments): their rows, which takes their chunks along, then their
+// vectors and stored files. Vectors left behind by a failed delete are
+// orphans that `api reconcile` removes.
+func (h *Handler) deleteDocuments(ctx context.Context, documentIDs []string) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`WITH RECURSIVE tree AS (
+		   SELECT id, storage_path FROM documents WHERE id = ANY($1::uuid[])
+		   UNION ALL
+		   SELECT d.id, d.storage_path FROM documents d JOIN tree t ON d.parent_document_id = t.id
+		 )
+		 SELECT coalesce(storage_path, ''), ARRAY(
+		   SELECT c.id::text FROM document_chunks c WHERE c.document_id = tree.id AND NOT c.is_parent
+		 )
+		 FROM tree`,
+		pq.Array(documentIDs),
+	)
+	if err != nil {
+		return err
+	}
+	var storagePaths, pointIDs []string
+	for rows.Next() {
+		var (
+			storagePath string
+			chunkIDs    []string
+		)
+		if err := rows.Scan(&storagePath, pq.Array(&chunkIDs)); err != nil {
+			rows.Close()
+			return err
+		}
+		if storagePath != "" {
+			storagePaths = append(storagePaths, storagePath)
+		}
+		pointIDs = append(pointIDs, chunkIDs...)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM documents WHERE id = ANY($1::uuid[])`, pq.Array(documentIDs)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, p := range storagePaths {
+		if abs, ok := h.storageAbs(p); ok {
+			_ = os.Remove(abs)
+		}
+	}
+	for i := 0; i < len(pointIDs); i += reconcileBatchSize {
+		if _, err := h.ragClient.DeletePoints(ctx, pointIDs[i:min(i+reconcileBatchSize, len(pointIDs))]); err != nil {
+			return fmt.Errorf("delete vectors: %w", err)
+		}
+	}
+	return nil
+}
+
+// markIngestFailed records a failed ingestion on the document and returns
+// its resulting status. Documents flagged for OCR keep that status.
+func (h *Handler) markIngestFailed(ctx context.Context, documentID string, err error) string {
+	if errors.Is(err, extract.ErrNoTextLayer) {
+		return statusNeedsOCR
+	}
+	if err := h.updateDocumentStatus(ctx, documentID, statusFailed); err != nil {
+		log.Printf("warning: document %s: failed to mark document failed: %v", documentID, err)
+	}
+	return statusFailed
+}
+
+// storeAttachment writes an attachment to storage and creates its document
+// row, linked to the parent, and returns it ready for ingestion. An
+// attachment matching one of the parent's previous children takes over that
+// document and its storage path instead.
+func (h *Handler) storeAttachment(ctx context.Context, parent storedDocument, att extract.Attachment, previous previousChildren) (childDocument, storedDocument, error) {
+	filename := sanitizeFilename(att.Filename)
+	sum := sha256.Sum256(att.Data)
+	checksum := hex.EncodeToString(sum[:])
+
+	doc := storedDocument{
+		UserID:   parent.UserID,
+		MIMEType: att.MIMEType,
+		Chunking: parent.Chunking,
+		Depth:    parent.Depth + 1,
+	}
+	var storageRel string
+	if prev, ok := previous.take(filename, checksum); ok {
+		doc.ID, storageRel, doc.Reingest = prev.id, prev.storagePath, true
+	} else {
+		docID, err := h.newDocumentID(ctx)
+		if err != nil {
+			return childDocument{}, storedDocument{}, err
+		}
+		doc.ID = docID
+		storageRel = filepath.ToSlash(filepath.Join(parent.UserID, fmt.Sprintf("%s_%s", docID, filename)))
+	}
+	abs, ok := h.storageAbs(storageRel)
+	if !ok {
+		return childDocument{}, storedDocument{}, fmt.Errorf("invalid storage path %q", storageRel)
+	}
+	doc.StorageAbs = abs
+	if err := writeFileAtomic(bytes.NewReader(att.Data), doc.StorageAbs); err != nil {
+		return childDocument{}, storedDocument{}, err
+	}
+
+	// Attachments of a mailbox record which message carried them.
+	var source map[string]any
+	if att.Message > 0 {
+		source = map[string]any{"source_message": att.Message}
+		if att.MessageID != "" {
+			source["source_message_id"] = att.MessageID
+		}
+	}
+	if err := h.insertDocumentMetadata(ctx, doc.ID, parent.UserID, filename, storageRel,
+		int64(len(att.Data)), att.MIMEType, checksum, parent.ID, source); err != nil {
+		doc.discard()
+		return childDocument{}, storedDocument{}, err
+	}
+	return childDocument{DocumentID: doc.ID, Filename: filename, Status: statusReady}, doc, nil
+}
+
+// normalizeExtracted cleans extracted text before chunking. Every format gets
+// Unicode cleanup; hard-wrapped formats (PDF pages and plain text) are also
+// reflowed, and running page headers and footers are stripped.
+func normalizeExtracted(res *extract.Result) {
+	res.Title = strings.TrimSpace(normalize.Clean(res.Title))
+	if len(res.Segments) == 0 {
+		res.Text = normalize.Reflow(normalize.Clean(res.Text))
+		return
+	}
+
+	paged := res.Segments[0].Kind == extract.SegmentKindPage
+	texts := make([]string, len(res.Segments))
+	for i, s := range res.Segments {
+		texts[i] = normalize.Clean(s.Text)
+	}
+	if paged {
+		texts = normalize.StripRepeatedLines(texts)
+	}
+	for i := range res.Segments {
+		if paged {
+			texts[i] = normalize.Reflow(texts[i])
+		}
+		res.Segments[i].Text = texts[i]
+		res.Segments[i].Title = normalize.Clean(res.Segments[i].Title)
+	}
+	res.Text = extract.JoinSegments(res.Segments)
+}
+
+// chunkedDocument is a document's chunks as stored.
+type chunkedDocument struct {
+	// Chunks are the chunks to embed. With hierarchical chunking they are
+	// the children of Parents, in order.
+	Chunks  []chunk.Chunk
+	Parents []chunk.Parent
+	// SegmentKind is the kind shared by all segments ("" when there are
+	// none).
+	SegmentKind string
+}
+
+// chunkExtracted chunks extracted text with the given options and tokenizer, keeping
+// segment spans when the extractor found structure and section paths when
+// the text has headings.
+func chunkExtracted(documentID uuid.UUID, res *extract.Result, opts chunk.Options, tok tokenize.Tokenizer) (chunkedDocument, error) {
+	var out chunkedDocument
+	chunker, err := chunk.New(opts, tok)
+	if err != nil {
+		return out, err
+	}
+	parentChunker, err := chunk.NewParent(opts, tok)
+	if err != nil {
+		return out, err
+	}
+
+	// res.Text is the segments joined (see normalizeExtracted), so segments
+	// map onto it and chunk offsets index the stored content.
+	segments := []chunk.Segment{{Start: 0, End: len(res.Text)}}
+	if len(res.Segments) > 0 {
+		out.SegmentKind = res.Segments[0].Kind
+		segments = make([]chunk.Segment, len(res.Segments))
+		for i, off := range extract.SegmentOffsets(res.Segments) {
+			s := res.Segments[i]
+			segments[i] = chunk.Segment{Number: s.Number, Title: s.Title, Start: off, End: off + len(s.Text)}
+		}
+	}
+
+	if res.Markdown {
+		segments = chunk.SplitSections(res.Text, segments)
+	}
+	top := chunker
+	if parentChunker != nil {
+		top = parentChunker
+	}
+	var chunks []chunk.Chunk
+	if res.Markdown {
+		chunks, err = chunk.ChunkSections(top, documentID, res.Text, segments)
+	} else {
+		chunks, err = top.Chunk(documentID, res.Text, segments)
+	}
+	if err != nil || parentChunker == nil {
+		out.Chunks = chunks
+		return out, err
+	}
+
+	out.Parents, err = chunk.ChunkHierarchy(chunks, chunker, documentID, res.Text, segments)
+	for _, p := range out.Parents {
+		out.Chunks = append(out.Chunks, p.Children...)
+	}
+	return out, err
+}
@@ -0,0 +1,72 @@
+package documents
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"docsense/api/internal/ingest/extract"
+)
+
+func TestIngestErrorResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"encrypted", extractError(fmt.Errorf("pdf open: %w", extract.ErrEncrypted)), http.StatusUnprocessableEntity, codePDFEncrypted},
+		{"corrupt", extractError(fmt.Errorf("pdf: %w", extract.ErrCorrupt)), http.StatusUnprocessableEntity, codePDFCorrupt},
+		{"wrapped no text layer", fmt.Errorf("ingest: %w", &ingestError{"no text", codeNoTextLayer, extract.ErrNoTextLayer}), http.StatusUnprocessableEntity, codeNoTextLayer},
+		{"other extraction failure", extractError(errors.New("boom")), http.StatusInternalServerError, ""},
+		{"not an ingest error", errors.New("db down"), http.StatusInternalServerError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := ingestErrorResponse(tt.err)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			code, _ := body["code"].(string)
+			if code != tt.wantCode {
+				t.Errorf("code = %q, want %q", code, tt.wantCode)
+			}
+			if body["error"] == "" {
+				t.Error("missing error message")
+			}
+		})
+	}
+}
+
+func TestPreviousChildrenTake(t *testing.T) {
+	previous := previousChildren{
+		childKey("a.pdf", "sum1"): {{id: "first"}, {id: "second"}},
+		childKey("b.txt", "sum2"): {{id: "other"}},
+	}
+	for _, want := range []string{"first", "second"} {
+		got, ok := previous.take("a.pdf", "sum1")
+		if !ok || got.id != want {
+			t.Fatalf("take = %q, %v; want %q", got.id, ok, want)
+		}
+	}
+	if got, ok := previous.take("a.pdf", "sum1"); ok {
+		t.Errorf("take reused %q twice", got.id)
+	}
+	// A changed attachment, or the same content renamed, is a new child.
+	if _, ok := previous.take("b.txt", "changed"); ok {
+		t.Error("take matched an attachment whose content changed")
+	}
+	if _, ok := previous.take("c.txt", "sum2"); ok {
+		t.Error("take matched an attachment under another name")
+	}
+
+	if got := previous.remaining(); !reflect.DeepEqual(got, []string{"other"}) {
+		t.Errorf("remaining = %v, want [other]", got)
+	}
+	var none previousChildren
+	if _, ok := none.take("a.pdf", "sum1"); ok || len(none.remaining()) != 0 {
+		t.Error("a document ingested for the first time has no previous children")
+	}
+}
@@ -21,10 +21,16 @@ func (h *Handler) List(c *gin.Context) {
 
 	rows, err := h.db.QueryContext(
 		c.Request.Context(),
-		`SELECT id, title, filename, mime_type, size_bytes, created_at, status
-     FROM documents
-     WHERE user_id = $1
-     ORDER BY created_at DESC`,
+		`SELECT d.id, d.title, d.filename, d.mime_type, d.size_bytes, d.created_at, d.status, d.parent_document_id::text,
+            p.expected, p.embedded
+     FROM documents d
+     CROSS JOIN LATERAL (
+       SELECT count(*) AS expected, count(embedded_at) AS embedded
+       FROM document_chunks
+       WHERE document_id = d.id AND NOT is_parent
+     ) p
+     WHERE d.user_id = $1
+     ORDER BY d.created_at DESC`,
 		userID,
 	)
 	if err != nil {
@@ -41,15 +47,19 @@ func (h *Handler) List(c *gin.Context) {
 		SizeBytes *int64    `json:"size_bytes"`
 		CreatedAt time.Time `json:"created_at"`
 		Status    *string   `json:"status"`
+		// ParentDocumentID is set for attachments extracted from another document.
+		ParentDocumentID *string `json:"parent_document_id"`
+		// Embedding is how many of the document's chunks are embedded.
+		Embedding embeddingProgress `json:"embedding"`
 	}
 
 	var out []docResp
 
 	for rows.Next() {
 		var d docResp
-		var title, filename, mimeType, status sql.NullString
+		var title, filename, mimeType, status, parentID sql.NullString
 		var size sql.NullInt64
-		if err := rows.Scan(&d.ID, &title, &filename, &mimeType, &size, &d.CreatedAt, &status); err != nil {
+		if err := rows.Scan(&d.ID, &title, &filename, &mimeType, &size, &d.CreatedAt, &status, &parentID, &d.Embedding.Expected, &d.Embedding.Embedded); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to scan row"})
 			return
 		}
@@ -68,6 +78,9 @@ func (h *Handler) List(c *gin.Context) {
 		if status.Valid {
 			d.Status = &status.String
 		}
+		if parentID.Valid {
+			d.ParentDocumentID = &parentID.String
+		}
 		out = append(out, d)
 	}
 	if err := rows.Err(); err != nil {
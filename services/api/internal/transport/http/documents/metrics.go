@@ -0,0 +1,51 @@
+package documents
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var documentsDesc = prometheus.NewDesc("docsense_documents", "Documents by status.", []string{"status"}, nil)
+
+// RegisterMetrics registers the number of documents by status, counted at
+// scrape time.
+func (h *Handler) RegisterMetrics(r prometheus.Registerer) error {
+	return r.Register(documentsCollector{h})
+}
+
+// documentsCollector counts documents by status on each scrape. If the
+// query fails the metric is left out of that scrape.
+type documentsCollector struct{ h *Handler }
+
+func (c documentsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- documentsDesc
+}
+
+func (c documentsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := c.h.db.QueryContext(ctx, `SELECT status, count(*) FROM documents GROUP BY status ORDER BY status`)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(documentsDesc, err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			status string
+			n      int64
+		)
+		if err := rows.Scan(&status, &n); err != nil {
+			ch <- prometheus.NewInvalidMetric(documentsDesc, err)
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(documentsDesc, prometheus.GaugeValue, float64(n), status)
+	}
+	if err := rows.Err(); err != nil {
+		ch <- prometheus.NewInvalidMetric(documentsDesc, err)
+	}
+}
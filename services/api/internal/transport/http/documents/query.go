@@ -1,10 +1,17 @@
 package documents
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 
 	"docsense/api/internal/adapters/rag"
 	"docsense/api/internal/app"
+	"docsense/api/internal/metrics"
+	"docsense/api/internal/ports"
 	"docsense/api/internal/transport/http/middleware"
 
 	"github.com/gin-gonic/gin"
@@ -14,29 +21,54 @@ import (
 type QueryRequest struct {
 	Query string `json:"query" binding:"required,min=1"`
 	TopK  int    `json:"top_k,omitempty"`
+	// DocumentIDs restricts retrieval to these documents.
+	DocumentIDs []string `json:"document_ids,omitempty" binding:"omitempty,max=100,dive,uuid"`
 }
 
 // Query handles document queries via RAG.
 //
 // Route: POST /api/documents/query
 func (h *Handler) Query(c *gin.Context) {
-	userID, ok := middleware.GetAuthenticatedUserID(c)
+	metrics.QueriesInFlight.Inc()
+	defer metrics.QueriesInFlight.Dec()
+
+	// Retrieval and generation, with retries, may outlast the server's
+	// write timeout; a dropped answer would reach the client as a reset.
+	clearWriteDeadline(c)
+
+	req, contexts, retrieved, ok := h.prepareQuery(c)
 	if !ok {
-		middleware.AbortUnauthorized(c)
 		return
 	}
+	resp, err := h.ragClient.Generate(c.Request.Context(), req.Query, contexts)
+	if err != nil {
+		c.JSON(ragErrorStatus(err), gin.H{"error": "query failed: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, queryResult(resp, retrieved))
+}
 
+// prepareQuery authenticates and validates a query request, retrieves the
+// matching chunks, and widens them to the passages to answer from. On
+// failure it has written the error response and returns false.
+func (h *Handler) prepareQuery(c *gin.Context) (QueryRequest, []rag.ContextChunk, *rag.RetrieveResponse, bool) {
 	var req QueryRequest
+	userID, ok := middleware.GetAuthenticatedUserID(c)
+	if !ok {
+		middleware.AbortUnauthorized(c)
+		return req, nil, nil, false
+	}
+
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
-		return
+		return req, nil, nil, false
 	}
 
 	// Sanitize and validate query input
 	sanitizedQuery, isValid := app.SanitizeQuery(req.Query)
 	if !isValid {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query: contains suspicious content or invalid characters"})
-		return
+		return req, nil, nil, false
 	}
 	req.Query = sanitizedQuery
 
@@ -47,13 +79,31 @@ func (h *Handler) Query(c *gin.Context) {
 		req.TopK = 50 // Max
 	}
 
-	// Call RAG service
-	resp, err := h.ragClient.Query(c.Request.Context(), req.Query, req.TopK)
+	// Retrieve, keep the matches in the user's documents, widen them to
+	// their surrounding text, then answer from the widened passages.
+	ctx := c.Request.Context()
+	retrieved, err := h.retrieve(ctx, req.Query, req.TopK, rag.Filter{UserID: userID, DocumentIDs: req.DocumentIDs})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed: " + err.Error()})
-		return
+		c.JSON(ragErrorStatus(err), gin.H{"error": "query failed: " + err.Error()})
+		return req, nil, nil, false
 	}
+	owned, err := h.ownedMatches(ctx, userID, retrieved.Matches)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed: check matches: " + err.Error()})
+		return req, nil, nil, false
+	}
+	retrieved.Matches = owned[:min(len(owned), req.TopK)]
+	contexts, err := h.expandMatches(ctx, userID, retrieved.Matches)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed: expand matches: " + err.Error()})
+		return req, nil, nil, false
+	}
+	return req, contexts, retrieved, true
+}
 
+// queryResult renders an answer with its citations and the retrieved
+// matches.
+func queryResult(resp *rag.GenerateResponse, retrieved *rag.RetrieveResponse) gin.H {
 	// Convert citations to include document metadata if available
 	citations := make([]map[string]interface{}, len(resp.Citations))
 	for i, cit := range resp.Citations {
@@ -67,11 +117,40 @@ func (h *Handler) Query(c *gin.Context) {
 			// Note: Document metadata could be fetched here if needed
 			// For now, we return the document_id for the frontend to resolve
 		}
+		if cit.DocumentID != nil && cit.ChunkID != "" {
+			// The cited chunk in its surrounding text, for highlighting.
+			citMap["context_url"] = fmt.Sprintf("/api/documents/%s/chunks/%s/context", *cit.DocumentID, cit.ChunkID)
+		}
+		if cit.SegmentKind != nil && cit.SegmentStart != nil {
+			end := *cit.SegmentStart
+			if cit.SegmentEnd != nil {
+				end = *cit.SegmentEnd
+			}
+			citMap["segment_kind"] = *cit.SegmentKind
+			citMap["segment_start"] = *cit.SegmentStart
+			citMap["segment_end"] = end
+			title := ""
+			if cit.SegmentTitle != nil {
+				title = *cit.SegmentTitle
+				citMap["segment_title"] = title
+			}
+			citMap["location"] = segmentLabel(*cit.SegmentKind, *cit.SegmentStart, end, title)
+		}
+		if len(cit.SectionPath) > 0 {
+			citMap["section_path"] = cit.SectionPath
+			citMap["section"] = strings.Join(cit.SectionPath, " > ")
+		}
+		if start, end, ok := cit.PageSpan(); ok && cit.DocumentID != nil {
+			citMap["page_start"] = start
+			citMap["page_end"] = end
+			// Browsers' PDF viewers honour the #page fragment.
+			citMap["url"] = fmt.Sprintf("/api/documents/%s/file#page=%d", *cit.DocumentID, start)
+		}
 		citations[i] = citMap
 	}
 
-	matches := make([]map[string]interface{}, len(resp.Matches))
-	for i, m := range resp.Matches {
+	matches := make([]map[string]interface{}, len(retrieved.Matches))
+	for i, m := range retrieved.Matches {
 		matchMap := map[string]interface{}{
 			"id":    m.ID,
 			"score": m.Score,
@@ -82,12 +161,74 @@ func (h *Handler) Query(c *gin.Context) {
 		if m.Text != nil {
 			matchMap["text"] = *m.Text
 		}
+		if m.ChunkIndex != nil {
+			matchMap["chunk_index"] = *m.ChunkIndex
+		}
 		matches[i] = matchMap
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	return gin.H{
 		"answer":    resp.Answer,
 		"citations": citations,
 		"matches":   matches,
-	})
+	}
+}
+
+// unfilteredOverfetch is how many times topK matches are fetched from
+// backends that cannot filter, so that enough survive filtering.
+const unfilteredOverfetch = 5
+
+// retrieve searches the chunks the filter allows. Backends that cannot
+// filter (the in-process fake) search all chunks for more matches than
+// topK, and matches outside filter.DocumentIDs are dropped afterwards;
+// callers still drop matches outside the user's documents (see
+// ownedMatches) and keep the first topK.
+func (h *Handler) retrieve(ctx context.Context, query string, topK int, filter rag.Filter) (*rag.RetrieveResponse, error) {
+	var (
+		resp *rag.RetrieveResponse
+		err  error
+	)
+	if fr, ok := h.ragClient.(ports.FilteredRetriever); ok {
+		resp, err = fr.RetrieveFiltered(ctx, query, topK, filter)
+	} else {
+		resp, err = h.ragClient.Retrieve(ctx, query, topK*unfilteredOverfetch)
+	}
+	if err != nil || len(filter.DocumentIDs) == 0 {
+		return resp, err
+	}
+	matches := resp.Matches[:0]
+	for _, m := range resp.Matches {
+		if m.DocumentID != nil && slices.Contains(filter.DocumentIDs, *m.DocumentID) {
+			matches = append(matches, m)
+		}
+	}
+	resp.Matches = matches
+	return resp, nil
+}
+
+// segmentLabel renders a segment span for display, e.g. "slide 12",
+// "slides 12-13" or "chapter 3: Installation".
+func segmentLabel(kind string, start, end int, title string) string {
+	label := fmt.Sprintf("%s %d", kind, start)
+	if end > start {
+		label = fmt.Sprintf("%ss %d-%d", kind, start, end)
+	}
+	if title != "" {
+		label += ": " + title
+	}
+	return label
+}
+
+// ragErrorStatus maps a RAG client error to a response status: 503 while
+// the RAG service is unavailable (including an open circuit breaker), 400
+// when it rejected the request, and 502 otherwise.
+func ragErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, rag.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, rag.ErrBadRequest):
+		return http.StatusBadRequest
+	default:
+		return http.StatusBadGateway
+	}
 }
@@ -0,0 +1,206 @@
+package documents
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	// reconcilePageSize is the number of points listed per request.
+	reconcilePageSize = 1000
+	// reconcileBatchSize is the number of points deleted per request.
+	reconcileBatchSize = 64
+)
+
+// ReconcileReport summarizes a reconciliation of chunks with vector points.
+type ReconcileReport struct {
+	Chunks int `json:"chunks"` // embedded (non-parent) chunks checked
+	Points int `json:"points"` // vector points checked
+
+	// Unlinked chunks have a vector but were not recorded as indexed (the
+	// upload failed after embedding).
+	Unlinked int `json:"unlinked"`
+	// Missing chunks have no vector.
+	Missing int `json:"missing"`
+	// Orphans are vectors with no chunk (e.g. of deleted documents).
+	Orphans int `json:"orphans"`
+
+	Linked     int `json:"linked"`
+	Reembedded int `json:"reembedded"`
+	Deleted    int `json:"deleted"`
+}
+
+func (r ReconcileReport) String() string {
+	return fmt.Sprintf("%d chunks, %d points: %d unlinked (%d linked), %d missing (%d re-embedded), %d orphaned (%d deleted)",
+		r.Chunks, r.Points, r.Unlinked, r.Linked, r.Missing, r.Reembedded, r.Orphans, r.Deleted)
+}
+
+// Reconcile compares the chunks to embed with the vector store's points and
+// repairs the differences: chunks with a vector but no recorded point ID are
+// linked, chunks without a vector are embedded again, and vectors without a
+// chunk are deleted. With dryRun it only reports them.
+func (h *Handler) Reconcile(ctx context.Context, dryRun bool) (ReconcileReport, error) {
+	var report ReconcileReport
+
+	points, err := h.listPoints(ctx)
+	if err != nil {
+		return report, err
+	}
+	report.Points = len(points)
+
+	rows, err := h.db.QueryContext(
+		ctx,
+		`SELECT id::text, document_id::text, qdrant_point_id IS NOT NULL
+		 FROM document_chunks
+		 WHERE NOT is_parent`,
+	)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	var (
+		unlinked []string
+		missing  = make(map[string][]string) // by document
+		chunks   = make(map[string]bool)
+	)
+	for rows.Next() {
+		var (
+			id, documentID string
+			linked         bool
+		)
+		if err := rows.Scan(&id, &documentID, &linked); err != nil {
+			return report, err
+		}
+		chunks[id] = true
+		switch {
+		case !points[id]:
+			missing[documentID] = append(missing[documentID], id)
+			report.Missing++
+		case !linked:
+			unlinked = append(unlinked, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+	report.Chunks = len(chunks)
+	report.Unlinked = len(unlinked)
+
+	var orphans []string
+	for id := range points {
+		if !chunks[id] {
+			orphans = append(orphans, id)
+		}
+	}
+	report.Orphans = len(orphans)
+
+	if dryRun {
+		return report, nil
+	}
+
+	if err := h.recordEmbedded(ctx, unlinked); err != nil {
+		return report, fmt.Errorf("link chunks: %w", err)
+	}
+	report.Linked = len(unlinked)
+
+	var embedErr error
+	for documentID, ids := range missing {
+		n, err := h.reembedChunks(ctx, documentID, ids)
+		report.Reembedded += n
+		if err != nil {
+			log.Printf("warning: reconcile: document %s: %v", documentID, err)
+			if embedErr == nil {
+				embedErr = fmt.Errorf("embed chunks of document %s: %w", documentID, err)
+			}
+		}
+	}
+
+	for i := 0; i < len(orphans); i += reconcileBatchSize {
+		n, err := h.ragClient.DeletePoints(ctx, orphans[i:min(i+reconcileBatchSize, len(orphans))])
+		if err != nil {
+			return report, err
+		}
+		report.Deleted += n
+	}
+	return report, embedErr
+}
+
+// ReconcileRoute runs Reconcile inside the server. Indexes held in the
+// server's memory (VECTOR_BACKEND=embedded, RAG_MODE=fake) can only be
+// reconciled here: `api reconcile` would build and repair its own copy. One
+// reconciliation runs at a time; "dry_run=true" only reports.
+//
+// Route: POST /admin/reconcile
+func (h *Handler) ReconcileRoute(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	if !h.reconciling.CompareAndSwap(false, true) {
+		c.JSON(http.StatusConflict, gin.H{"error": "a reconciliation is already running"})
+		return
+	}
+	defer h.reconciling.Store(false)
+
+	// Re-embedding may take far longer than the server's write timeout.
+	clearWriteDeadline(c)
+	report, err := h.Reconcile(c.Request.Context(), dryRun)
+	log.Printf("reconcile: %s", report)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "reconcile failed: " + err.Error(), "report": report})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"dry_run": dryRun, "report": report})
+}
+
+// listPoints returns the IDs of all vector points.
+func (h *Handler) listPoints(ctx context.Context) (map[string]bool, error) {
+	points := make(map[string]bool)
+	offset := ""
+	for {
+		page, err := h.ragClient.ListPoints(ctx, offset, reconcilePageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page.Points {
+			points[p.ID] = true
+		}
+		if page.NextOffset == nil || *page.NextOffset == "" {
+			return points, nil
+		}
+		offset = *page.NextOffset
+	}
+}
+
+// reembedChunks embeds the given chunks of a document, which have no vector,
+// and returns how many were indexed. Their stale links are cleared first,
+// and the document's status is updated to match.
+func (h *Handler) reembedChunks(ctx context.Context, documentID string, chunkIDs []string) (int, error) {
+	rows, err := h.db.QueryContext(
+		ctx,
+		`UPDATE document_chunks
+		 SET qdrant_point_id = NULL, embedded_at = NULL, updated_at = now()
+		 WHERE id = ANY($1::uuid[])
+		 RETURNING `+chunkInColumns,
+		pq.Array(chunkIDs),
+	)
+	if err != nil {
+		return 0, err
+	}
+	chunks, err := scanChunkIns(rows)
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].ChunkIndex < chunks[j].ChunkIndex })
+
+	n, err := h.embedChunks(ctx, documentID, chunks)
+	if _, _, statusErr := h.updateEmbeddingStatus(ctx, documentID); err == nil {
+		err = statusErr
+	}
+	return n, err
+}
@@ -0,0 +1,26 @@
+package documents
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReconcileRouteRunsOneAtATime(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{}
+	h.reconciling.Store(true)
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/admin/reconcile", nil)
+	h.ReconcileRoute(c)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if !h.reconciling.Load() {
+		t.Error("a refused run cleared the running reconciliation's flag")
+	}
+}
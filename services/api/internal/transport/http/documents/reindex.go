@@ -0,0 +1,115 @@
+package documents
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"docsense/api/internal/ingest/chunk"
+	"docsense/api/internal/transport/http/middleware"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/google/uuid"
+)
+
+var errDocumentNotFound = errors.New("document not found")
+
+// Reindex extracts and chunks a document's stored file again, with its
+// recorded chunking, and re-embeds only the chunks whose text changed;
+// unchanged chunks keep their vectors (see storeIngested). Attachments are
+// not ingested again.
+//
+// Route: POST /api/documents/:id/reindex
+// Form fields: "password" for encrypted PDFs. Optional "chunk_strategy",
+// "chunk_size", "chunk_overlap" and "chunk_parent_size" override the
+// recorded chunking.
+//
+// The response has the document's resulting status ("ready", or "partial"
+// if chunks were left unembedded) and its "embedding" progress.
+func (h *Handler) Reindex(c *gin.Context) {
+	userID, ok := middleware.GetAuthenticatedUserID(c)
+	if !ok {
+		middleware.AbortUnauthorized(c)
+		return
+	}
+
+	docID := c.Param("id")
+	doc, err := h.loadStoredDocument(c.Request.Context(), docID, userID)
+	if errors.Is(err, errDocumentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query document"})
+		return
+	}
+
+	if doc.Chunking, err = h.chunkingOptions(c, doc.Chunking); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	doc.Password = c.PostForm("password")
+	doc.Reingest = true
+
+	clearWriteDeadline(c)
+	if _, err := h.ingestDocument(c.Request.Context(), doc); err != nil {
+		h.markIngestFailed(c.Request.Context(), docID, err)
+		status, body := ingestErrorResponse(err)
+		body["document_id"] = docID
+		c.JSON(status, body)
+		return
+	}
+	status, progress, err := h.documentStatus(c.Request.Context(), docID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query document status", "document_id": docID})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"document_id": docID, "status": status, "embedding": progress})
+}
+
+// loadStoredDocument loads a user's document for ingesting it again. Its
+// chunking is the recorded one, or the handler's default if none was.
+func (h *Handler) loadStoredDocument(ctx context.Context, documentID, userID string) (storedDocument, error) {
+	if _, err := uuid.Parse(documentID); err != nil {
+		return storedDocument{}, errDocumentNotFound
+	}
+
+	var (
+		storagePath, mimeType sql.NullString
+		chunking              []byte
+	)
+	err := h.db.QueryRowContext(
+		ctx,
+		`SELECT storage_path, mime_type, metadata->'chunking' FROM documents WHERE id = $1 AND user_id = $2`,
+		documentID,
+		userID,
+	).Scan(&storagePath, &mimeType, &chunking)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !storagePath.Valid) {
+		return storedDocument{}, errDocumentNotFound
+	}
+	if err != nil {
+		return storedDocument{}, err
+	}
+	abs, ok := h.storageAbs(storagePath.String)
+	if !ok {
+		return storedDocument{}, errDocumentNotFound
+	}
+
+	doc := storedDocument{
+		ID:         documentID,
+		UserID:     userID,
+		StorageAbs: abs,
+		MIMEType:   mimeType.String,
+		Chunking:   h.chunking,
+	}
+	if len(chunking) > 0 {
+		var recorded chunk.Options
+		if err := json.Unmarshal(chunking, &recorded); err != nil {
+			return storedDocument{}, err
+		}
+		doc.Chunking = recorded
+	}
+	return doc, nil
+}
@@ -10,4 +10,16 @@ func (h *Handler) RegisterRoutes(rg *gin.RouterGroup) {
 	docs.POST("/upload", h.Upload)
 	docs.GET("", h.List)
 	docs.POST("/query", h.Query)
+	docs.POST("/query/stream", h.QueryStream)
+	docs.GET("/:id", h.Get)
+	docs.GET("/:id/file", h.File)
+	docs.POST("/:id/reindex", h.Reindex)
+	docs.POST("/:id/embed", h.Embed)
+	docs.GET("/:id/chunks/:chunk_id/context", h.ChunkContext)
+}
+
+// RegisterAdminRoutes wires the operator routes; rg must be behind
+// authentication.
+func (h *Handler) RegisterAdminRoutes(rg *gin.RouterGroup) {
+	rg.POST("/reconcile", h.ReconcileRoute)
 }
@@ -0,0 +1,87 @@
+package documents
+
+import (
+	"net/http"
+
+	"docsense/api/internal/metrics"
+	"docsense/api/internal/requestctx"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QueryStream answers a query like Query, streaming the answer as
+// Server-Sent Events while it is generated:
+//
+//	event: delta   data: {"text": "..."}       a piece of the answer
+//	event: done    data: {"answer", "citations", "matches"} as Query returns them
+//	event: error   data: {"error": "...", "status": 503, "request_id": "..."}
+//
+// Failures before the first event (validation, retrieval) are plain JSON
+// responses like Query's. A client disconnecting cancels generation.
+//
+// Route: POST /api/documents/query/stream
+func (h *Handler) QueryStream(c *gin.Context) {
+	metrics.QueriesInFlight.Inc()
+	defer metrics.QueriesInFlight.Dec()
+
+	req, contexts, retrieved, ok := h.prepareQuery(c)
+	if !ok {
+		return
+	}
+
+	clearWriteDeadline(c)
+
+	stream := &eventStream{c: c}
+	resp, err := h.ragClient.GenerateStream(c.Request.Context(), req.Query, contexts, func(delta string) error {
+		return stream.send("delta", gin.H{"text": delta})
+	})
+	if err != nil {
+		if c.Request.Context().Err() != nil {
+			return // the client went away
+		}
+		stream.fail(ragErrorStatus(err), "query failed: "+err.Error())
+		return
+	}
+	_ = stream.send("done", queryResult(resp, retrieved))
+}
+
+// eventStream writes Server-Sent Events to c. The stream's headers and 200
+// status go out with the first event, so a failure before it can still be a
+// plain JSON error.
+type eventStream struct {
+	c       *gin.Context
+	started bool
+}
+
+// send writes one event and flushes it. It returns the request context's
+// error once the client has gone away.
+func (s *eventStream) send(event string, data any) error {
+	if !s.started {
+		s.started = true
+		s.c.Header("Content-Type", "text/event-stream")
+		s.c.Header("Cache-Control", "no-cache")
+		s.c.Header("Connection", "keep-alive")
+		// Keep reverse proxies (nginx) from buffering the stream.
+		s.c.Header("X-Accel-Buffering", "no")
+		s.c.Status(http.StatusOK)
+	}
+	s.c.SSEvent(event, data)
+	s.c.Writer.Flush()
+	return s.c.Request.Context().Err()
+}
+
+// fail reports an error: as a JSON response with status before the first
+// event, or as an error event after it.
+func (s *eventStream) fail(status int, msg string) {
+	if !s.started {
+		s.c.JSON(status, gin.H{"error": msg})
+		return
+	}
+	// The middleware adds the request ID to JSON error responses, but
+	// this one is an event in a 200 response.
+	_ = s.send("error", gin.H{
+		"error":      msg,
+		"status":     status,
+		"request_id": requestctx.RequestID(s.c.Request.Context()),
+	})
+}
@@ -0,0 +1,121 @@
+package documents
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"docsense/api/internal/requestctx"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newStreamContext(ctx context.Context) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/documents/query/stream", nil).WithContext(ctx)
+	return c, rec
+}
+
+func TestEventStream(t *testing.T) {
+	ctx := requestctx.WithRequestID(context.Background(), "req-1")
+	tests := []struct {
+		name       string
+		run        func(s *eventStream)
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{
+			name: "deltas then done",
+			run: func(s *eventStream) {
+				_ = s.send("delta", gin.H{"text": "Hello"})
+				_ = s.send("delta", gin.H{"text": " world"})
+				_ = s.send("done", gin.H{"answer": "Hello world"})
+			},
+			wantStatus: http.StatusOK,
+			wantType:   "text/event-stream",
+			wantBody: "event:delta\ndata:{\"text\":\"Hello\"}\n\n" +
+				"event:delta\ndata:{\"text\":\" world\"}\n\n" +
+				"event:done\ndata:{\"answer\":\"Hello world\"}\n\n",
+		},
+		{
+			name: "newlines stay inside one data line",
+			run: func(s *eventStream) {
+				_ = s.send("delta", gin.H{"text": "line one\n\nline two"})
+			},
+			wantStatus: http.StatusOK,
+			wantType:   "text/event-stream",
+			wantBody:   "event:delta\ndata:{\"text\":\"line one\\n\\nline two\"}\n\n",
+		},
+		{
+			name: "failure before the first event is plain JSON",
+			run: func(s *eventStream) {
+				s.fail(http.StatusServiceUnavailable, "query failed: unavailable")
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantType:   "application/json",
+			wantBody:   `{"error":"query failed: unavailable"}`,
+		},
+		{
+			name: "failure after an event is an error event",
+			run: func(s *eventStream) {
+				_ = s.send("delta", gin.H{"text": "Hel"})
+				s.fail(http.StatusBadGateway, "query failed: reset")
+			},
+			wantStatus: http.StatusOK,
+			wantType:   "text/event-stream",
+			wantBody: "event:delta\ndata:{\"text\":\"Hel\"}\n\n" +
+				"event:error\ndata:{\"error\":\"query failed: reset\",\"request_id\":\"req-1\",\"status\":502}\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newStreamContext(ctx)
+			tt.run(&eventStream{c: c})
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type")); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("body:\n got %q\nwant %q", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestEventStreamHeaders(t *testing.T) {
+	c, rec := newStreamContext(context.Background())
+	if err := (&eventStream{c: c}).send("delta", gin.H{"text": "x"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	for k, want := range map[string]string{
+		"Cache-Control":     "no-cache",
+		"X-Accel-Buffering": "no",
+	} {
+		if got := rec.Header().Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+	if !rec.Flushed {
+		t.Error("event was not flushed")
+	}
+}
+
+func TestEventStreamClientGone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c, _ := newStreamContext(ctx)
+	s := &eventStream{c: c}
+	if err := s.send("delta", gin.H{"text": "a"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	cancel()
+	if err := s.send("delta", gin.H{"text": "b"}); err != context.Canceled {
+		t.Errorf("send after disconnect = %v, want %v", err, context.Canceled)
+	}
+}
@@ -3,6 +3,7 @@ package documents
 import (
 	"context"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -13,22 +14,42 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
-	"docsense/api/internal/adapters/rag"
 	"docsense/api/internal/app"
 	"docsense/api/internal/ingest/chunk"
 	"docsense/api/internal/ingest/extract"
+	"docsense/api/internal/metrics"
 	"docsense/api/internal/transport/http/middleware"
 
 	"github.com/gin-gonic/gin"
 	uuid "github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Upload handles multipart PDF uploads.
+// Upload handles multipart document uploads (PDF, TXT, MD, PPTX, ODP, EPUB,
+// EML, MBOX). Supported email attachments become child documents.
 //
 // Route: POST /api/documents/upload
-// Form field: "file"
+// Form fields: "file", and "password" for encrypted PDFs. Optional
+// "chunk_strategy", "chunk_size" and "chunk_overlap" override the default
+// chunking for this document. An optional "document_id" uploads a new
+// version of that document: only chunks whose text changed are embedded
+// again (see storeIngested). If the new version fails to ingest, the
+// document is left as it was, previous file included.
+//
+// The response has the document's resulting status ("ready", or "partial"
+// if chunks were left unembedded), its "embedding" progress, and the
+// "attachments" ingested as child documents with theirs.
+//
+// Documents that cannot be read fail with 422 and a "code": pdf_encrypted,
+// pdf_corrupt, or no_text_layer (the document is kept with status needs_ocr).
 func (h *Handler) Upload(c *gin.Context) {
 	userID, ok := middleware.GetAuthenticatedUserID(c)
 	if !ok {
@@ -43,6 +64,9 @@ func (h *Handler) Upload(c *gin.Context) {
 		}
 	}
 
+	// Extraction, chunking and embedding run before the response.
+	clearWriteDeadline(c)
+
 	// Enforce a hard limit on request body size.
 	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadBytes)
 
@@ -66,13 +90,39 @@ func (h *Handler) Upload(c *gin.Context) {
 		return
 	}
 
-	if err := validatePDF(fileHeader); err != nil {
+	if err := validateUpload(fileHeader); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	docID, err := h.newDocumentID(c.Request.Context())
+	chunking, err := h.chunkingOptions(c, h.chunking)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	docID, previousPath := c.PostForm("document_id"), ""
+	var previousFile documentFile
+	if _, err := uuid.Parse(docID); docID != "" && err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+	if docID != "" {
+		previous, err := h.loadStoredDocument(c.Request.Context(), docID, userID)
+		if errors.Is(err, errDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query document"})
+			return
+		}
+		previousPath = previous.StorageAbs
+		if previousFile, err = h.loadDocumentFile(c.Request.Context(), docID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query document"})
+			return
+		}
+	} else if docID, err = h.newDocumentID(c.Request.Context()); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to allocate document id"})
 		return
 	}
@@ -80,13 +130,26 @@ func (h *Handler) Upload(c *gin.Context) {
 	safeFilename := sanitizeFilename(fileHeader.Filename)
 	storageRel := filepath.ToSlash(filepath.Join(userID, fmt.Sprintf("%s_%s", docID, safeFilename)))
 	storageAbs := filepath.Join(h.storageDir, filepath.FromSlash(storageRel))
+	if storageAbs == previousPath {
+		// Keep the previous version's file until the new one is ingested.
+		storageRel = filepath.ToSlash(filepath.Join(userID, fmt.Sprintf("%s_%s_%s", docID, uuid.NewString()[:8], safeFilename)))
+		storageAbs = filepath.Join(h.storageDir, filepath.FromSlash(storageRel))
+	}
 
 	if err := os.MkdirAll(filepath.Dir(storageAbs), 0o755); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to prepare storage"})
 		return
 	}
 
-	if err := saveMultipartFileAtomic(fileHeader, storageAbs); err != nil {
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(
+		attribute.String("document.id", docID),
+		attribute.Int64("upload.size_bytes", fileHeader.Size),
+	)
+	metrics.UploadSize.Observe(float64(fileHeader.Size))
+	_, stage := startStage(c.Request.Context(), "save", attribute.Int64("upload.size_bytes", fileHeader.Size))
+	err = saveMultipartFileAtomic(fileHeader, storageAbs)
+	stage.end(err)
+	if err != nil {
 		status := http.StatusInternalServerError
 		if errors.Is(err, errRequestTooLarge) {
 			status = http.StatusRequestEntityTooLarge
@@ -103,77 +166,148 @@ func (h *Handler) Upload(c *gin.Context) {
 		return
 	}
 
-	mimeType := fileHeader.Header.Get("Content-Type")
+	mimeType := extract.MIMETypeForFilename(safeFilename)
+	if mimeType == "" {
+		mimeType = fileHeader.Header.Get("Content-Type")
+	}
 	if mimeType == "" {
 		mimeType = "application/octet-stream"
 	}
-	if err := h.insertDocumentMetadata(c.Request.Context(), docID, userID, safeFilename, storageRel, fileHeader.Size, mimeType, checksum); err != nil {
+	if err := h.insertDocumentMetadata(c.Request.Context(), docID, userID, safeFilename, storageRel, fileHeader.Size, mimeType, checksum, "", nil); err != nil {
 		_ = os.Remove(storageAbs)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist metadata"})
 		return
 	}
 
-	// Synchronously extract text (simple, no chunking) and persist to document_contents.
-	content, err := extract.ExtractText(storageAbs, mimeType)
+	children, err := h.ingestDocument(c.Request.Context(), storedDocument{
+		ID:         docID,
+		UserID:     userID,
+		StorageAbs: storageAbs,
+		MIMEType:   mimeType,
+		Password:   c.PostForm("password"),
+		Chunking:   chunking,
+		Reingest:   previousPath != "",
+	})
 	if err != nil {
-		_ = os.Remove(storageAbs)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to extract document text"})
+		h.abandonUpload(c.Request.Context(), docID, storageAbs, previousPath, previousFile, err)
+		status, body := ingestErrorResponse(err)
+		body["document_id"] = docID
+		c.JSON(status, body)
 		return
 	}
+	if previousPath != "" {
+		_ = os.Remove(previousPath)
+	}
 
-	if err := h.insertDocumentContent(c.Request.Context(), docID, content); err != nil {
-		_ = os.Remove(storageAbs)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist document content"})
+	status, progress, err := h.documentStatus(c.Request.Context(), docID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query document status", "document_id": docID})
 		return
 	}
+	resp := gin.H{"document_id": docID, "status": status, "embedding": progress}
+	if len(children) > 0 {
+		resp["attachments"] = children
+	}
+	c.JSON(http.StatusOK, resp)
+}
 
-	// Deterministically chunk the extracted text and persist chunks.
-	// Convert document id string to uuid.UUID
-	docUUID, err := uuid.Parse(docID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid document id"})
+// documentFile is a document's stored file and what was recorded from it,
+// kept while a new version is ingested.
+type documentFile struct {
+	title, filename, storagePath, mimeType, checksum sql.NullString
+	sizeBytes                                        sql.NullInt64
+	status                                           string
+	metadata                                         []byte
+}
+
+func (h *Handler) loadDocumentFile(ctx context.Context, documentID string) (documentFile, error) {
+	var f documentFile
+	err := h.db.QueryRowContext(
+		ctx,
+		`SELECT title, filename, storage_path, mime_type, checksum_sha256, size_bytes, status, metadata
+		 FROM documents WHERE id = $1`,
+		documentID,
+	).Scan(&f.title, &f.filename, &f.storagePath, &f.mimeType, &f.checksum, &f.sizeBytes, &f.status, &f.metadata)
+	return f, err
+}
+
+// abandonUpload cleans up after an upload failed to ingest. A new version
+// of a document puts the previous file's record back and removes the new
+// file, so the document is as it was (chunks are only replaced once
+// ingestion succeeds). A new document is marked failed.
+func (h *Handler) abandonUpload(ctx context.Context, documentID, storageAbs, previousPath string, previous documentFile, ingestErr error) {
+	if previousPath == "" {
+		h.markIngestFailed(ctx, documentID, ingestErr)
 		return
 	}
-	chunks, err := chunk.ChunkText(docUUID, content)
+	_, err := h.db.ExecContext(
+		ctx,
+		`UPDATE documents
+		 SET title = $2, filename = $3, storage_path = $4, mime_type = $5, checksum_sha256 = $6,
+		     size_bytes = $7, status = $8, metadata = $9::jsonb, updated_at = now()
+		 WHERE id = $1`,
+		documentID,
+		previous.title,
+		previous.filename,
+		previous.storagePath,
+		previous.mimeType,
+		previous.checksum,
+		previous.sizeBytes,
+		previous.status,
+		string(previous.metadata),
+	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to chunk document text"})
+		// The document still refers to the new file; keep it.
+		log.Printf("warning: document %s: failed to restore the previous version: %v", documentID, err)
+		h.markIngestFailed(ctx, documentID, ingestErr)
 		return
 	}
-	if len(chunks) > 0 {
-		if err := h.insertDocumentChunks(c.Request.Context(), chunks); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist document chunks"})
-			return
-		}
+	_ = os.Remove(storageAbs)
+}
 
-		// Send chunks to RAG service for embedding and indexing
-		ragChunks := make([]rag.ChunkIn, len(chunks))
-		for i, ch := range chunks {
-			chunkID, err := h.getChunkIDByIndex(c.Request.Context(), docID, ch.Index)
-			if err != nil {
-				log.Printf("warning: failed to get chunk ID for index %d: %v", ch.Index, err)
-				continue
-			}
-			ragChunks[i] = rag.ChunkIn{
-				ChunkID:    chunkID,
-				ChunkIndex: ch.Index,
-				Text:       ch.Content,
-			}
+// chunkingOptions returns base (the handler's default chunking, or a
+// document's recorded one) with any overrides from the form applied. Sizes
+// are in the handler's tokenizer's tokens, which the options record.
+func (h *Handler) chunkingOptions(c *gin.Context, base chunk.Options) (chunk.Options, error) {
+	opts := base
+	opts.Tokenizer = h.tokenizer.Name()
+	if v := c.PostForm("chunk_strategy"); v != "" {
+		opts.Strategy = v
+	}
+	for field, dst := range map[string]*int{"chunk_size": &opts.Size, "chunk_overlap": &opts.Overlap, "chunk_parent_size": &opts.ParentSize} {
+		v := c.PostForm(field)
+		if v == "" {
+			continue
 		}
-
-		if len(ragChunks) > 0 {
-			if _, err := h.ragClient.EmbedChunks(c.Request.Context(), docID, ragChunks); err != nil {
-				log.Printf("warning: failed to embed chunks: %v", err)
-				// Don't fail the upload, but log the error
-			}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return chunk.Options{}, fmt.Errorf("invalid %s: %q", field, v)
 		}
+		*dst = n
 	}
-
-	if err := h.updateDocumentStatus(c.Request.Context(), docID, "ready"); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update document status"})
-		return
+	if err := opts.Validate(); err != nil {
+		return chunk.Options{}, err
 	}
+	return opts, nil
+}
 
-	c.JSON(http.StatusOK, gin.H{"document_id": docID})
+// recordChunking stores the chunking used for a document in its metadata, so
+// it can be reindexed the same way.
+func recordChunking(ctx context.Context, tx *sql.Tx, documentID string, opts chunk.Options) error {
+	optsJSON, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE documents
+		 SET metadata = metadata || jsonb_build_object('chunking', $2::jsonb),
+		     updated_at = now()
+		 WHERE id = $1`,
+		documentID,
+		string(optsJSON),
+	)
+	return err
 }
 
 func (h *Handler) ensureDevUserExists(ctx context.Context, userID string) error {
@@ -200,25 +334,36 @@ func (h *Handler) newDocumentID(ctx context.Context) (string, error) {
 	return id, nil
 }
 
-func (h *Handler) insertDocumentMetadata(ctx context.Context, documentID, userID, filename, storagePath string, sizeBytes int64, mimeType, checksumSHA256 string) error {
+func (h *Handler) insertDocumentMetadata(ctx context.Context, documentID, userID, filename, storagePath string, sizeBytes int64, mimeType, checksumSHA256, parentDocumentID string, extraMeta map[string]any) error {
+	// Documents extracted from another document (email attachments) are
+	// linked to it and marked as such.
+	sourceType := "upload"
+	if parentDocumentID != "" {
+		sourceType = "attachment"
+	}
+
 	// Minimal metadata; additional columns can be added as the product evolves.
 	meta := map[string]any{
 		"original_filename": filename,
 		"storage":           "local",
 	}
+	for k, v := range extraMeta {
+		meta[k] = v
+	}
 	metaJSON, _ := json.Marshal(meta)
 
 	// Document lifecycle states (minimal):
 	// - 'uploaded': file has been received and metadata persisted. Next step is ingestion.
 	// - 'ingesting': background ingestion/processing is ongoing (e.g., text extraction, embeddings).
 	// - 'ready': ingestion completed and document is available for search.
+	// - 'partial': ingested, but some chunks failed to embed (see Embed).
 	// On upload we create or update the document row and set status = 'uploaded'.
 	// Use an upsert so repeated uploads for the same id (shouldn't normally happen)
 	// will result in updating the storage path / filename and resetting the status.
 	_, err := h.db.ExecContext(
 		ctx,
-		`INSERT INTO documents (id, user_id, title, source_type, mime_type, size_bytes, filename, storage_path, status, metadata, checksum_sha256)
-		 VALUES ($1, $2, $3, 'upload', $4, $5, $6, $7, 'uploaded', $8::jsonb, $9)
+		`INSERT INTO documents (id, user_id, title, source_type, mime_type, size_bytes, filename, storage_path, status, metadata, checksum_sha256, parent_document_id)
+		 VALUES ($1, $2, $3, $10, $4, $5, $6, $7, 'uploaded', $8::jsonb, $9, $11)
 		 ON CONFLICT (id) DO UPDATE SET
 		   user_id = EXCLUDED.user_id,
 		   title = EXCLUDED.title,
@@ -229,7 +374,8 @@ func (h *Handler) insertDocumentMetadata(ctx context.Context, documentID, userID
 		   storage_path = EXCLUDED.storage_path,
 		   status = 'uploaded',
 		   metadata = EXCLUDED.metadata,
-		   checksum_sha256 = EXCLUDED.checksum_sha256`,
+		   checksum_sha256 = EXCLUDED.checksum_sha256,
+		   parent_document_id = EXCLUDED.parent_document_id`,
 		documentID,
 		userID,
 		filename,
@@ -239,33 +385,48 @@ func (h *Handler) insertDocumentMetadata(ctx context.Context, documentID, userID
 		storagePath,
 		string(metaJSON),
 		checksumSHA256,
+		sourceType,
+		nullString(parentDocumentID),
 	)
 	return err
 }
 
-func validatePDF(fh *multipart.FileHeader) error {
+func validateUpload(fh *multipart.FileHeader) error {
 	name := strings.ToLower(fh.Filename)
 	if strings.HasSuffix(name, ".pdf") {
-		f, err := fh.Open()
-		if err != nil {
-			return fmt.Errorf("unable to read file")
-		}
-		defer func() { _ = f.Close() }()
-
-		header := make([]byte, 5)
-		_, err = io.ReadFull(f, header)
-		if err != nil {
-			return fmt.Errorf("unable to read file")
-		}
-		if string(header) != "%PDF-" {
-			return fmt.Errorf("invalid PDF signature")
-		}
-		return nil
+		return checkSignature(fh, "%PDF-", "invalid PDF signature")
 	}
 	if strings.HasSuffix(name, ".txt") || strings.HasSuffix(name, ".md") {
 		return nil
 	}
-	return fmt.Errorf("only PDF, TXT, and MD files are supported")
+	if strings.HasSuffix(name, ".pptx") || strings.HasSuffix(name, ".odp") {
+		// Both are zip containers.
+		return checkSignature(fh, "PK\x03\x04", "invalid presentation file")
+	}
+	if strings.HasSuffix(name, ".epub") {
+		return checkSignature(fh, "PK\x03\x04", "invalid EPUB file")
+	}
+	if strings.HasSuffix(name, ".eml") || strings.HasSuffix(name, ".mbox") {
+		return nil
+	}
+	return fmt.Errorf("only PDF, TXT, MD, PPTX, ODP, EPUB, EML, and MBOX files are supported")
+}
+
+func checkSignature(fh *multipart.FileHeader, signature, message string) error {
+	f, err := fh.Open()
+	if err != nil {
+		return fmt.Errorf("unable to read file")
+	}
+	defer func() { _ = f.Close() }()
+
+	header := make([]byte, len(signature))
+	if _, err := io.ReadFull(f, header); err != nil {
+		return fmt.Errorf("unable to read file")
+	}
+	if string(header) != signature {
+		return errors.New(message)
+	}
+	return nil
 }
 
 func sanitizeFilename(name string) string {
@@ -281,7 +442,12 @@ func saveMultipartFileAtomic(fh *multipart.FileHeader, dst string) error {
 		return err
 	}
 	defer func() { _ = src.Close() }()
+	return writeFileAtomic(src, dst)
+}
 
+// writeFileAtomic copies src to a temp file next to dst and renames it into
+// place, so readers never observe a partially written file.
+func writeFileAtomic(src io.Reader, dst string) error {
 	dir := filepath.Dir(dst)
 	tmp, err := os.CreateTemp(dir, ".upload-*.tmp")
 	if err != nil {
@@ -312,26 +478,61 @@ func saveMultipartFileAtomic(fh *multipart.FileHeader, dst string) error {
 	return nil
 }
 
-func (h *Handler) insertDocumentContent(ctx context.Context, documentID, content string) error {
-	_, err := h.db.ExecContext(
+// applyExtractedMetadata records what the extractor learned about the
+// document itself: its own title replaces the filename-derived one, and
+// format metadata (author, language, ...) is merged into documents.metadata.
+// The document outline, if any, is kept there under "outline".
+func (h *Handler) applyExtractedMetadata(ctx context.Context, documentID string, res *extract.Result) error {
+	if res.Title == "" && len(res.Metadata) == 0 && len(res.Outline) == 0 {
+		return nil
+	}
+	meta := map[string]any{}
+	for k, v := range res.Metadata {
+		meta[k] = v
+	}
+	if len(res.Outline) > 0 {
+		meta["outline"] = res.Outline
+	}
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	_, err = h.db.ExecContext(
 		ctx,
-		`INSERT INTO document_contents (document_id, content) VALUES ($1, $2)
-		 ON CONFLICT (document_id) DO UPDATE SET content = EXCLUDED.content, created_at = now()`,
+		`UPDATE documents
+		 SET title = COALESCE(NULLIF($2, ''), title),
+		     metadata = metadata || $3::jsonb,
+		     updated_at = now()
+		 WHERE id = $1`,
 		documentID,
-		content,
+		res.Title,
+		string(metaJSON),
 	)
 	return err
 }
 
-func (h *Handler) updateDocumentStatus(ctx context.Context, documentID, status string) error {
-	_, err := h.db.ExecContext(ctx, `UPDATE documents SET status = $1, updated_at = now() WHERE id = $2`, status, documentID)
-	return err
+// storedChunks describes the chunks storeIngested stored for a document,
+// compared with its previous version.
+type storedChunks struct {
+	// IDs are the IDs of chunked.Chunks.
+	IDs []string
+	// Reused marks the chunks identical to an embedded chunk of the
+	// previous version; they keep its ID, and so its vector.
+	Reused []bool
+	// Removed are the IDs of previous chunks that were not reused, whose
+	// vectors are stale.
+	Removed []string
 }
 
-func (h *Handler) insertDocumentChunks(ctx context.Context, chunks []chunk.Chunk) error {
+// storeIngested writes an ingested document's content, chunks and chunking
+// options and marks it ready, all in one transaction, so a document never
+// ends up with only some of its chunks. Chunks of a previous version are
+// replaced; new chunks with the same text as a previous embedded chunk
+// (by content_sha256) take over its ID and vector.
+func (h *Handler) storeIngested(ctx context.Context, doc storedDocument, text string, chunked chunkedDocument) (stored storedChunks, err error) {
 	tx, err := h.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return stored, err
 	}
 	defer func() {
 		if err != nil {
@@ -339,28 +540,229 @@ func (h *Handler) insertDocumentChunks(ctx context.Context, chunks []chunk.Chunk
 		}
 	}()
 
-	stmt := `INSERT INTO document_chunks (id, document_id, chunk_index, content_text, token_count, created_at, updated_at)
-			 VALUES (gen_random_uuid(), $1, $2, $3, $4, now(), now())`
+	if _, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO document_contents (document_id, content) VALUES ($1, $2)
+		 ON CONFLICT (document_id) DO UPDATE SET content = EXCLUDED.content, created_at = now()`,
+		doc.ID,
+		text,
+	); err != nil {
+		return stored, err
+	}
+	if err = recordChunking(ctx, tx, doc.ID, doc.Chunking); err != nil {
+		return stored, err
+	}
+	previous, err := deletePreviousChunks(ctx, tx, doc.ID)
+	if err != nil {
+		return stored, err
+	}
+	if stored, err = copyDocumentChunks(ctx, tx, text, chunked, previous); err != nil {
+		return stored, err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE documents SET status = $1, updated_at = now() WHERE id = $2`, statusReady, doc.ID); err != nil {
+		return stored, err
+	}
+	return stored, tx.Commit()
+}
+
+func (h *Handler) updateDocumentStatus(ctx context.Context, documentID, status string) error {
+	_, err := h.db.ExecContext(ctx, `UPDATE documents SET status = $1, updated_at = now() WHERE id = $2`, status, documentID)
+	return err
+}
 
-	for _, ch := range chunks {
-		_, err = tx.ExecContext(ctx, stmt, ch.DocumentID.String(), ch.Index, ch.Content, ch.TokenCount)
-		if err != nil {
-			_ = tx.Rollback()
-			return err
-		}
+// previousChunk is an embedded chunk of a document's previous version.
+type previousChunk struct {
+	id         string
+	embeddedAt time.Time
+}
+
+// previousChunks are the chunks of a document's previous version: the
+// embedded ones by content hash, in document order, and the IDs of all.
+type previousChunks struct {
+	byHash map[string][]previousChunk
+	ids    []string
+}
+
+// take returns an unused previous chunk with the given content hash.
+func (p *previousChunks) take(hash string) (previousChunk, bool) {
+	chunks := p.byHash[hash]
+	if len(chunks) == 0 {
+		return previousChunk{}, false
 	}
-	return tx.Commit()
+	p.byHash[hash] = chunks[1:]
+	return chunks[0], true
 }
 
-func (h *Handler) getChunkIDByIndex(ctx context.Context, documentID string, chunkIndex int) (string, error) {
-	var chunkID string
-	err := h.db.QueryRowContext(
+// deletePreviousChunks deletes a document's chunks and returns those that
+// were embedded (parents are not). Chunks stored before hashes were recorded
+// are hashed here.
+func deletePreviousChunks(ctx context.Context, tx *sql.Tx, documentID string) (previousChunks, error) {
+	previous := previousChunks{byHash: make(map[string][]previousChunk)}
+	rows, err := tx.QueryContext(
 		ctx,
-		`SELECT id::text FROM document_chunks WHERE document_id = $1 AND chunk_index = $2`,
+		`DELETE FROM document_chunks
+		 WHERE document_id = $1
+		 RETURNING id::text, is_parent, chunk_index,
+		           coalesce(content_sha256, encode(digest(content_text, 'sha256'), 'hex')), embedded_at`,
 		documentID,
-		chunkIndex,
-	).Scan(&chunkID)
-	return chunkID, err
+	)
+	if err != nil {
+		return previous, err
+	}
+	defer rows.Close()
+
+	type row struct {
+		id         string
+		index      int
+		hash       string
+		embeddedAt sql.NullTime
+	}
+	var embedded []row
+	for rows.Next() {
+		var (
+			r        row
+			isParent bool
+		)
+		if err := rows.Scan(&r.id, &isParent, &r.index, &r.hash, &r.embeddedAt); err != nil {
+			return previous, err
+		}
+		if isParent {
+			continue
+		}
+		previous.ids = append(previous.ids, r.id)
+		if r.embeddedAt.Valid {
+			embedded = append(embedded, r)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return previous, err
+	}
+	sort.Slice(embedded, func(i, j int) bool { return embedded[i].index < embedded[j].index })
+	for _, r := range embedded {
+		previous.byHash[r.hash] = append(previous.byHash[r.hash], previousChunk{id: r.id, embeddedAt: r.embeddedAt.Time})
+	}
+	return previous, nil
+}
+
+// copyDocumentChunks stores the chunks of text, the document's stored
+// content, with a single COPY: parents first, then the chunks to embed
+// linked to them. Chunks matching one of previous reuse its ID and
+// embedding record.
+//
+// Offsets are stored in characters (code points), as Postgres string
+// functions count them.
+func copyDocumentChunks(ctx context.Context, tx *sql.Tx, text string, chunked chunkedDocument, previous previousChunks) (storedChunks, error) {
+	var stored storedChunks
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("document_chunks",
+		"id", "document_id", "chunk_index", "is_parent", "parent_chunk_id", "content_text", "content_sha256", "token_count",
+		"start_offset", "end_offset", "segment_kind", "segment_start", "segment_end", "segment_title", "section_path",
+		"qdrant_point_id", "embedded_at"))
+	if err != nil {
+		return stored, err
+	}
+	defer stmt.Close()
+
+	// Parents and children are each in document order, so each gets its
+	// own offset converter.
+	parentOffsets := [2]runeOffsets{{text: text}, {text: text}}
+	childOffsets := [2]runeOffsets{{text: text}, {text: text}}
+	row := func(id string, ch chunk.Chunk, hash string, isParent bool, parentID string, embeddedAt sql.NullTime) error {
+		offsets := &childOffsets
+		if isParent {
+			offsets = &parentOffsets
+		}
+		var sectionPath any
+		if len(ch.SectionPath) > 0 {
+			sectionPath = pq.Array(ch.SectionPath)
+		}
+		var pointID sql.NullString
+		if embeddedAt.Valid {
+			pointID = nullString(id)
+		}
+		_, err := stmt.ExecContext(ctx, id, ch.DocumentID.String(), ch.Index, isParent, nullString(parentID), ch.Content, hash, ch.TokenCount,
+			offsets[0].at(ch.StartOffset), offsets[1].at(ch.EndOffset),
+			nullString(chunked.SegmentKind), nullPositiveInt(ch.SegmentStart), nullPositiveInt(ch.SegmentEnd), nullString(ch.SegmentTitle),
+			sectionPath, pointID, embeddedAt)
+		return err
+	}
+	reused := make(map[string]bool)
+	child := func(ch chunk.Chunk, parentID string) error {
+		hash := contentSHA256(ch.Content)
+		id, embeddedAt := uuid.NewString(), sql.NullTime{}
+		prev, ok := previous.take(hash)
+		if ok {
+			id, embeddedAt = prev.id, sql.NullTime{Time: prev.embeddedAt, Valid: true}
+			reused[id] = true
+		}
+		if err := row(id, ch, hash, false, parentID, embeddedAt); err != nil {
+			return err
+		}
+		stored.IDs = append(stored.IDs, id)
+		stored.Reused = append(stored.Reused, ok)
+		return nil
+	}
+
+	if len(chunked.Parents) == 0 {
+		for _, ch := range chunked.Chunks {
+			if err := child(ch, ""); err != nil {
+				return stored, err
+			}
+		}
+	}
+	for _, p := range chunked.Parents {
+		parentID := uuid.NewString()
+		if err := row(parentID, p.Chunk, contentSHA256(p.Content), true, "", sql.NullTime{}); err != nil {
+			return stored, err
+		}
+		for _, ch := range p.Children {
+			if err := child(ch, parentID); err != nil {
+				return stored, err
+			}
+		}
+	}
+	// Flush the buffered rows.
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return stored, err
+	}
+
+	for _, id := range previous.ids {
+		if !reused[id] {
+			stored.Removed = append(stored.Removed, id)
+		}
+	}
+	return stored, nil
+}
+
+// runeOffsets converts byte offsets into text to character offsets. It is
+// fastest when queried with non-decreasing offsets, as chunk starts and ends
+// are.
+type runeOffsets struct {
+	text    string
+	byteOff int
+	runeOff int
+}
+
+func (r *runeOffsets) at(off int) int {
+	if off < r.byteOff {
+		r.byteOff, r.runeOff = 0, 0
+	}
+	r.runeOff += utf8.RuneCountInString(r.text[r.byteOff:off])
+	r.byteOff = off
+	return r.runeOff
+}
+
+// contentSHA256 returns the hex SHA-256 of a chunk's text.
+func contentSHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullPositiveInt(n int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(n), Valid: n > 0}
 }
 
 // calculateSHA256 computes the SHA256 hash of a file.
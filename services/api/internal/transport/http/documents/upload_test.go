@@ -0,0 +1,95 @@
+package documents
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"docsense/api/internal/ingest/chunk"
+	"docsense/api/internal/ingest/tokenize"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/google/uuid"
+)
+
+// TestRuneOffsets checks that the stored character offsets of chunks cut
+// from multibyte text select exactly the chunk in the document text, which
+// is how Postgres substr reads them back.
+func TestRuneOffsets(t *testing.T) {
+	text := strings.Repeat("Grüße aus Köln — “zitiert”. 日本語の文です。 Plain ASCII words follow here.\n\n", 6)
+	c, err := chunk.New(chunk.Options{Strategy: chunk.StrategyRecursive, Size: 12, Overlap: 4}, tokenize.Words{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := c.Chunk(uuid.Nil, text, []chunk.Segment{{Start: 0, End: len(text)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runes := []rune(text)
+	starts, ends := runeOffsets{text: text}, runeOffsets{text: text}
+	for i, ch := range chunks {
+		s, e := starts.at(ch.StartOffset), ends.at(ch.EndOffset)
+		if got := string(runes[s:e]); got != ch.Content {
+			t.Errorf("chunk %d: characters [%d, %d) = %q, want %q", i, s, e, got, ch.Content)
+		}
+	}
+
+	// Going backwards restarts the count instead of going wrong.
+	if got, want := starts.at(len("Grüße")), len([]rune("Grüße")); got != want {
+		t.Errorf("at after rewind = %d, want %d", got, want)
+	}
+}
+
+func TestPreviousChunksTake(t *testing.T) {
+	same := contentSHA256("repeated paragraph")
+	if want := "bb1de3f210a3e99520d8eea5521af070f0df72213a59ce447f95a31a64b7f6e9"; same != want {
+		t.Fatalf("contentSHA256 = %q, want %q", same, want)
+	}
+	previous := previousChunks{byHash: map[string][]previousChunk{
+		same: {{id: "first"}, {id: "second"}},
+	}}
+	// Identical chunks reuse the previous ones in document order, each once.
+	for _, want := range []string{"first", "second"} {
+		got, ok := previous.take(same)
+		if !ok || got.id != want {
+			t.Fatalf("take = %q, %v; want %q", got.id, ok, want)
+		}
+	}
+	if got, ok := previous.take(same); ok {
+		t.Errorf("take reused %q twice", got.id)
+	}
+	if _, ok := previous.take(contentSHA256("new text")); ok {
+		t.Error("take matched a chunk that changed")
+	}
+}
+
+// TestUploadMalformedDocumentID checks that a new version of a document that
+// cannot exist is rejected before the database is queried (the handler has
+// none here).
+func TestUploadMalformedDocumentID(t *testing.T) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	fw, err := w.CreateFormFile("file", "notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("new version"))
+	w.WriteField("document_id", "not-a-uuid")
+	w.Close()
+
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/documents/upload", &body)
+	c.Request.Header.Set("Content-Type", w.FormDataContentType())
+	c.Set("user_id", "00000000-0000-0000-0000-000000000001")
+
+	h := &Handler{maxUploadBytes: 1 << 20, chunking: chunk.Options{Strategy: "fixed", Size: 100}, tokenizer: tokenize.Words{}}
+	h.Upload(c)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusNotFound, rec.Body)
+	}
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorBodyWriter adds fields (the request ID and trace ID) to JSON error
+// responses, so handlers need not thread them into every gin.H.
+//
+// A JSON body written with a status of 400 or more is held back until
+// flush, which adds the fields to it if it is an object. Other responses,
+// including streams, pass straight through.
+type errorBodyWriter struct {
+	gin.ResponseWriter
+	fields func() map[string]any
+
+	buf      bytes.Buffer
+	buffered bool
+}
+
+func (w *errorBodyWriter) holdBack() bool {
+	if w.buffered {
+		return true
+	}
+	if w.ResponseWriter.Written() || w.Status() < http.StatusBadRequest {
+		return false
+	}
+	ct := w.Header().Get("Content-Type")
+	w.buffered = strings.HasPrefix(ct, "application/json")
+	return w.buffered
+}
+
+func (w *errorBodyWriter) Write(b []byte) (int, error) {
+	if w.holdBack() {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *errorBodyWriter) WriteString(s string) (int, error) {
+	if w.holdBack() {
+		return w.buf.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *errorBodyWriter) Written() bool {
+	return w.buffered || w.ResponseWriter.Written()
+}
+
+func (w *errorBodyWriter) Size() int {
+	if w.buffered {
+		return w.buf.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *errorBodyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// flush writes a held back body, with the fields added.
+func (w *errorBodyWriter) flush() {
+	if !w.buffered {
+		return
+	}
+	w.buffered = false
+	body := w.buf.Bytes()
+
+	var obj map[string]any
+	if err := json.Unmarshal(body, &obj); err == nil && obj != nil {
+		for k, v := range w.fields() {
+			if _, ok := obj[k]; !ok {
+				obj[k] = v
+			}
+		}
+		if b, err := json.Marshal(obj); err == nil {
+			body = b
+		}
+	}
+	w.Header().Del("Content-Length")
+	if _, err := w.ResponseWriter.Write(body); err != nil {
+		log.Printf("warning: write error response: %v", err)
+	}
+}
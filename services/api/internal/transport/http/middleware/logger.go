@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Logger is gin's access log with the request ID (set by RequestID) after
+// the timestamp, so API log lines can be matched with the RAG service's.
+func Logger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(p gin.LogFormatterParams) string {
+		var statusColor, methodColor, resetColor string
+		if p.IsOutputColor() {
+			statusColor = p.StatusCodeColor()
+			methodColor = p.MethodColor()
+			resetColor = p.ResetColor()
+		}
+		if p.Latency > time.Minute {
+			p.Latency = p.Latency.Truncate(time.Second)
+		}
+		id, _ := p.Keys[requestIDKey].(string)
+		if id == "" {
+			id = "-"
+		}
+		return fmt.Sprintf("[GIN] %v | %s |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			p.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			id,
+			statusColor, p.StatusCode, resetColor,
+			p.Latency,
+			p.ClientIP,
+			methodColor, p.Method, resetColor,
+			p.Path,
+			p.ErrorMessage,
+		)
+	})
+}
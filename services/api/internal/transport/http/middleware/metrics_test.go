@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"docsense/api/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Metrics())
+	r.GET("/api/documents/:id", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+
+	counter := func(route, status string) float64 {
+		return testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(http.MethodGet, route, status))
+	}
+	before, beforeUnmatched := counter("/api/documents/:id", "404"), counter("unmatched", "404")
+	for _, path := range []string{"/api/documents/1", "/api/documents/2", "/no/such/path"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if got := counter("/api/documents/:id", "404") - before; got != 2 {
+		t.Errorf("requests by route = %v, want 2", got)
+	}
+	if got := counter("unmatched", "404") - beforeUnmatched; got != 1 {
+		t.Errorf("unmatched requests = %v, want 1", got)
+	}
+}
+
+func TestAdminToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/metrics", AdminToken("s3cret"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name, header string
+		want         int
+	}{
+		{"valid token", "Bearer s3cret", http.StatusOK},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"no scheme", "s3cret", http.StatusUnauthorized},
+		{"missing", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
@@ -1,32 +1,56 @@
 package middleware
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"regexp"
+
+	"docsense/api/internal/requestctx"
+
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const requestIDHeader = "X-Request-Id"
+const requestIDKey = "request_id"
+
+// validRequestID bounds client-supplied request IDs, which end up in logs
+// and outgoing headers.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
 
 // RequestID ensures every request has a stable correlation ID.
 //
-// This is intentionally minimal scaffolding; real deployments often integrate
-// structured logging and distributed tracing.
+// The ID is taken from the X-Request-Id header when it looks sane, or
+// generated. It is stored in the request's context.Context (see package
+// requestctx), which outgoing RAG calls forward, and in the gin context
+// for GetRequestID; it is echoed in the X-Request-Id response header,
+// recorded on the request's span (see Tracing) and added to JSON error
+// bodies with the span's trace ID.
 func RequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Request.Header.Get(requestIDHeader)
-		if id == "" {
-			// Gin does not generate request IDs by default.
-			// Keep this dependency-free: generate a random 16-byte hex ID.
-			b := make([]byte, 16)
-			if _, err := rand.Read(b); err == nil {
-				id = hex.EncodeToString(b)
-			} else {
-				// Last-resort fallback.
-				id = "unknown"
-			}
+		id := c.Request.Header.Get(requestctx.RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = requestctx.NewRequestID()
 		}
-		c.Writer.Header().Set(requestIDHeader, id)
+		ctx := requestctx.WithRequestID(c.Request.Context(), id)
+		span := trace.SpanFromContext(ctx)
+		span.SetAttributes(attribute.String("request_id", id))
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(requestIDKey, id)
+		c.Writer.Header().Set(requestctx.RequestIDHeader, id)
+
+		w := &errorBodyWriter{ResponseWriter: c.Writer, fields: func() map[string]any {
+			fields := map[string]any{"request_id": id}
+			if sc := span.SpanContext(); sc.HasTraceID() {
+				fields["trace_id"] = sc.TraceID().String()
+			}
+			return fields
+		}}
+		c.Writer = w
 		c.Next()
+		w.flush()
 	}
 }
+
+// GetRequestID returns the request ID stored by RequestID.
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
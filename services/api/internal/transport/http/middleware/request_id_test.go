@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"docsense/api/internal/requestctx"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func newRouter(handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	// As tracing.Setup does; without a tracer provider, spans continue the
+	// incoming trace but start none.
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	r := gin.New()
+	r.Use(Tracing(), RequestID())
+	r.GET("/", handler)
+	return r
+}
+
+func serve(r *gin.Engine, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"taken from the request", "abc-123", true},
+		{"generated when missing", "", false},
+		{"generated when invalid", "bad id\nwith newline", false},
+		{"generated when too long", strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen, ctxID string
+			r := newRouter(func(c *gin.Context) {
+				seen = GetRequestID(c)
+				ctxID = requestctx.RequestID(c.Request.Context())
+				c.Status(http.StatusNoContent)
+			})
+			h := http.Header{}
+			if tt.header != "" {
+				h.Set(requestctx.RequestIDHeader, tt.header)
+			}
+			rec := serve(r, h)
+
+			got := rec.Header().Get(requestctx.RequestIDHeader)
+			if tt.keep && got != tt.header {
+				t.Errorf("request ID = %q, want %q", got, tt.header)
+			}
+			if !tt.keep && (got == tt.header || len(got) != 32) {
+				t.Errorf("request ID = %q, want a generated one", got)
+			}
+			if seen != got || ctxID != got {
+				t.Errorf("handler saw %q (gin) and %q (context), response has %q", seen, ctxID, got)
+			}
+		})
+	}
+}
+
+func TestTracingContinuesTrace(t *testing.T) {
+	tests := []struct {
+		name, header string
+		want         string
+	}{
+		{"valid", testTraceparent, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"invalid", "00-xyz-00f067aa0ba902b7-01", ""},
+		{"missing", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sc trace.SpanContext
+			r := newRouter(func(c *gin.Context) {
+				sc = trace.SpanContextFromContext(c.Request.Context())
+			})
+			h := http.Header{}
+			if tt.header != "" {
+				h.Set("traceparent", tt.header)
+			}
+			serve(r, h)
+			got := ""
+			if sc.HasTraceID() {
+				got = sc.TraceID().String()
+			}
+			if got != tt.want {
+				t.Errorf("trace ID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		want    string
+	}{
+		{
+			name:    "json error gets the IDs",
+			handler: func(c *gin.Context) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}) },
+			want:    `{"error":"not found","request_id":"req-1","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"}`,
+		},
+		{
+			name:    "fields set by the handler are kept",
+			handler: func(c *gin.Context) { c.JSON(http.StatusBadRequest, gin.H{"error": "bad", "request_id": "own"}) },
+			want:    `{"error":"bad","request_id":"own","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"}`,
+		},
+		{
+			name:    "success is untouched",
+			handler: func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) },
+			want:    `{"ok":true}`,
+		},
+		{
+			name:    "json array is untouched",
+			handler: func(c *gin.Context) { c.JSON(http.StatusConflict, []string{"a"}) },
+			want:    `["a"]`,
+		},
+		{
+			name:    "plain text error is untouched",
+			handler: func(c *gin.Context) { c.String(http.StatusInternalServerError, "boom") },
+			want:    "boom",
+		},
+		{
+			name: "streamed event is untouched",
+			handler: func(c *gin.Context) {
+				c.Header("Content-Type", "text/event-stream")
+				c.SSEvent("error", gin.H{"error": "failed"})
+			},
+			want: "event:error\ndata:{\"error\":\"failed\"}\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			h.Set(requestctx.RequestIDHeader, "req-1")
+			h.Set("traceparent", testTraceparent)
+			rec := serve(newRouter(tt.handler), h)
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorBodyWithoutTrace(t *testing.T) {
+	rec := serve(newRouter(func(c *gin.Context) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "no"})
+	}), http.Header{})
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body %q: %v", rec.Body.String(), err)
+	}
+	if body["request_id"] != rec.Header().Get(requestctx.RequestIDHeader) {
+		t.Errorf("request_id = %v, want %q", body["request_id"], rec.Header().Get(requestctx.RequestIDHeader))
+	}
+	if _, ok := body["trace_id"]; ok {
+		t.Errorf("trace_id set without a trace: %v", body)
+	}
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// Tracing starts an OpenTelemetry server span for each request, named after
+// its route, in the caller's trace (an incoming traceparent) or a new one.
+// Handlers add to it with trace.SpanFromContext(c.Request.Context()).
+//
+// It goes before RequestID, which tags the span with the request ID and
+// reads its trace ID for error bodies.
+func Tracing() gin.HandlerFunc {
+	return otelgin.Middleware("")
+}
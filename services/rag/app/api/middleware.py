@@ -0,0 +1,55 @@
+"""ASGI middleware for request correlation.
+
+The API forwards its request ID (X-Request-Id) and W3C trace context
+(traceparent) on every call. They are stored in context variables for the
+duration of the request, so log lines carry the same ID as the API's, and
+the request ID is echoed on the response.
+"""
+
+from __future__ import annotations
+
+import re
+import uuid
+
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from app.core.logger import request_id_ctx, traceparent_ctx
+
+REQUEST_ID_HEADER = "x-request-id"
+TRACEPARENT_HEADER = "traceparent"
+
+_VALID_REQUEST_ID = re.compile(r"^[A-Za-z0-9._:-]{1,128}$")
+_VALID_TRACEPARENT = re.compile(r"^[0-9a-f]{2}-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}(-.*)?$")
+
+
+class RequestContextMiddleware:
+    """Binds the request ID and traceparent of each HTTP request."""
+
+    def __init__(self, app: ASGIApp):
+        self.app = app
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send) -> None:
+        if scope["type"] != "http":
+            await self.app(scope, receive, send)
+            return
+
+        headers = {k.decode("latin-1").lower(): v.decode("latin-1") for k, v in scope.get("headers", [])}
+        request_id = headers.get(REQUEST_ID_HEADER, "")
+        if not _VALID_REQUEST_ID.match(request_id):
+            request_id = uuid.uuid4().hex
+        traceparent = headers.get(TRACEPARENT_HEADER, "").strip().lower()
+        if not _VALID_TRACEPARENT.match(traceparent):
+            traceparent = None
+
+        async def send_with_request_id(message: Message) -> None:
+            if message["type"] == "http.response.start":
+                message["headers"] = [*message.get("headers", []), (b"x-request-id", request_id.encode())]
+            await send(message)
+
+        request_token = request_id_ctx.set(request_id)
+        trace_token = traceparent_ctx.set(traceparent)
+        try:
+            await self.app(scope, receive, send_with_request_id)
+        finally:
+            request_id_ctx.reset(request_token)
+            traceparent_ctx.reset(trace_token)
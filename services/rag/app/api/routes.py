@@ -1,21 +1,76 @@
 from __future__ import annotations
 
-from fastapi import APIRouter
+import json
+from collections.abc import Iterator
+
+from fastapi import APIRouter, Depends, HTTPException, Query, Request
+from fastapi.responses import StreamingResponse
 
 from app.api.schemas import (
     Citation,
+    DeletePointsRequest,
+    DeletePointsResponse,
     EmbedRequest,
     EmbedResponse,
+    GenerateRequest,
+    GenerateResponse,
+    PointOut,
+    PointsResponse,
     QueryRequest,
     QueryResponse,
     RetrievedChunkOut,
+    RetrieveResponse,
+    UpdatePayloadsResponse,
 )
+from app.core.logger import get_logger
 from app.core.settings import settings
+from app.core.signing import SignatureError, SignatureVerifier, load_verifier
 from app.embeddings.sentence_embedder import SentenceEmbedder
+from app.generator.llm_generator import Citation as GeneratedCitation
 from app.generator.llm_generator import LLMGenerator
-from app.retriever.qdrant_retriever import QdrantRetriever
+from app.retriever.qdrant_retriever import QdrantRetriever, RetrievedChunk
+
+logger = get_logger(__name__)
+
+_verifier: SignatureVerifier | None = None
+_verifier_loaded = False
+
+
+def load_signing() -> None:
+    """Load the request signature verifier; called when the service starts.
+
+    Raises ValueError for malformed keys, or for missing ones unless
+    RAG_ALLOW_UNSIGNED is set, so a misconfigured deploy fails to start.
+    """
+    global _verifier, _verifier_loaded
+    _verifier = load_verifier(
+        settings.rag_signing_keys,
+        allow_unsigned=settings.rag_allow_unsigned,
+        max_skew=settings.rag_signature_max_skew,
+    )
+    if _verifier is None:
+        logger.warning("RAG_ALLOW_UNSIGNED is set; requests are not authenticated")
+    _verifier_loaded = True
+
 
-router = APIRouter()
+async def verify_signature(request: Request) -> None:
+    """Reject requests not signed by the API (see app.core.signing)."""
+    if not _verifier_loaded:
+        # Fail closed if the service was started without load_signing.
+        raise HTTPException(status_code=503, detail="request signing is not configured")
+    if _verifier is None:
+        return
+    path = request.scope.get("raw_path", request.url.path.encode()).decode()
+    if request.url.query:
+        path += "?" + request.url.query
+    try:
+        _verifier.verify(request.method, path, request.headers, await request.body())
+    except SignatureError as e:
+        logger.warning("rejected %s %s: %s", request.method, request.url.path, e)
+        raise HTTPException(status_code=401, detail=f"invalid request signature: {e}") from None
+
+
+router = APIRouter(dependencies=[Depends(verify_signature)])
 
 # Initialize embedder once (lazy-loaded)
 _embedder: SentenceEmbedder | None = None
@@ -34,12 +89,45 @@ def embed(req: EmbedRequest) -> EmbedResponse:
     embedder = get_embedder()
     retriever = QdrantRetriever(embedder)
 
-    upserted = retriever.upsert_chunks(
+    point_ids = retriever.upsert_chunks(
+        document_id=req.document_id,
+        chunks=[(c.chunk_id, c.chunk_index, c.text) for c in req.chunks],
+        extra_payloads=_extra_payloads(req),
+    )
+
+    return EmbedResponse(upserted=len(point_ids), point_ids=point_ids)
+
+
+@router.post("/points/payload", response_model=UpdatePayloadsResponse)
+def update_payloads(req: EmbedRequest) -> UpdatePayloadsResponse:
+    """Refresh the payloads of already embedded chunks without re-embedding."""
+    retriever = QdrantRetriever(get_embedder())
+    updated = retriever.update_payloads(
         document_id=req.document_id,
         chunks=[(c.chunk_id, c.chunk_index, c.text) for c in req.chunks],
+        extra_payloads=_extra_payloads(req),
+    )
+
+    return UpdatePayloadsResponse(updated=updated)
+
+
+@router.get("/points", response_model=PointsResponse)
+def list_points(offset: str | None = None, limit: int = Query(256, ge=1, le=1000)) -> PointsResponse:
+    """Page through indexed points, for reconciliation with the chunk store."""
+    retriever = QdrantRetriever(get_embedder())
+    points, next_offset = retriever.list_points(offset, limit)
+
+    return PointsResponse(
+        points=[PointOut(id=point_id, document_id=document_id) for point_id, document_id in points],
+        next_offset=next_offset,
     )
 
-    return EmbedResponse(upserted=upserted)
+
+@router.post("/points/delete", response_model=DeletePointsResponse)
+def delete_points(req: DeletePointsRequest) -> DeletePointsResponse:
+    """Delete points whose chunks no longer exist."""
+    retriever = QdrantRetriever(get_embedder())
+    return DeletePointsResponse(deleted=retriever.delete_points(req.point_ids))
 
 
 @router.post("/query", response_model=QueryResponse)
@@ -48,24 +136,120 @@ def query(req: QueryRequest) -> QueryResponse:
     retriever = QdrantRetriever(embedder)
     generator = LLMGenerator()
 
-    matches = retriever.query(req.query, top_k=req.top_k)
+    matches = retriever.query(req.query, top_k=req.top_k, user_id=req.user_id, document_ids=req.document_ids)
     answer = generator.generate(req.query, matches)
 
-    # Convert citations to schema format
-    citation_schemas = [
+    return QueryResponse(
+        answer=answer.answer,
+        citations=_citation_schemas(answer.citations),
+        matches=[
+            RetrievedChunkOut(id=m.id, score=m.score, document_id=m.document_id, text=m.text) for m in matches
+        ],
+    )
+
+
+@router.post("/retrieve", response_model=RetrieveResponse)
+def retrieve(req: QueryRequest) -> RetrieveResponse:
+    """Retrieval only, for callers that expand matches before /generate."""
+    retriever = QdrantRetriever(get_embedder())
+    matches = retriever.query(req.query, top_k=req.top_k, user_id=req.user_id, document_ids=req.document_ids)
+
+    return RetrieveResponse(
+        matches=[
+            RetrievedChunkOut(
+                id=m.id,
+                score=m.score,
+                document_id=m.document_id,
+                text=m.text,
+                chunk_index=m.chunk_index,
+                segment_kind=m.segment_kind,
+                segment_start=m.segment_start,
+                segment_end=m.segment_end,
+                segment_title=m.segment_title,
+                section_path=list(m.section_path) if m.section_path else None,
+            )
+            for m in matches
+        ]
+    )
+
+
+@router.post("/generate", response_model=GenerateResponse)
+def generate(req: GenerateRequest) -> GenerateResponse:
+    """Answer from caller-supplied passages (see /retrieve)."""
+    generator = LLMGenerator()
+    answer = generator.generate(req.query, _context_chunks(req))
+
+    return GenerateResponse(answer=answer.answer, citations=_citation_schemas(answer.citations))
+
+
+@router.post("/generate/stream")
+def generate_stream(req: GenerateRequest) -> StreamingResponse:
+    """Like /generate, streamed as NDJSON: a {"delta": ...} line per piece of
+    the answer, then {"citations": [...]}, or {"error": ...} if generation
+    fails midway. Failures before the first piece are plain error responses."""
+    generator = LLMGenerator()
+    deltas, citations = generator.generate_stream(req.query, _context_chunks(req))
+
+    def lines() -> Iterator[str]:
+        try:
+            for delta in deltas:
+                yield json.dumps({"delta": delta}) + "\n"
+        except Exception as e:  # noqa: BLE001 - reported to the client in-band
+            yield json.dumps({"error": str(e)}) + "\n"
+            return
+        out = [c.model_dump() for c in _citation_schemas(citations)]
+        yield json.dumps({"citations": out}) + "\n"
+
+    return StreamingResponse(lines(), media_type="application/x-ndjson")
+
+
+def _context_chunks(req: GenerateRequest) -> list[RetrievedChunk]:
+    """The request's passages as retrieved chunks for the generator."""
+    return [
+        RetrievedChunk(
+            id=c.chunk_id,
+            score=c.score,
+            document_id=c.document_id,
+            text=c.text,
+            chunk_index=c.chunk_index,
+            segment_kind=c.segment_kind,
+            segment_start=c.segment_start,
+            segment_end=c.segment_end,
+            segment_title=c.segment_title,
+            section_path=tuple(c.section_path) if c.section_path else None,
+        )
+        for c in req.contexts
+    ]
+
+
+def _extra_payloads(req: EmbedRequest) -> list[dict]:
+    """Per-chunk payload fields besides document, index and text."""
+    return [
+        {
+            "user_id": req.user_id,
+            "segment_kind": c.segment_kind,
+            "segment_start": c.segment_start,
+            "segment_end": c.segment_end,
+            "segment_title": c.segment_title,
+            "section_path": c.section_path,
+        }
+        for c in req.chunks
+    ]
+
+
+def _citation_schemas(citations: list[GeneratedCitation]) -> list[Citation]:
+    """Convert generator citations to the response schema."""
+    return [
         Citation(
             chunk_id=c.chunk_id,
             document_id=c.document_id,
             chunk_index=c.chunk_index,
             text_snippet=c.text_snippet,
+            segment_kind=c.segment_kind,
+            segment_start=c.segment_start,
+            segment_end=c.segment_end,
+            segment_title=c.segment_title,
+            section_path=list(c.section_path) if c.section_path else None,
         )
-        for c in answer.citations
+        for c in citations
     ]
-
-    return QueryResponse(
-        answer=answer.answer,
-        citations=citation_schemas,
-        matches=[
-            RetrievedChunkOut(id=m.id, score=m.score, document_id=m.document_id, text=m.text) for m in matches
-        ],
-    )
@@ -7,20 +7,61 @@ class ChunkIn(BaseModel):
     chunk_id: str = Field(..., min_length=1)
     chunk_index: int = Field(..., ge=0)
     text: str = Field(..., min_length=1)
+    # Structural span, e.g. slides 3-4 (absent for unstructured documents).
+    segment_kind: str | None = None
+    segment_start: int | None = Field(None, ge=1)
+    segment_end: int | None = Field(None, ge=1)
+    segment_title: str | None = None
+    # Heading breadcrumb of the chunk's section, outermost first.
+    section_path: list[str] | None = None
 
 
 class EmbedRequest(BaseModel):
     document_id: str = Field(..., min_length=1)
+    # Owner of the document, stored with its chunks so retrieval can be
+    # restricted to one user's documents.
+    user_id: str | None = None
     chunks: list[ChunkIn]
 
 
 class EmbedResponse(BaseModel):
     upserted: int
+    # IDs of the upserted points (the chunk IDs), so callers can record which
+    # chunks are indexed.
+    point_ids: list[str] = []
+
+
+class UpdatePayloadsResponse(BaseModel):
+    updated: int
+
+
+class PointOut(BaseModel):
+    id: str
+    document_id: str | None
+
+
+class PointsResponse(BaseModel):
+    points: list[PointOut]
+    # Pass as offset to fetch the next page; null after the last page.
+    next_offset: str | None
+
+
+class DeletePointsRequest(BaseModel):
+    point_ids: list[str] = Field(..., min_length=1, max_length=1000)
+
+
+class DeletePointsResponse(BaseModel):
+    deleted: int
 
 
 class QueryRequest(BaseModel):
     query: str = Field(..., min_length=1)
     top_k: int = Field(5, ge=1, le=50)
+    # Restrict retrieval to a user's documents and, if set, to these
+    # documents. Filtering happens in the search, so top_k matches are
+    # returned whenever that many pass the filter.
+    user_id: str | None = None
+    document_ids: list[str] | None = Field(None, max_length=100)
 
 
 class RetrievedChunkOut(BaseModel):
@@ -28,6 +69,38 @@ class RetrievedChunkOut(BaseModel):
     score: float
     document_id: str | None
     text: str | None
+    chunk_index: int | None = None
+    segment_kind: str | None = None
+    segment_start: int | None = None
+    segment_end: int | None = None
+    segment_title: str | None = None
+    section_path: list[str] | None = None
+
+
+class RetrieveResponse(BaseModel):
+    matches: list[RetrievedChunkOut]
+
+
+class ContextChunkIn(BaseModel):
+    """A passage to answer from: a retrieved chunk, possibly expanded by the
+    caller to its parent section or neighbouring chunks. Citations point at
+    chunk_id."""
+
+    chunk_id: str = Field(..., min_length=1)
+    text: str = Field(..., min_length=1)
+    score: float = 0.0
+    document_id: str | None = None
+    chunk_index: int | None = None
+    segment_kind: str | None = None
+    segment_start: int | None = None
+    segment_end: int | None = None
+    segment_title: str | None = None
+    section_path: list[str] | None = None
+
+
+class GenerateRequest(BaseModel):
+    query: str = Field(..., min_length=1)
+    contexts: list[ContextChunkIn]
 
 
 class Citation(BaseModel):
@@ -35,9 +108,19 @@ class Citation(BaseModel):
     document_id: str | None
     chunk_index: int | None
     text_snippet: str | None
+    segment_kind: str | None = None
+    segment_start: int | None = None
+    segment_end: int | None = None
+    segment_title: str | None = None
+    section_path: list[str] | None = None
 
 
 class QueryResponse(BaseModel):
     answer: str
     citations: list[Citation] = []
     matches: list[RetrievedChunkOut]
+
+
+class GenerateResponse(BaseModel):
+    answer: str
+    citations: list[Citation] = []
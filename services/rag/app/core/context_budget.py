@@ -71,6 +71,11 @@ class ContextBudget:
                             document_id=chunk.document_id,
                             text=trimmed_text,
                             chunk_index=chunk.chunk_index,
+                            segment_kind=chunk.segment_kind,
+                            segment_start=chunk.segment_start,
+                            segment_end=chunk.segment_end,
+                            segment_title=chunk.segment_title,
+                            section_path=chunk.section_path,
                         )
                         selected.append(trimmed_chunk)
                 break
@@ -99,7 +104,9 @@ class ContextBudget:
     def build_context_string(self, chunks: list[RetrievedChunk]) -> str:
         """Build context string from selected chunks.
 
-        Formats chunks with clear separators for LLM parsing.
+        Formats chunks with clear separators for LLM parsing. A chunk's
+        section breadcrumb, when known, is shown in its header so answers can
+        name the section they draw on.
         """
         if not chunks:
             return ""
@@ -107,5 +114,8 @@ class ContextBudget:
         parts = []
         for i, chunk in enumerate(chunks, 1):
             if chunk.text:
-                parts.append(f"[Chunk {i}]\n{chunk.text}\n")
+                header = f"[Chunk {i}]"
+                if chunk.section_path:
+                    header += f" (section: {' > '.join(chunk.section_path)})"
+                parts.append(f"{header}\n{chunk.text}\n")
         return "\n".join(parts)
@@ -8,6 +8,9 @@ from typing import Any
 # Context variable for request ID (for correlation)
 request_id_ctx: ContextVar[str | None] = ContextVar("request_id", default=None)
 
+# Context variable for the W3C traceparent of the current request
+traceparent_ctx: ContextVar[str | None] = ContextVar("traceparent", default=None)
+
 
 class ContextLogger(logging.LoggerAdapter):
     """Logger adapter that adds request ID to log records."""
@@ -51,3 +54,13 @@ def set_request_id(request_id: str) -> None:
 def get_request_id() -> str | None:
     """Get current request ID from context."""
     return request_id_ctx.get()
+
+
+def set_traceparent(traceparent: str | None) -> None:
+    """Set the W3C traceparent of the current request."""
+    traceparent_ctx.set(traceparent)
+
+
+def get_traceparent() -> str | None:
+    """Get the W3C traceparent of the current request."""
+    return traceparent_ctx.get()
@@ -13,6 +13,13 @@ class Settings(BaseSettings):
     qdrant_collection: str = "docsense_chunks"
     qdrant_vector_size: int = 384
 
+    # Request signing: "id:secret" keys, comma-separated, that API requests
+    # may be signed with (several during a rotation). The service refuses to
+    # start without keys unless unsigned requests are explicitly allowed.
+    rag_signing_keys: str = ""
+    rag_allow_unsigned: bool = False
+    rag_signature_max_skew: int = 300  # seconds
+
     # Embedding model settings
     embedding_model: str = "sentence-transformers/all-MiniLM-L6-v2"
 
@@ -0,0 +1,110 @@
+"""HMAC request signing between the API and the RAG service.
+
+The API signs each request with a shared secret:
+
+    X-Signature-Key-Id:    which key signed (keys are rotatable)
+    X-Signature-Timestamp: Unix seconds
+    X-Signature-Nonce:     random, unique per request
+    X-Signature:           hex HMAC-SHA256 of the canonical request
+
+The canonical request is the method, the path with its query string, the
+timestamp, the nonce and the hex SHA-256 of the body, joined by newlines.
+Requests older or newer than the allowed clock skew are rejected, and
+nonces are remembered for that long to reject replays.
+"""
+
+from __future__ import annotations
+
+import hashlib
+import hmac
+import threading
+import time
+from collections.abc import Callable, Mapping
+
+KEY_ID_HEADER = "X-Signature-Key-Id"
+TIMESTAMP_HEADER = "X-Signature-Timestamp"
+NONCE_HEADER = "X-Signature-Nonce"
+SIGNATURE_HEADER = "X-Signature"
+
+
+class SignatureError(Exception):
+    """A request whose signature is missing or does not verify."""
+
+
+def parse_keys(spec: str) -> dict[str, bytes]:
+    """Parse "id:secret,id:secret" into secrets by key ID."""
+    keys: dict[str, bytes] = {}
+    for item in spec.split(","):
+        item = item.strip()
+        if not item:
+            continue
+        key_id, sep, secret = item.partition(":")
+        if not sep or not key_id or not secret:
+            raise ValueError(f"invalid signing key {key_id or item!r}: want id:secret")
+        keys[key_id] = secret.encode()
+    return keys
+
+
+def sign(secret: bytes, method: str, path: str, timestamp: str, nonce: str, body: bytes) -> str:
+    """Hex HMAC-SHA256 of the canonical request."""
+    canonical = "\n".join([method.upper(), path, timestamp, nonce, hashlib.sha256(body).hexdigest()])
+    return hmac.new(secret, canonical.encode(), hashlib.sha256).hexdigest()
+
+
+class SignatureVerifier:
+    """Verifies signed requests against a set of keys, rejecting replays."""
+
+    def __init__(self, keys: Mapping[str, bytes], max_skew: int = 300, clock: Callable[[], float] = time.time):
+        self._keys = dict(keys)
+        self._max_skew = max_skew
+        self._clock = clock
+        self._seen: dict[str, float] = {}  # nonce -> expiry
+        self._lock = threading.Lock()
+
+    def verify(self, method: str, path: str, headers: Mapping[str, str], body: bytes) -> str:
+        """Verify a request and return the ID of the key that signed it."""
+        key_id = headers.get(KEY_ID_HEADER)
+        timestamp = headers.get(TIMESTAMP_HEADER)
+        nonce = headers.get(NONCE_HEADER)
+        signature = headers.get(SIGNATURE_HEADER)
+        if not (key_id and timestamp and nonce and signature):
+            raise SignatureError("missing signature headers")
+
+        secret = self._keys.get(key_id)
+        if secret is None:
+            raise SignatureError(f"unknown key {key_id!r}")
+        try:
+            ts = int(timestamp)
+        except ValueError:
+            raise SignatureError("invalid timestamp") from None
+        now = self._clock()
+        if abs(now - ts) > self._max_skew:
+            raise SignatureError("timestamp outside allowed clock skew")
+        if not hmac.compare_digest(sign(secret, method, path, timestamp, nonce, body), signature):
+            raise SignatureError("signature mismatch")
+
+        # Past ts + max_skew the timestamp check rejects the request, so a
+        # nonce only needs remembering until then. Nonces are kept per
+        # process.
+        with self._lock:
+            if len(self._seen) > 10_000:
+                self._seen = {n: exp for n, exp in self._seen.items() if exp > now}
+            if self._seen.get(nonce, 0) >= now:
+                raise SignatureError("replayed request")
+            self._seen[nonce] = ts + self._max_skew
+        return key_id
+
+
+def load_verifier(spec: str, allow_unsigned: bool = False, max_skew: int = 300) -> SignatureVerifier | None:
+    """Build the verifier for RAG_SIGNING_KEYS when the service starts.
+
+    Malformed keys raise ValueError, failing startup rather than every
+    request. Without keys, unsigned requests are only accepted when
+    explicitly allowed (RAG_ALLOW_UNSIGNED); the result is then None.
+    """
+    keys = parse_keys(spec)
+    if keys:
+        return SignatureVerifier(keys, max_skew=max_skew)
+    if not allow_unsigned:
+        raise ValueError("RAG_SIGNING_KEYS is not set; set it, or RAG_ALLOW_UNSIGNED=true to accept unsigned requests")
+    return None
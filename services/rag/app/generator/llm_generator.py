@@ -1,5 +1,6 @@
 from __future__ import annotations
 
+from collections.abc import Iterator
 from dataclasses import dataclass
 from typing import Protocol
 
@@ -15,6 +16,11 @@ class Citation:
     document_id: str | None
     chunk_index: int | None
     text_snippet: str | None
+    segment_kind: str | None = None
+    segment_start: int | None = None
+    segment_end: int | None = None
+    segment_title: str | None = None
+    section_path: tuple[str, ...] | None = None
 
 
 @dataclass(frozen=True)
@@ -29,6 +35,13 @@ class LLMProvider(Protocol):
     def generate(self, system_prompt: str, user_prompt: str, max_tokens: int) -> str:
         raise NotImplementedError
 
+    def stream(self, system_prompt: str, user_prompt: str, max_tokens: int) -> Iterator[str]:
+        """Like generate, yielding the answer in pieces as they are produced."""
+        raise NotImplementedError
+
+
+NO_ANSWER = "I don't have sufficient information in my knowledge base to answer this question."
+
 
 class OpenAIProvider:
     """OpenAI-compatible LLM provider."""
@@ -63,6 +76,32 @@ class OpenAIProvider:
         )
         return response.choices[0].message.content or ""
 
+    def stream(self, system_prompt: str, user_prompt: str, max_tokens: int) -> Iterator[str]:
+        if self.client is None:
+            raise ValueError("OpenAI client not initialized")
+
+        # Send the request now, so that failures surface before streaming starts.
+        response = self.client.chat.completions.create(
+            model=self.model,
+            messages=[
+                {"role": "system", "content": system_prompt},
+                {"role": "user", "content": user_prompt},
+            ],
+            max_tokens=max_tokens,
+            temperature=0.0,
+            stream=True,
+        )
+
+        def deltas() -> Iterator[str]:
+            try:
+                for chunk in response:
+                    if chunk.choices and chunk.choices[0].delta.content:
+                        yield chunk.choices[0].delta.content
+            finally:
+                response.close()
+
+        return deltas()
+
 
 class LLMGenerator:
     """Production-grade answer generator with citations.
@@ -94,48 +133,56 @@ class LLMGenerator:
         - Answer text (grounded in context)
         - Citations mapping answer claims to source chunks
         """
-        if not context:
-            return GeneratedAnswer(
-                answer="I don't have sufficient information in my knowledge base to answer this question.",
-                citations=[],
-            )
+        selected_chunks = self._select(context)
+        if not selected_chunks:
+            return GeneratedAnswer(answer=NO_ANSWER, citations=[])
 
-        # Select chunks that fit within token budget
-        selected_chunks = self._context_budget.select_chunks(
-            context, max_chunks=settings.max_chunks
+        answer_text = self._provider.generate(
+            system_prompt=self._build_system_prompt(),
+            user_prompt=self._build_user_prompt(question, self._context_budget.build_context_string(selected_chunks)),
+            max_tokens=1000,
         )
+        return GeneratedAnswer(answer=answer_text, citations=self._citations(selected_chunks))
 
-        if not selected_chunks:
-            return GeneratedAnswer(
-                answer="I don't have sufficient information in my knowledge base to answer this question.",
-                citations=[],
-            )
+    def generate_stream(self, question: str, context: list[RetrievedChunk]) -> tuple[Iterator[str], list[Citation]]:
+        """Like generate, but returns the answer as an iterator of text pieces.
 
-        # Build context from selected chunks
-        context_text = self._context_budget.build_context_string(selected_chunks)
-        system_prompt = self._build_system_prompt()
-        user_prompt = self._build_user_prompt(question, context_text)
+        The LLM request is sent before returning, so provider errors are
+        raised here rather than while iterating.
+        """
+        selected_chunks = self._select(context)
+        if not selected_chunks:
+            return iter([NO_ANSWER]), []
 
-        # Generate answer
-        answer_text = self._provider.generate(
-            system_prompt=system_prompt,
-            user_prompt=user_prompt,
+        deltas = self._provider.stream(
+            system_prompt=self._build_system_prompt(),
+            user_prompt=self._build_user_prompt(question, self._context_budget.build_context_string(selected_chunks)),
             max_tokens=1000,
         )
+        return deltas, self._citations(selected_chunks)
 
-        # Extract citations from selected chunks
-        citations = [
+    def _select(self, context: list[RetrievedChunk]) -> list[RetrievedChunk]:
+        """Select the chunks that fit within the token budget."""
+        if not context:
+            return []
+        return self._context_budget.select_chunks(context, max_chunks=settings.max_chunks)
+
+    def _citations(self, chunks: list[RetrievedChunk]) -> list[Citation]:
+        return [
             Citation(
                 chunk_id=chunk.id,
                 document_id=chunk.document_id,
                 chunk_index=chunk.chunk_index,
                 text_snippet=chunk.text[:200] + "..." if chunk.text and len(chunk.text) > 200 else chunk.text,
+                segment_kind=chunk.segment_kind,
+                segment_start=chunk.segment_start,
+                segment_end=chunk.segment_end,
+                segment_title=chunk.segment_title,
+                section_path=chunk.section_path,
             )
-            for chunk in selected_chunks
+            for chunk in chunks
         ]
 
-        return GeneratedAnswer(answer=answer_text, citations=citations)
-
     def _build_system_prompt(self) -> str:
         return """You are a helpful assistant that answers questions based ONLY on the provided context.
 
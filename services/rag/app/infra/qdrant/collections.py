@@ -17,16 +17,21 @@ def ensure_collection() -> None:
         existing = client.get_collection(collection)
         # Check if vector size matches (for development, we allow recreation)
         # In production, you might want to handle migrations differently
-        return
     except Exception:
         # If it doesn't exist (or Qdrant isn't ready yet), attempt creation.
-        pass
+        # Get vector size from embedder
+        embedder = SentenceEmbedder()
+        vector_size = embedder.vector_size
 
-    # Get vector size from embedder
-    embedder = SentenceEmbedder()
-    vector_size = embedder.vector_size
+        client.create_collection(
+            collection_name=collection,
+            vectors_config=qm.VectorParams(size=vector_size, distance=qm.Distance.COSINE),
+        )
 
-    client.create_collection(
-        collection_name=collection,
-        vectors_config=qm.VectorParams(size=vector_size, distance=qm.Distance.COSINE),
-    )
+    # Retrieval filters on these; creating an existing index is a no-op.
+    for field in ("user_id", "document_id"):
+        client.create_payload_index(
+            collection_name=collection,
+            field_name=field,
+            field_schema=qm.PayloadSchemaType.KEYWORD,
+        )
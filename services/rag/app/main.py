@@ -2,7 +2,8 @@ from __future__ import annotations
 
 from fastapi import FastAPI
 
-from app.api.routes import router
+from app.api.middleware import RequestContextMiddleware
+from app.api.routes import load_signing, router
 from app.core.logger import setup_logging
 from app.core.settings import settings
 from app.infra.qdrant.collections import ensure_collection
@@ -11,10 +12,13 @@ from app.infra.qdrant.collections import ensure_collection
 setup_logging(level="INFO" if settings.rag_env == "production" else "DEBUG")
 
 app = FastAPI(title="DocSense RAG Service", version="0.1.0")
+app.add_middleware(RequestContextMiddleware)
 
 
 @app.on_event("startup")
 def on_startup() -> None:
+    # Fail the deploy, not each request, on missing or malformed keys.
+    load_signing()
     # Ensure Qdrant collection exists (idempotent).
     ensure_collection()
 
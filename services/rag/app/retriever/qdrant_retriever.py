@@ -15,6 +15,11 @@ class RetrievedChunk:
     document_id: str | None
     text: str | None
     chunk_index: int | None = None
+    segment_kind: str | None = None
+    segment_start: int | None = None
+    segment_end: int | None = None
+    segment_title: str | None = None
+    section_path: tuple[str, ...] | None = None
 
 
 class EmbedderInterface:
@@ -33,12 +38,21 @@ class QdrantRetriever:
         self._client = get_qdrant_client()
         self._embedder = embedder
 
-    def query(self, query_text: str, top_k: int) -> list[RetrievedChunk]:
+    def query(
+        self,
+        query_text: str,
+        top_k: int,
+        user_id: str | None = None,
+        document_ids: list[str] | None = None,
+    ) -> list[RetrievedChunk]:
+        """Return the top_k chunks nearest to query_text, restricted to a
+        user's chunks and to document_ids when given."""
         vector = self._embedder.embed_text(query_text)
 
         results = self._client.search(
             collection_name=settings.qdrant_collection,
             query_vector=vector,
+            query_filter=search_filter(user_id, document_ids),
             limit=top_k,
             with_payload=True,
         )
@@ -47,6 +61,7 @@ class QdrantRetriever:
         for p in results:
             payload = p.payload or {}
             chunk_index = payload.get("chunk_index")
+            section_path = payload.get("section_path")
             out.append(
                 RetrievedChunk(
                     id=str(p.id),
@@ -54,33 +69,123 @@ class QdrantRetriever:
                     document_id=payload.get("document_id"),
                     text=payload.get("text"),
                     chunk_index=int(chunk_index) if chunk_index is not None else None,
+                    segment_kind=payload.get("segment_kind"),
+                    segment_start=payload.get("segment_start"),
+                    segment_end=payload.get("segment_end"),
+                    segment_title=payload.get("segment_title"),
+                    section_path=tuple(section_path) if section_path else None,
                 )
             )
         return out
 
-    def upsert_chunks(self, document_id: str, chunks: list[tuple[str, int, str]]) -> int:
-        """Upsert chunk points into Qdrant.
-
-        chunks: list of (chunk_id, chunk_index, text)
+    def upsert_chunks(
+        self,
+        document_id: str,
+        chunks: list[tuple[str, int, str]],
+        extra_payloads: list[dict] | None = None,
+    ) -> list[str]:
+        """Upsert chunk points into Qdrant and return their IDs.
+
+        chunks: list of (chunk_id, chunk_index, text); the chunk ID is the
+        point ID
+        extra_payloads: optional per-chunk payload fields (e.g. segment span),
+        aligned with chunks
         """
         if not chunks:
-            return 0
+            return []
 
         vectors = self._embedder.embed_texts([c[2] for c in chunks])
+        extras = extra_payloads or [{} for _ in chunks]
 
         points: list[qm.PointStruct] = []
-        for (chunk_id, chunk_index, text), vector in zip(chunks, vectors, strict=True):
-            points.append(
-                qm.PointStruct(
-                    id=chunk_id,
-                    vector=vector,
-                    payload={
-                        "document_id": document_id,
-                        "chunk_index": chunk_index,
-                        "text": text,
-                    },
+        for (chunk_id, chunk_index, text), vector, extra in zip(chunks, vectors, extras, strict=True):
+            payload = _chunk_payload(document_id, chunk_index, text, extra)
+            points.append(qm.PointStruct(id=chunk_id, vector=vector, payload=payload))
+
+        self._client.upsert(collection_name=settings.qdrant_collection, points=points)
+        return [str(p.id) for p in points]
+
+    def update_payloads(
+        self,
+        document_id: str,
+        chunks: list[tuple[str, int, str]],
+        extra_payloads: list[dict] | None = None,
+    ) -> int:
+        """Replace the payloads of existing chunk points, keeping their vectors.
+
+        Used for chunks whose text is unchanged but whose position (index,
+        segment span) may have moved. Arguments are as for upsert_chunks.
+        """
+        if not chunks:
+            return 0
+
+        extras = extra_payloads or [{} for _ in chunks]
+        operations = [
+            qm.OverwritePayloadOperation(
+                overwrite_payload=qm.SetPayload(
+                    payload=_chunk_payload(document_id, chunk_index, text, extra),
+                    points=[chunk_id],
                 )
             )
+            for (chunk_id, chunk_index, text), extra in zip(chunks, extras, strict=True)
+        ]
+        self._client.batch_update_points(collection_name=settings.qdrant_collection, update_operations=operations)
+        return len(operations)
 
-        self._client.upsert(collection_name=settings.qdrant_collection, points=points)
-        return len(points)
+    def list_points(self, offset: str | None, limit: int) -> tuple[list[tuple[str, str | None]], str | None]:
+        """Page through all points as (point_id, document_id).
+
+        Returns the page and the offset of the next one (None after the last).
+        """
+        points, next_offset = self._client.scroll(
+            collection_name=settings.qdrant_collection,
+            offset=offset,
+            limit=limit,
+            with_payload=["document_id"],
+            with_vectors=False,
+        )
+        page = [(str(p.id), (p.payload or {}).get("document_id")) for p in points]
+        return page, str(next_offset) if next_offset is not None else None
+
+    def delete_points(self, point_ids: list[str]) -> int:
+        """Delete points by ID; IDs that do not exist are ignored."""
+        if not point_ids:
+            return 0
+        self._client.delete(
+            collection_name=settings.qdrant_collection,
+            points_selector=qm.PointIdsList(points=point_ids),
+        )
+        return len(point_ids)
+
+
+def search_filter(user_id: str | None, document_ids: list[str] | None) -> qm.Filter | None:
+    """Build the Qdrant filter for a search, or None to search everything.
+
+    Points embedded before owners were stored have no user_id and pass the
+    user condition; the API drops those of other users. Reindexing a
+    document stores its owner.
+    """
+    must: list[qm.Condition] = []
+    if user_id:
+        must.append(
+            qm.Filter(
+                should=[
+                    qm.FieldCondition(key="user_id", match=qm.MatchValue(value=user_id)),
+                    qm.IsEmptyCondition(is_empty=qm.PayloadField(key="user_id")),
+                ]
+            )
+        )
+    if document_ids:
+        must.append(qm.FieldCondition(key="document_id", match=qm.MatchAny(any=document_ids)))
+    return qm.Filter(must=must) if must else None
+
+
+def _chunk_payload(document_id: str, chunk_index: int, text: str, extra: dict) -> dict:
+    """Build the payload stored with a chunk point."""
+    payload = {
+        "document_id": document_id,
+        "chunk_index": chunk_index,
+        "text": text,
+    }
+    payload.update({k: v for k, v in extra.items() if v is not None})
+    return payload
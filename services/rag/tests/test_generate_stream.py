@@ -0,0 +1,70 @@
+from __future__ import annotations
+
+import json
+
+from fastapi.testclient import TestClient
+
+from app.generator.llm_generator import NO_ANSWER, LLMGenerator
+from app.retriever.qdrant_retriever import RetrievedChunk
+
+
+class StubProvider:
+    def generate(self, system_prompt: str, user_prompt: str, max_tokens: int) -> str:
+        return "".join(self.stream(system_prompt, user_prompt, max_tokens))
+
+    def stream(self, system_prompt: str, user_prompt: str, max_tokens: int):
+        return iter(["The answer", " is 42."])
+
+
+class FailingProvider(StubProvider):
+    def stream(self, system_prompt: str, user_prompt: str, max_tokens: int):
+        def deltas():
+            yield "The answer"
+            raise RuntimeError("connection reset")
+
+        return deltas()
+
+
+def _chunk() -> RetrievedChunk:
+    return RetrievedChunk(id="c1", score=0.9, document_id="doc", text="The answer is 42.", chunk_index=3)
+
+
+def test_generate_stream_yields_deltas_and_citations():
+    deltas, citations = LLMGenerator(provider=StubProvider()).generate_stream("q", [_chunk()])
+    assert list(deltas) == ["The answer", " is 42."]
+    assert [c.chunk_id for c in citations] == ["c1"]
+
+
+def test_generate_stream_without_context():
+    deltas, citations = LLMGenerator(provider=StubProvider()).generate_stream("q", [])
+    assert list(deltas) == [NO_ANSWER]
+    assert citations == []
+
+
+def _stream(monkeypatch, provider) -> list[dict]:
+    import app.api.routes as routes
+    import app.main as main_module
+
+    monkeypatch.setattr(main_module, "ensure_collection", lambda: None)
+    monkeypatch.setattr(routes, "LLMGenerator", lambda: LLMGenerator(provider=provider))
+
+    body = {
+        "query": "q",
+        "contexts": [{"chunk_id": "c1", "text": "The answer is 42.", "score": 0.9, "document_id": "doc", "chunk_index": 3}],
+    }
+    with TestClient(main_module.app) as client:
+        resp = client.post("/generate/stream", json=body)
+        assert resp.status_code == 200
+        assert resp.headers["content-type"].startswith("application/x-ndjson")
+        return [json.loads(line) for line in resp.text.splitlines()]
+
+
+def test_generate_stream_endpoint(monkeypatch):
+    lines = _stream(monkeypatch, StubProvider())
+    assert lines[:2] == [{"delta": "The answer"}, {"delta": " is 42."}]
+    assert [c["chunk_id"] for c in lines[2]["citations"]] == ["c1"]
+
+
+def test_generate_stream_endpoint_reports_midway_errors(monkeypatch):
+    lines = _stream(monkeypatch, FailingProvider())
+    assert lines == [{"delta": "The answer"}, {"error": "connection reset"}]
@@ -0,0 +1,43 @@
+from __future__ import annotations
+
+from fastapi.testclient import TestClient
+
+
+def _client(monkeypatch) -> TestClient:
+    import app.main as main_module
+
+    monkeypatch.setattr(main_module, "ensure_collection", lambda: None)
+    return TestClient(main_module.app)
+
+
+def test_request_id_is_echoed(monkeypatch):
+    with _client(monkeypatch) as client:
+        resp = client.get("/health", headers={"X-Request-Id": "abc-123"})
+        assert resp.headers["x-request-id"] == "abc-123"
+
+
+def test_request_id_is_generated_when_missing_or_invalid(monkeypatch):
+    with _client(monkeypatch) as client:
+        generated = client.get("/health").headers["x-request-id"]
+        replaced = client.get("/health", headers={"X-Request-Id": "bad id\n"}).headers["x-request-id"]
+    assert len(generated) == 32
+    assert len(replaced) == 32 and replaced != generated
+
+
+def test_request_context_is_bound_for_handlers(monkeypatch):
+    import app.main as main_module
+    from app.core.logger import get_request_id, get_traceparent
+
+    seen = {}
+
+    @main_module.app.get("/_test/context")
+    def context() -> dict[str, str]:
+        seen["request_id"] = get_request_id()
+        seen["traceparent"] = get_traceparent()
+        return {}
+
+    traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+    with _client(monkeypatch) as client:
+        client.get("/_test/context", headers={"X-Request-Id": "abc-123", "traceparent": traceparent})
+    assert seen == {"request_id": "abc-123", "traceparent": traceparent}
+    assert get_request_id() is None
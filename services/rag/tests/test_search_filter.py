@@ -0,0 +1,35 @@
+from __future__ import annotations
+
+from qdrant_client.http import models as qm
+
+from app.api.routes import _extra_payloads
+from app.api.schemas import ChunkIn, EmbedRequest
+from app.retriever.qdrant_retriever import _chunk_payload, search_filter
+
+
+def test_no_filter_searches_everything():
+    assert search_filter(None, None) is None
+    assert search_filter("", []) is None
+
+
+def test_user_filter_keeps_points_without_owner():
+    f = search_filter("u1", None)
+    assert len(f.must) == 1
+    user = f.must[0]
+    assert isinstance(user, qm.Filter)
+    match, legacy = user.should
+    assert match.key == "user_id" and match.match.value == "u1"
+    assert legacy.is_empty.key == "user_id"
+
+
+def test_document_filter():
+    f = search_filter("u1", ["d1", "d2"])
+    docs = f.must[1]
+    assert docs.key == "document_id"
+    assert docs.match.any == ["d1", "d2"]
+
+
+def test_owner_is_stored_in_payload():
+    req = EmbedRequest(document_id="d1", user_id="u1", chunks=[ChunkIn(chunk_id="c1", chunk_index=0, text="hi")])
+    payload = _chunk_payload("d1", 0, "hi", _extra_payloads(req)[0])
+    assert payload == {"document_id": "d1", "chunk_index": 0, "text": "hi", "user_id": "u1"}
@@ -0,0 +1,132 @@
+from __future__ import annotations
+
+import pytest
+from fastapi.testclient import TestClient
+
+from app.core.signing import (
+    KEY_ID_HEADER,
+    NONCE_HEADER,
+    SIGNATURE_HEADER,
+    TIMESTAMP_HEADER,
+    SignatureError,
+    SignatureVerifier,
+    load_verifier,
+    parse_keys,
+    sign,
+)
+
+NOW = 1_700_000_000
+BODY = b'{"query": "q", "top_k": 5}'
+
+
+def _headers(key_id: str, secret: bytes, nonce: str = "n1", ts: int = NOW, body: bytes = BODY) -> dict[str, str]:
+    return {
+        KEY_ID_HEADER: key_id,
+        TIMESTAMP_HEADER: str(ts),
+        NONCE_HEADER: nonce,
+        SIGNATURE_HEADER: sign(secret, "POST", "/retrieve", str(ts), nonce, body),
+    }
+
+
+def _verifier(spec: str = "k1:secret-one") -> SignatureVerifier:
+    return SignatureVerifier(parse_keys(spec), max_skew=300, clock=lambda: NOW)
+
+
+def test_parse_keys():
+    assert parse_keys(" k1:a, k2:b:c ,") == {"k1": b"a", "k2": b"b:c"}
+    assert parse_keys("") == {}
+    with pytest.raises(ValueError):
+        parse_keys("no-secret")
+
+
+def test_load_verifier():
+    assert isinstance(load_verifier("k1:a"), SignatureVerifier)
+    # Accepting unsigned requests must be asked for explicitly.
+    assert load_verifier("", allow_unsigned=True) is None
+    with pytest.raises(ValueError):
+        load_verifier("")
+    with pytest.raises(ValueError):
+        load_verifier("no-secret", allow_unsigned=True)
+
+
+def _app(monkeypatch, keys: str, allow_unsigned: bool = False):
+    import app.main as main_module
+    from app.core.settings import settings
+
+    monkeypatch.setattr(main_module, "ensure_collection", lambda: None)
+    monkeypatch.setattr(settings, "rag_signing_keys", keys)
+    monkeypatch.setattr(settings, "rag_allow_unsigned", allow_unsigned)
+    return main_module.app
+
+
+@pytest.mark.parametrize("keys", ["", "no-secret"])
+def test_service_refuses_to_start_without_valid_keys(monkeypatch, keys):
+    with pytest.raises(ValueError):
+        with TestClient(_app(monkeypatch, keys)):
+            pass
+
+
+def test_service_rejects_unsigned_requests(monkeypatch):
+    with TestClient(_app(monkeypatch, "k1:secret-one")) as client:
+        assert client.get("/points").status_code == 401
+        assert client.get("/health").status_code == 200
+
+
+# The same vector is checked by services/api/internal/adapters/rag/sign_test.go,
+# so the two sides cannot drift apart.
+VECTOR_SIGNATURE = "688e9cc9c2e3e35a233e323e8bc08958456fc48535d3f45ed4fc6b7fb7dca51d"
+VECTOR_NONCE = "00112233445566778899aabbccddeeff"
+VECTOR_BODY = b'{"query":"q","top_k":5}'
+
+
+def test_sign_matches_shared_vector():
+    assert sign(b"secret-one", "POST", "/retrieve?debug=1", "1700000000", VECTOR_NONCE, VECTOR_BODY) == VECTOR_SIGNATURE
+
+
+def test_verify_accepts_shared_vector():
+    headers = {
+        KEY_ID_HEADER: "k1",
+        TIMESTAMP_HEADER: "1700000000",
+        NONCE_HEADER: VECTOR_NONCE,
+        SIGNATURE_HEADER: VECTOR_SIGNATURE,
+    }
+    assert _verifier().verify("POST", "/retrieve?debug=1", headers, VECTOR_BODY) == "k1"
+
+
+def test_verify_accepts_signed_request():
+    assert _verifier().verify("POST", "/retrieve", _headers("k1", b"secret-one"), BODY) == "k1"
+
+
+def test_verify_accepts_any_configured_key_during_rotation():
+    v = _verifier("k1:secret-one,k2:secret-two")
+    assert v.verify("POST", "/retrieve", _headers("k1", b"secret-one", nonce="a"), BODY) == "k1"
+    assert v.verify("POST", "/retrieve", _headers("k2", b"secret-two", nonce="b"), BODY) == "k2"
+
+
+@pytest.mark.parametrize(
+    "headers, body",
+    [
+        ({}, BODY),
+        (_headers("k1", b"wrong-secret"), BODY),
+        (_headers("k9", b"secret-one"), BODY),
+        (_headers("k1", b"secret-one"), b'{"query": "other"}'),
+        (_headers("k1", b"secret-one", ts=NOW - 301), BODY),
+        (_headers("k1", b"secret-one", ts=NOW + 301), BODY),
+    ],
+)
+def test_verify_rejects(headers, body):
+    with pytest.raises(SignatureError):
+        _verifier().verify("POST", "/retrieve", headers, body)
+
+
+def test_verify_rejects_other_path():
+    with pytest.raises(SignatureError):
+        _verifier().verify("POST", "/points/delete", _headers("k1", b"secret-one"), BODY)
+
+
+def test_verify_rejects_replay():
+    v = _verifier()
+    headers = _headers("k1", b"secret-one")
+    v.verify("POST", "/retrieve", headers, BODY)
+    with pytest.raises(SignatureError, match="replayed"):
+        v.verify("POST", "/retrieve", headers, BODY)